@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"todoapp/store"
+)
+
+const (
+	archiveUsage   = "archive task_id | archive --done days"
+	unarchiveUsage = "unarchive task_id... | unarchive --all [@list] [+tag...]"
+)
+
+// archiveCommand archives a done task, or all the tasks done for more than
+// a number of days.
+func archiveCommand(e *env, args []string) error {
+	if len(args) > 0 && args[0] == "--done" {
+		if len(args) < 2 {
+			return usageError{archiveUsage}
+		}
+		days, err := strconv.Atoi(args[1])
+		if err != nil || days < 0 {
+			return usageError{archiveUsage}
+		}
+		if err := e.store.ArchiveDone(time.Now().AddDate(0, 0, -days)); err != nil {
+			return err
+		}
+		fmt.Printf("Archived the tasks done for more than %d days\n", days)
+		return nil
+	}
+	if len(args) < 1 {
+		return usageError{archiveUsage}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	if err := e.store.ArchiveTask(id, true); err != nil {
+		return err
+	}
+	fmt.Println("Task archived")
+	return nil
+}
+
+// unarchiveCommand brings archived tasks back in one change: those given,
+// or all of them in a list and with tags.
+func unarchiveCommand(e *env, args []string) error {
+	var ops []store.TaskOperation
+	if len(args) > 0 && args[0] == "--all" {
+		tasks, _, err := selectTasks(e, args[1:], "archived")
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			ops = append(ops, store.TaskOperation{Type: "Archive", ID: t.ID})
+		}
+		if len(ops) == 0 {
+			fmt.Println("No archived tasks.")
+			return nil
+		}
+	} else {
+		if len(args) < 1 {
+			return usageError{unarchiveUsage}
+		}
+		for _, arg := range args {
+			id, err := parseTaskID(arg)
+			if err != nil {
+				return err
+			}
+			ops = append(ops, store.TaskOperation{Type: "Archive", ID: id})
+		}
+	}
+	if err := e.store.Batch(ops); err != nil {
+		return err
+	}
+	if len(ops) == 1 {
+		fmt.Println("Task unarchived")
+	} else {
+		fmt.Printf("%d tasks unarchived\n", len(ops))
+	}
+	return nil
+}
@@ -2,127 +2,493 @@ package cli
 
 import (
 	"bufio"
+	"cmp"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"todoapp/store"
 
 	"github.com/google/uuid"
 )
 
-func Start(store *store.InMemoryStore) {
+type argKind int
+
+const (
+	argTitle argKind = iota
+	argPriority
+	argTaskID
+	argShell
+	argProfileCommand
+	argProfile
+	argFile
+	argPosition
+	argTag
+	argFilter
+	argListCommand
+	argList
+	argParent
+	argSwitch
+	argRepeat
+	argTrashCommand
+	argTrashedID
+	argArchivedID
+	argTimerCommand
+	argTimeCommand
+	argReportKind
+)
+
+type command struct {
+	name       string
+	usage      string
+	args       []argKind
+	flags      []string
+	mutates    bool
+	scriptable bool
+	run        func(e *env, args []string) error
+}
+
+// env is what commands run against: the configured store and the profile
+// the CLI acts as, if any. script is set while running a batch script, where
+// nothing may prompt the user.
+type env struct {
+	store   store.Store
+	profile string
+	config  *profileConfig
+	script  bool
+}
+
+type usageError struct {
+	usage string
+}
+
+func (e usageError) Error() string {
+	return "Usage: " + e.usage
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "add", usage: "add title priority [@list] [+tag...]", args: []argKind{argTitle, argPriority, argFilter}, mutates: true, scriptable: true, run: addCommand},
+		{name: "delete", usage: "delete [--tree] task_id", args: []argKind{argTaskID}, flags: []string{"--tree"}, mutates: true, scriptable: true, run: deleteCommand},
+		{name: "edit", usage: "edit task_id [new_title] | edit --all", args: []argKind{argTaskID, argTitle}, flags: []string{"--all"}, mutates: true, scriptable: true, run: editCommand},
+		{name: "toggle", usage: "toggle task_id", args: []argKind{argTaskID}, mutates: true, scriptable: true, run: toggleCommand},
+		{name: "move", usage: "move task_id top|bottom|up|down|before_task_id|@list", args: []argKind{argTaskID, argPosition}, mutates: true, scriptable: true, run: moveCommand},
+		{name: "subtask", usage: "subtask parent_id title priority [+tag...]", args: []argKind{argTaskID, argTitle, argPriority, argTag}, mutates: true, scriptable: true, run: subtaskCommand},
+		{name: "nest", usage: "nest task_id parent_id|top", args: []argKind{argTaskID, argParent}, mutates: true, scriptable: true, run: nestCommand},
+		{name: "autocomplete", usage: "autocomplete task_id on|off", args: []argKind{argTaskID, argSwitch}, mutates: true, scriptable: true, run: autocompleteCommand},
+		{name: "block", usage: "block task_id blocker_id", args: []argKind{argTaskID, argTaskID}, mutates: true, scriptable: true, run: blockCommand},
+		{name: "unblock", usage: "unblock task_id blocker_id", args: []argKind{argTaskID, argTaskID}, mutates: true, scriptable: true, run: unblockCommand},
+		{name: "repeat", usage: repeatUsage, args: []argKind{argTaskID, argRepeat}, mutates: true, scriptable: true, run: repeatCommand},
+		{name: "history", usage: "history task_id", args: []argKind{argTaskID}, scriptable: true, run: historyCommand},
+		{name: "tag", usage: "tag task_id +tag|-tag...", args: []argKind{argTaskID, argTag}, mutates: true, scriptable: true, run: tagCommand},
+		{name: "list", usage: "list [--archived] [@list] [+tag...]", args: []argKind{argFilter}, flags: []string{"--archived"}, scriptable: true, run: listCommand},
+		{name: "ready", usage: "ready [@list] [+tag...]", args: []argKind{argFilter}, scriptable: true, run: readyCommand},
+		{name: "archive", usage: archiveUsage, args: []argKind{argTaskID}, flags: []string{"--done"}, mutates: true, scriptable: true, run: archiveCommand},
+		{name: "unarchive", usage: unarchiveUsage, args: []argKind{argArchivedID}, flags: []string{"--all"}, mutates: true, scriptable: true, run: unarchiveCommand},
+		{name: "trash", usage: trashUsage, args: []argKind{argTrashCommand, argTrashedID}, mutates: true, run: trashCommand},
+		{name: "timer", usage: timerUsage, args: []argKind{argTimerCommand, argTaskID}, mutates: true, run: timerCommand},
+		{name: "time", usage: timeUsage, args: []argKind{argTimeCommand, argTaskID}, mutates: true, run: timeCommand},
+		{name: "report", usage: reportUsage, args: []argKind{argReportKind}, run: reportCommand},
+		{name: "undo", usage: "undo", mutates: true, run: undoCommand},
+		{name: "redo", usage: "redo", mutates: true, run: redoCommand},
+		{name: "lists", usage: listsUsage, args: []argKind{argListCommand, argList}, mutates: true, run: listsCommand},
+		{name: "run", usage: "run [--keep-going] [--dry-run] [--atomic] [script_file]", args: []argKind{argFile}, flags: []string{"--keep-going", "--dry-run", "--atomic"}, mutates: true, run: runCommand},
+		{name: "sync", usage: "sync", run: syncCommand},
+		{name: "login", usage: "login", run: loginCommand},
+		{name: "logout", usage: "logout", run: logoutCommand},
+		{name: "register", usage: "register", run: registerCommand},
+		{name: "profile", usage: "profile add|use|remove|list", args: []argKind{argProfileCommand, argProfile}, run: profileCommand},
+		{name: "help", usage: "help", scriptable: true, run: helpCommand},
+		{name: "completion", usage: "completion bash|zsh|fish", args: []argKind{argShell}, run: completionCommand},
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func commandSummary() string {
+	usages := make([]string, 0, len(commands)+1)
+	for _, c := range commands {
+		usages = append(usages, c.usage)
+	}
+	usages = append(usages, "quit")
+	return "Commands:  " + strings.Join(usages, ", ")
+}
+
+// Run is the entry point of the todo binary. Without arguments it starts the
+// interactive prompt, otherwise it executes a single command and exits.
+func Run(args []string) int {
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	file := fs.String("file", "tasks.json", "path of the tasks file")
+	serverURL := fs.String("server", os.Getenv("TODO_SERVER"), "URL of a single-user todo server")
+	profileName := fs.String("profile", "", "profile to use instead of the current one")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+
+	e, save, err := openEnv(*file, *serverURL, *profileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if len(args) == 0 {
+		interactive(e)
+		save()
+		return 0
+	}
+
+	if args[0] == completeCommand {
+		for _, candidate := range complete(e, args[1:]) {
+			fmt.Println(candidate)
+		}
+		return 0
+	}
+
+	c, err := execute(e, args)
+	if c.mutates {
+		// Saved even on error, a script run may have applied some commands.
+		save()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// openEnv sets up the store the CLI works against and returns a function
+// persisting it. A server given with --server is used as a single user, else
+// the active profile's user on its server, else the local tasks file.
+func openEnv(file, serverURL, profileName string) (*env, func(), error) {
+	config, err := loadProfiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &env{config: config}
+
+	if serverURL != "" {
+		e.store, err = NewQueuedStore(NewRemoteStore(serverURL), journalPath(""))
+		return e, func() {}, err
+	}
+
+	e.profile, err = activeProfile(config, profileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.profile != "" {
+		e.store, err = openProfileStore(e.profile, config.Profiles[e.profile])
+		return e, func() {}, err
+	}
+
+	s, err := store.NewInMemoryStore(store.Config{LoadFromFile: true, FilePath: file})
+	if err != nil {
+		return nil, nil, err
+	}
+	e.store = s
+	return e, s.SaveTasksToFile, nil
+}
+
+// interactive reads commands from stdin until quit.
+func interactive(e *env) {
 
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Println("Task Manager CLI")
-	fmt.Println("Commands:  add title priority, delete task_id, edit task_id new_title, toggle task_id, list, quit")
+	fmt.Println(commandSummary())
 
 	for {
 		fmt.Print("> ")
-		scanner.Scan()
-		command := scanner.Text()
-		args := strings.Fields(command)
+		if !scanner.Scan() {
+			return
+		}
+		args, err := splitArgs(scanner.Text())
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
 
 		if len(args) == 0 {
 			continue
 		}
 
-		switch args[0] {
-		case "add":
-			if len(args) < 3 {
-				fmt.Println("Usage: add title priority")
-				continue
-			}
-			title := args[1]
-			priority := args[2]
-			p, valid := mapStringToPriorityType(priority)
-			if !valid {
-				fmt.Println("Invalid priority. Valid values are: low, medium, high")
-				continue
-			}
-			id := uuid.New()
-
-			err := store.AddItem(id, title, p)
-			if err != nil {
-				fmt.Printf("Error adding task: %s\n", err)
-			} else {
-				fmt.Printf("Task added with ID: %s\n", id)
-			}
-
-		case "delete":
-			if len(args) < 2 {
-				fmt.Println("Usage: delete task_id")
-				continue
-			}
-			id, err := uuid.Parse(args[1])
-			if err != nil {
-				fmt.Println("Invalid UUID format")
-				continue
-			}
-			err = store.DeleteItem(id)
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Println("Task deleted")
-			}
-
-		case "edit":
-			if len(args) < 3 {
-				fmt.Println("Usage: edit task_id new_title")
-				continue
-			}
-			id, err := uuid.Parse(args[1])
-			if err != nil {
-				fmt.Println("Invalid UUID format")
-				continue
-			}
-			newTitle := args[2]
-			err = store.EditTask(id, newTitle)
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Println("Task edited")
-			}
-
-		case "toggle":
-			if len(args) < 2 {
-				fmt.Println("Usage: toggle task_id")
-				continue
-			}
-			id, err := uuid.Parse(args[1])
-			if err != nil {
-				fmt.Println("Invalid UUID format")
-				continue
-			}
-			err = store.ToggleDone(id)
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Println("Task completion toggled")
-			}
-
-		case "list":
-			tasks, _ := store.GetAllItems()
-			if len(tasks) == 0 {
-				fmt.Println("No tasks available.")
-			} else {
-				fmt.Println("Tasks:")
-				for _, task := range tasks {
-					status := "Incomplete"
-					if task.Done {
-						status = "Complete"
-					}
-					fmt.Printf("ID: %s, Title: %s, Priority: %s, Status: %s\n", task.ID, task.Title, task.Priority, status)
-				}
-			}
-
-		case "quit":
+		if args[0] == "quit" {
 			fmt.Println("Exiting Task Manager CLI.")
 			return
+		}
+
+		if _, err := execute(e, args); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func execute(e *env, args []string) (command, error) {
+	c, ok := lookupCommand(args[0])
+	if !ok {
+		return command{}, errors.New("Unknown command!")
+	}
+	return c, c.run(e, args[1:])
+}
+
+func addCommand(e *env, args []string) error {
+	if len(args) < 2 {
+		return usageError{"add title priority [@list] [+tag...]"}
+	}
+	title := args[0]
+	p, valid := mapStringToPriorityType(args[1])
+	if !valid {
+		return errors.New("Invalid priority. Valid values are: low, medium, high")
+	}
+	name, args, err := takeList(args[2:])
+	if err != nil {
+		return err
+	}
+	tags, _, err := parseTags(args, false)
+	if err != nil {
+		return err
+	}
+	var list store.List
+	if name != "" {
+		if list, err = findList(e, name); err != nil {
+			return err
+		}
+	}
+	id := uuid.New()
+
+	if err := e.store.AddItem(id, title, p); err != nil {
+		return fmt.Errorf("Error adding task: %w", err)
+	}
+	if list.ID != uuid.Nil {
+		if err := e.store.SetList(id, list.ID); err != nil {
+			return fmt.Errorf("Error moving task to list: %w", err)
+		}
+	}
+	for _, tag := range tags {
+		if err := e.store.AddTag(id, tag); err != nil {
+			return fmt.Errorf("Error tagging task: %w", err)
+		}
+	}
+	fmt.Printf("Task added with ID: %s\n", id)
+	return nil
+}
+
+// deleteCommand deletes a task, moving its subtasks up to its parent, or
+// together with its subtasks with --tree.
+func deleteCommand(e *env, args []string) error {
+	tree := len(args) > 0 && args[0] == "--tree"
+	if tree {
+		args = args[1:]
+	}
+	if len(args) < 1 {
+		return usageError{"delete [--tree] task_id"}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	if tree {
+		err = e.store.DeleteTree(id)
+	} else {
+		err = e.store.DeleteItem(id)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Task moved to the trash")
+	return nil
+}
+
+func undoCommand(e *env, args []string) error {
+	if err := e.store.Undo(); err != nil {
+		return err
+	}
+	fmt.Println("Last change undone")
+	return nil
+}
+
+func redoCommand(e *env, args []string) error {
+	if err := e.store.Redo(); err != nil {
+		return err
+	}
+	fmt.Println("Change redone")
+	return nil
+}
+
+func toggleCommand(e *env, args []string) error {
+	if len(args) < 1 {
+		return usageError{"toggle task_id"}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	if err := e.store.ToggleDone(id); errors.Is(err, store.ErrBlocked) {
+		return blockedError(e, id)
+	} else if err != nil {
+		return err
+	}
+	fmt.Println("Task completion toggled")
+	printNextOccurrence(e, id)
+	return nil
+}
 
-		default:
-			fmt.Println("Unknown command!")
+// moveCommand moves a task to the top or bottom of the list, one place up or
+// down, just before another task or into another list.
+func moveCommand(e *env, args []string) error {
+	if len(args) < 2 {
+		return usageError{"move task_id top|bottom|up|down|before_task_id|@list"}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	if name, ok := strings.CutPrefix(args[1], "@"); ok {
+		list, err := findList(e, name)
+		if err != nil {
+			return err
 		}
+		if err := e.store.SetList(id, list.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Task moved to %s\n", cmp.Or(list.Name, "the inbox"))
+		return nil
+	}
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tasks, func(t store.Task) bool { return t.ID == id })
+	if i < 0 {
+		return store.ErrTaskNotFound
+	}
+
+	// before stays the task itself when it's already in place.
+	before := id
+	switch args[1] {
+	case "top":
+		before = tasks[0].ID
+	case "bottom":
+		before = uuid.Nil
+	case "up":
+		if i > 0 {
+			before = tasks[i-1].ID
+		}
+	case "down":
+		if i+2 < len(tasks) {
+			before = tasks[i+2].ID
+		} else if i+1 < len(tasks) {
+			before = uuid.Nil
+		}
+	default:
+		if before, err = parseTaskID(args[1]); err != nil {
+			return errors.New("Invalid position. Valid values are: top, bottom, up, down, a task ID or @list")
+		}
+	}
+
+	if err := e.store.MoveTask(id, before); err != nil {
+		return err
+	}
+	fmt.Println("Task moved")
+	return nil
+}
+
+// listCommand prints the tasks of a list, or of all the lists that aren't
+// archived.
+func listCommand(e *env, args []string) error {
+	if len(args) > 0 && args[0] == "--archived" {
+		return listTasks(e, args[1:], "archived")
+	}
+	return listTasks(e, args, "")
+}
+
+// readyCommand lists the open tasks that aren't waiting on other tasks.
+func readyCommand(e *env, args []string) error {
+	return listTasks(e, args, "ready")
+}
+
+// listTasks prints the tasks in a list and with tags, if given, and in
+// state, a Query status.
+func listTasks(e *env, args []string, state string) error {
+	tasks, all, err := selectTasks(e, args, state)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		fmt.Println("No tasks available.")
+		return nil
+	}
+	tasks, depths := store.Nest(tasks)
+	names, spent := listNames(e), timeSpent(e)
+	fmt.Println("Tasks:")
+	for i, task := range tasks {
+		status := "Incomplete"
+		if !task.Archived.IsZero() {
+			status = "Complete (archived)"
+		} else if task.Done {
+			status = "Complete"
+		}
+		list := ""
+		if name, ok := names[task.List]; ok {
+			list = ", List: " + name
+		}
+		fmt.Printf("%sID: %s, Title: %s, Priority: %s, Status: %s%s%s%s%s%s\n", strings.Repeat("  ", depths[i]),
+			task.ID, task.Title, task.Priority, status, formatProgress(all, task.ID), formatBlockers(all, task)+formatRepeat(task), list, formatTags(task.Tags), formatTime(spent, task.ID))
+	}
+	return nil
+}
+
+// selectTasks returns the tasks in state, a Query status, and in the @list
+// and with the tags among args, together with the tasks they were selected
+// from.
+func selectTasks(e *env, args []string, state string) (selected, all []store.Task, err error) {
+	name, args, err := takeList(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	tags, _, err := parseTags(args, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return nil, nil, err
+	}
+	q := store.Query{Tags: tags, Status: state}
+	if name != "" {
+		list, err := findList(e, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		q.List = store.ListKey(list.ID)
+	} else if lists, err := e.store.GetLists(); err == nil {
+		tasks = store.WithoutArchived(tasks, lists)
+	}
+	selected, _ = q.Run(tasks)
+	return selected, tasks, nil
+}
+
+func helpCommand(_ *env, _ []string) error {
+	fmt.Println(commandSummary())
+	return nil
+}
+
+func parseTaskID(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.UUID{}, errors.New("Invalid UUID format")
 	}
+	return id, nil
 }
 
 func mapStringToPriorityType(priority string) (store.Priority, bool) {
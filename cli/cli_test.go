@@ -0,0 +1,415 @@
+package cli
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+func TestMoveCommand(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for _, id := range ids {
+		if err := s.AddItem(id, "Test Task", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	order := func() []uuid.UUID {
+		tasks, _ := s.GetAllItems()
+		var got []uuid.UUID
+		for _, task := range tasks {
+			got = append(got, task.ID)
+		}
+		return got
+	}
+
+	moves := []struct {
+		id       uuid.UUID
+		position string
+		expected []uuid.UUID
+	}{
+		{ids[2], "top", []uuid.UUID{ids[2], ids[0], ids[1]}},
+		{ids[2], "down", []uuid.UUID{ids[0], ids[2], ids[1]}},
+		{ids[2], "down", []uuid.UUID{ids[0], ids[1], ids[2]}},
+		{ids[2], "down", []uuid.UUID{ids[0], ids[1], ids[2]}},
+		{ids[1], "up", []uuid.UUID{ids[1], ids[0], ids[2]}},
+		{ids[1], "up", []uuid.UUID{ids[1], ids[0], ids[2]}},
+		{ids[1], "bottom", []uuid.UUID{ids[0], ids[2], ids[1]}},
+		{ids[1], ids[0].String(), []uuid.UUID{ids[1], ids[0], ids[2]}},
+	}
+	for _, m := range moves {
+		if err := moveCommand(e, []string{m.id.String(), m.position}); err != nil {
+			t.Fatalf("move %s %s: expected no error, got %s", m.id, m.position, err)
+		}
+		if got := order(); !slices.Equal(got, m.expected) {
+			t.Errorf("move %s %s: expected %v, got %v", m.id, m.position, m.expected, got)
+		}
+	}
+
+	if err := moveCommand(e, []string{ids[0].String(), "sideways"}); err == nil {
+		t.Error("expected an error for an invalid position")
+	}
+}
+
+func TestTags(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	if err := addCommand(e, []string{"Deploy", "high", "+Ops", "+backend"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	tasks, _ := s.GetAllItems()
+	id := tasks[0].ID.String()
+	if expected := []string{"backend", "ops"}; !slices.Equal(tasks[0].Tags, expected) {
+		t.Errorf("expected %q, got %q", expected, tasks[0].Tags)
+	}
+
+	if err := tagCommand(e, []string{id, "-backend", "+home"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	tasks, _ = s.GetAllItems()
+	if expected := []string{"home", "ops"}; !slices.Equal(tasks[0].Tags, expected) {
+		t.Errorf("expected %q, got %q", expected, tasks[0].Tags)
+	}
+
+	for _, args := range [][]string{{id, "home"}, {id, "+two words"}} {
+		if err := tagCommand(e, args); err == nil {
+			t.Errorf("tag %q: expected an error", args)
+		}
+	}
+	if err := addCommand(e, []string{"Other", "low", "-home"}); err == nil {
+		t.Error("expected an error removing a tag from a new task")
+	}
+
+	candidates := complete(e, []string{"list", ""})
+	if expected := []string{"+home", "+ops"}; !slices.Equal(candidates, expected) {
+		t.Errorf("expected %v, got %v", expected, candidates)
+	}
+}
+
+func TestLists(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	if err := listsCommand(e, []string{"add", "Work"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	lists, _ := s.GetLists()
+	work := lists[0].ID
+
+	if err := addCommand(e, []string{"Deploy", "high", "@work", "+ops"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	tasks, _ := s.GetAllItems()
+	id := tasks[0].ID.String()
+	if tasks[0].List != work || !slices.Equal(tasks[0].Tags, []string{"ops"}) {
+		t.Errorf("expected the task in %s tagged ops, got %+v", work, tasks[0])
+	}
+
+	if err := moveCommand(e, []string{id, "@inbox"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	tasks, _ = s.GetAllItems()
+	if tasks[0].List != uuid.Nil {
+		t.Errorf("expected the task in the inbox, got %s", tasks[0].List)
+	}
+	if err := moveCommand(e, []string{id, "@Home"}); err == nil {
+		t.Error("expected an error moving to an unknown list")
+	}
+
+	if err := listsCommand(e, []string{"rename", "work", "Job"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	candidates := complete(e, []string{"add", "Task", "low", ""})
+	if expected := []string{"@inbox", "@Job", "+ops"}; !slices.Equal(candidates, expected) {
+		t.Errorf("expected %v, got %v", expected, candidates)
+	}
+
+	if err := listsCommand(e, []string{"archive", "job"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if candidates := complete(e, []string{"list", ""}); slices.Contains(candidates, "@Job") {
+		t.Errorf("expected no archived list in %v", candidates)
+	}
+	if err := listsCommand(e, []string{"remove", "Job"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if lists, _ := s.GetLists(); len(lists) != 0 {
+		t.Errorf("expected no lists, got %v", lists)
+	}
+}
+
+func TestSubtasks(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	parent, other := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{parent, other} {
+		if err := s.AddItem(id, "Test Task", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	if err := subtaskCommand(e, []string{parent.String(), "Step one", "high", "+ops"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := nestCommand(e, []string{other.String(), parent.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := nestCommand(e, []string{parent.String(), other.String()}); err == nil {
+		t.Error("expected an error nesting a task under its own subtask")
+	}
+	if err := autocompleteCommand(e, []string{parent.String(), "on"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	tasks, _ := s.GetAllItems()
+	for _, task := range tasks {
+		if task.ID == parent {
+			continue
+		}
+		if task.Parent != parent {
+			t.Errorf("expected %q under the parent, got %s", task.Title, task.Parent)
+		}
+		if err := toggleCommand(e, []string{task.ID.String()}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+	tasks, _ = s.GetAllItems()
+	if i := slices.IndexFunc(tasks, func(t store.Task) bool { return t.ID == parent }); !tasks[i].Done {
+		t.Error("expected the parent to be completed with its subtasks")
+	}
+
+	if err := deleteCommand(e, []string{"--tree", parent.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if tasks, _ := s.GetAllItems(); len(tasks) != 0 {
+		t.Errorf("expected the subtasks deleted with the parent, got %v", tasks)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	migration, deploy := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{migration, deploy} {
+		if err := s.AddItem(id, "Test Task", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	if err := blockCommand(e, []string{deploy.String(), migration.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := blockCommand(e, []string{migration.String(), deploy.String()}); err == nil {
+		t.Error("expected an error for a dependency cycle")
+	}
+	if err := toggleCommand(e, []string{deploy.String()}); err == nil || !strings.Contains(err.Error(), "blocked") {
+		t.Errorf("expected an error toggling a blocked task, got %v", err)
+	}
+
+	if err := unblockCommand(e, []string{deploy.String(), migration.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := toggleCommand(e, []string{deploy.String()}); err != nil {
+		t.Errorf("expected no error once unblocked, got %s", err)
+	}
+}
+
+func TestRecurrence(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	id := uuid.New()
+	if err := s.AddItem(id, "Water plants", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	if err := repeatCommand(e, []string{id.String(), "every", "3", "days"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := repeatCommand(e, []string{id.String(), "every", "blue", "moon"}); err == nil {
+		t.Error("expected an error for an invalid rule")
+	}
+	if err := toggleCommand(e, []string{id.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	tasks, _ := s.GetAllItems()
+	if len(tasks) != 2 {
+		t.Fatalf("expected the next occurrence added, got %v", tasks)
+	}
+	next := tasks[1]
+	if next.Done || next.Series != id || next.Repeat != "FREQ=DAILY;INTERVAL=3" || next.Due.IsZero() {
+		t.Errorf("expected an open occurrence repeating every 3 days, got %+v", next)
+	}
+	if history := store.History(tasks, next); len(history) != 1 || history[0].ID != id {
+		t.Errorf("expected the completed task in the history, got %v", history)
+	}
+	if err := historyCommand(e, []string{next.ID.String()}); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+
+	if err := repeatCommand(e, []string{next.ID.String(), "off"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := toggleCommand(e, []string{next.ID.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if tasks, _ := s.GetAllItems(); len(tasks) != 2 {
+		t.Errorf("expected no occurrence after the rule was removed, got %v", tasks)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	kept, purged := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{kept, purged} {
+		if err := s.AddItem(id, "Test Task", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+		if err := deleteCommand(e, []string{id.String()}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+	if candidates := complete(e, []string{"trash", "restore", ""}); len(candidates) != 2 {
+		t.Errorf("expected the trashed tasks as candidates, got %v", candidates)
+	}
+
+	if err := trashCommand(e, []string{"restore", kept.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := trashCommand(e, []string{"purge", purged.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := trashCommand(e, []string{"restore", purged.String()}); err == nil {
+		t.Error("expected an error restoring a purged task")
+	}
+	if err := trashCommand(e, []string{"empty", "soon"}); err == nil {
+		t.Error("expected an error for an invalid number of days")
+	}
+	if tasks, _ := s.GetAllItems(); len(tasks) != 1 || tasks[0].ID != kept {
+		t.Errorf("expected the restored task back, got %v", tasks)
+	}
+	if trash, _ := s.GetTrash(); len(trash) != 0 {
+		t.Errorf("expected an empty trash, got %v", trash)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	id := uuid.New()
+	if err := s.AddItem(id, "Test Task", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	if err := deleteCommand(e, []string{id.String()}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if err := undoCommand(e, nil); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if tasks, _ := s.GetAllItems(); len(tasks) != 1 || tasks[0].ID != id {
+		t.Errorf("expected the deleted task back, got %v", tasks)
+	}
+	if err := redoCommand(e, nil); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := redoCommand(e, nil); !errors.Is(err, store.ErrNothingToRedo) {
+		t.Errorf("expected ErrNothingToRedo, got %v", err)
+	}
+	if tasks, _ := s.GetAllItems(); len(tasks) != 0 {
+		t.Errorf("expected the task deleted again, got %v", tasks)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	report, invoice := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{report, invoice} {
+		if err := s.AddItem(id, "Test Task", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	if err := s.AddTag(invoice, "billing"); err != nil {
+		t.Fatalf("Error tagging task: %s", err)
+	}
+	archived := func() int {
+		tasks, _ := s.GetAllItems()
+		matched, _ := store.Query{Status: "archived"}.Run(tasks)
+		return len(matched)
+	}
+
+	if err := archiveCommand(e, []string{report.String()}); !errors.Is(err, store.ErrNotDone) {
+		t.Errorf("expected ErrNotDone, got %v", err)
+	}
+	for _, id := range []uuid.UUID{report, invoice} {
+		if err := s.ToggleDone(id); err != nil {
+			t.Fatalf("Error completing task: %s", err)
+		}
+	}
+	if err := archiveCommand(e, []string{"--done", "1"}); err != nil || archived() != 0 {
+		t.Errorf("expected tasks done today kept, got %v and %d archived", err, archived())
+	}
+	if err := archiveCommand(e, []string{"--done", "0"}); err != nil || archived() != 2 {
+		t.Fatalf("expected the done tasks archived, got %v and %d archived", err, archived())
+	}
+
+	if err := unarchiveCommand(e, []string{"--all", "+billing"}); err != nil || archived() != 1 {
+		t.Errorf("expected the tagged task unarchived, got %v and %d archived", err, archived())
+	}
+	if err := unarchiveCommand(e, []string{report.String(), invoice.String()}); err != nil || archived() != 0 {
+		t.Errorf("expected both tasks unarchived, got %v and %d archived", err, archived())
+	}
+}
+
+func TestTimer(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	e := &env{store: s}
+	task := uuid.New()
+	if err := s.AddItem(task, "Test Task", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	entries := func() []store.TimeEntry {
+		entries, _ := s.GetTimeEntries()
+		return entries
+	}
+
+	if err := timerCommand(e, []string{"start", task.String()}); err != nil {
+		t.Fatalf("expected the timer started, got %v", err)
+	}
+	if err := timerCommand(e, []string{"start", task.String()}); !errors.Is(err, store.ErrTimerRunning) {
+		t.Errorf("expected ErrTimerRunning, got %v", err)
+	}
+	if err := timerCommand(e, []string{"stop"}); err != nil || entries()[0].Running() {
+		t.Fatalf("expected the timer stopped, got %v", err)
+	}
+
+	if err := timeCommand(e, []string{"add", task.String(), "2026-03-02T09:00", "2026-03-02T08:00"}); !errors.Is(err, store.ErrInvalidEntry) {
+		t.Errorf("expected ErrInvalidEntry, got %v", err)
+	}
+	if err := timeCommand(e, []string{"add", task.String(), "2026-03-02T09:00", "2026-03-02T10:30"}); err != nil {
+		t.Fatalf("expected the entry added, got %v", err)
+	}
+	manual := entries()[1]
+	if manual.Duration(time.Now()) != 90*time.Minute {
+		t.Fatalf("expected an entry of 1h30m, got %+v", manual)
+	}
+	if err := timeCommand(e, []string{"edit", manual.ID.String(), "2026-03-02T09:00", "2026-03-02T11:00"}); err != nil {
+		t.Fatalf("expected the entry edited, got %v", err)
+	}
+	if got := entries()[1]; got.Task != task || got.Duration(time.Now()) != 2*time.Hour {
+		t.Errorf("expected the entry edited on the same task, got %+v", got)
+	}
+	if err := timeCommand(e, []string{"delete", manual.ID.String()}); err != nil || len(entries()) != 1 {
+		t.Errorf("expected the entry deleted, got %v", err)
+	}
+	if err := reportCommand(e, []string{"week"}); err == nil {
+		t.Error("expected a usage error for an unknown report")
+	}
+	if err := reportCommand(e, []string{"day", "7"}); err != nil {
+		t.Errorf("expected a report, got %v", err)
+	}
+}
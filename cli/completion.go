@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"todoapp/store"
+)
+
+// completeCommand is the hidden command the shell scripts call back into to
+// get the candidates for the word under the cursor.
+const completeCommand = "__complete"
+
+// globalFlags lists the flags accepted before the command name, mapped to
+// whether they take a value.
+var globalFlags = map[string]bool{
+	"--file":    true,
+	"--server":  true,
+	"--profile": true,
+}
+
+var shells = []string{"bash", "zsh", "fish"}
+
+const bashCompletion = `# bash completion for todo
+_todo() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    local candidates
+    candidates=$(todo __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null | cut -f1)
+    COMPREPLY=($(compgen -W "$candidates" -- "$cur"))
+}
+complete -o default -F _todo todo
+`
+
+const zshCompletion = `#compdef todo
+_todo() {
+    local -a candidates
+    local line value
+    for line in "${(@f)$(todo __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -z $line ]] && continue
+        value=${line%%$'\t'*}
+        value=${value//:/\\:}
+        if [[ $line == *$'\t'* ]]; then
+            candidates+=("$value:${line#*$'\t'}")
+        else
+            candidates+=("$value")
+        fi
+    done
+    if (( ${#candidates} == 0 )); then
+        _files
+        return
+    fi
+    _describe 'todo' candidates
+}
+compdef _todo todo
+`
+
+const fishCompletion = `# fish completion for todo
+function __todo_complete
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    todo __complete $tokens (commandline -ct) 2>/dev/null
+end
+complete -c todo -f -a '(__todo_complete)'
+`
+
+func completionCommand(_ *env, args []string) error {
+	if len(args) < 1 {
+		return usageError{"completion bash|zsh|fish"}
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		return fmt.Errorf("Unsupported shell %q. Valid values are: %s", args[0], strings.Join(shells, ", "))
+	}
+	return nil
+}
+
+// complete returns the candidates for the last of words, which holds the
+// (possibly empty) word being completed. Each candidate may carry a
+// description after a tab, which zsh and fish display next to it.
+func complete(e *env, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	words = words[:len(words)-1]
+
+	i := 0
+	for i < len(words) && strings.HasPrefix(words[i], "-") {
+		if globalFlags[words[i]] && !strings.Contains(words[i], "=") {
+			i++
+		}
+		i++
+	}
+	if i > len(words) {
+		// The word being completed is the value of a global flag.
+		if words[len(words)-1] == "--profile" {
+			return argCandidates(e, argProfile)
+		}
+		return nil
+	}
+
+	if i == len(words) {
+		if strings.HasPrefix(current, "-") {
+			return slices.Sorted(maps.Keys(globalFlags))
+		}
+		candidates := make([]string, 0, len(commands))
+		for _, c := range commands {
+			candidates = append(candidates, c.name+"\t"+c.usage)
+		}
+		return candidates
+	}
+
+	c, ok := lookupCommand(words[i])
+	if !ok {
+		return nil
+	}
+	if strings.HasPrefix(current, "-") {
+		return c.flags
+	}
+
+	position := 0
+	for _, w := range words[i+1:] {
+		if !strings.HasPrefix(w, "-") {
+			position++
+		}
+	}
+	if position >= len(c.args) {
+		// Tags and the tasks to unarchive may be repeated.
+		if len(c.args) == 0 || !slices.Contains([]argKind{argTag, argFilter, argArchivedID}, c.args[len(c.args)-1]) {
+			return nil
+		}
+		position = len(c.args) - 1
+	}
+	return argCandidates(e, c.args[position])
+}
+
+func argCandidates(e *env, kind argKind) []string {
+	switch kind {
+	case argPriority:
+		return []string{"low", "medium", "high"}
+	case argShell:
+		return shells
+	case argProfileCommand:
+		return []string{"add", "use", "remove", "list"}
+	case argProfile:
+		return slices.Sorted(maps.Keys(e.config.Profiles))
+	case argTag:
+		tasks, err := e.store.GetAllItems()
+		if err != nil {
+			return nil
+		}
+		var candidates []string
+		for _, tag := range store.AllTags(tasks) {
+			candidates = append(candidates, "+"+tag)
+		}
+		return candidates
+	case argFilter:
+		return append(listCandidates(e, "@"), argCandidates(e, argTag)...)
+	case argParent:
+		return append([]string{"top"}, argCandidates(e, argTaskID)...)
+	case argSwitch:
+		return []string{"on", "off"}
+	case argRepeat:
+		return []string{"daily", "weekly", "monthly", "yearly", "weekdays", "off"}
+	case argTrashCommand:
+		return []string{"restore", "purge", "empty"}
+	case argTrashedID:
+		trash, err := e.store.GetTrash()
+		if err != nil {
+			return nil
+		}
+		candidates := make([]string, 0, len(trash))
+		for _, task := range trash {
+			candidates = append(candidates, task.ID.String()+"\t"+task.Title)
+		}
+		return candidates
+	case argArchivedID:
+		tasks, err := e.store.GetAllItems()
+		if err != nil {
+			return nil
+		}
+		var candidates []string
+		for _, task := range tasks {
+			if !task.Archived.IsZero() {
+				candidates = append(candidates, task.ID.String()+"\t"+task.Title)
+			}
+		}
+		return candidates
+	case argTimerCommand:
+		return []string{"start", "stop"}
+	case argTimeCommand:
+		return append([]string{"add", "edit", "delete"}, argCandidates(e, argTaskID)...)
+	case argReportKind:
+		return store.ReportKinds
+	case argListCommand:
+		return []string{"add", "rename", "archive", "unarchive", "remove"}
+	case argList:
+		return listCandidates(e, "")
+	case argPosition:
+		return slices.Concat([]string{"top", "bottom", "up", "down"}, listCandidates(e, "@"), argCandidates(e, argTaskID))
+	case argTaskID:
+		tasks, err := e.store.GetAllItems()
+		if err != nil {
+			return nil
+		}
+		candidates := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			candidates = append(candidates, task.ID.String()+"\t"+task.Title)
+		}
+		return candidates
+	default:
+		return nil
+	}
+}
+
+// listCandidates returns the names of the lists after prefix: all of them,
+// or with "@" the inbox and the lists that aren't archived.
+func listCandidates(e *env, prefix string) []string {
+	lists, err := e.store.GetLists()
+	if err != nil || len(lists) == 0 {
+		return nil
+	}
+	var candidates []string
+	if prefix == "@" {
+		candidates = append(candidates, prefix+store.Inbox)
+	}
+	for _, l := range lists {
+		if prefix == "" || !l.Archived {
+			candidates = append(candidates, prefix+l.Name)
+		}
+	}
+	return candidates
+}
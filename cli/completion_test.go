@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"slices"
+	"testing"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+func TestComplete(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	taskID := uuid.New()
+	if err := s.AddItem(taskID, "Test Task", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	e := &env{store: s, config: &profileConfig{Profiles: map[string]profile{"work": {}}}}
+
+	t.Run("commands", func(t *testing.T) {
+		candidates := complete(e, []string{""})
+		if !slices.Contains(candidates, "toggle\ttoggle task_id") {
+			t.Errorf("expected toggle in candidates, got %v", candidates)
+		}
+	})
+
+	t.Run("global flags", func(t *testing.T) {
+		candidates := complete(e, []string{"-"})
+		if !slices.Contains(candidates, "--file") {
+			t.Errorf("expected --file in candidates, got %v", candidates)
+		}
+	})
+
+	t.Run("global flag value", func(t *testing.T) {
+		candidates := complete(e, []string{"--file", ""})
+		if len(candidates) != 0 {
+			t.Errorf("expected no candidates, got %v", candidates)
+		}
+	})
+
+	t.Run("profiles", func(t *testing.T) {
+		candidates := complete(e, []string{"--profile", ""})
+		expected := []string{"work"}
+		if !slices.Equal(candidates, expected) {
+			t.Errorf("expected %v, got %v", expected, candidates)
+		}
+	})
+
+	t.Run("task ids", func(t *testing.T) {
+		candidates := complete(e, []string{"--file", "tasks.json", "toggle", ""})
+		expected := []string{taskID.String() + "\tTest Task"}
+		if !slices.Equal(candidates, expected) {
+			t.Errorf("expected %v, got %v", expected, candidates)
+		}
+	})
+
+	t.Run("priorities", func(t *testing.T) {
+		candidates := complete(e, []string{"add", "title", ""})
+		expected := []string{"low", "medium", "high"}
+		if !slices.Equal(candidates, expected) {
+			t.Errorf("expected %v, got %v", expected, candidates)
+		}
+	})
+
+	t.Run("past the last argument", func(t *testing.T) {
+		candidates := complete(e, []string{"toggle", taskID.String(), ""})
+		if len(candidates) != 0 {
+			t.Errorf("expected no candidates, got %v", candidates)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// blockCommand makes a task wait on another one.
+func blockCommand(e *env, args []string) error {
+	return setBlocker(e, args, true)
+}
+
+func unblockCommand(e *env, args []string) error {
+	return setBlocker(e, args, false)
+}
+
+func setBlocker(e *env, args []string, block bool) error {
+	usage := "block task_id blocker_id"
+	if !block {
+		usage = "unblock task_id blocker_id"
+	}
+	if len(args) < 2 {
+		return usageError{usage}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	blocker, err := parseTaskID(args[1])
+	if err != nil {
+		return err
+	}
+	if block {
+		err = e.store.AddBlocker(id, blocker)
+	} else {
+		err = e.store.RemoveBlocker(id, blocker)
+	}
+	if err != nil {
+		return err
+	}
+	if block {
+		fmt.Println("Task blocked")
+	} else {
+		fmt.Println("Task unblocked")
+	}
+	return nil
+}
+
+// blockedError names the open tasks a task waits on.
+func blockedError(e *env, id uuid.UUID) error {
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return store.ErrBlocked
+	}
+	i := taskIndex(tasks, id)
+	if i < 0 {
+		return store.ErrBlocked
+	}
+	return errors.New("Task is blocked by open tasks" + formatBlockers(tasks, tasks[i]))
+}
+
+// formatBlockers shows the open tasks t waits on.
+func formatBlockers(tasks []store.Task, t store.Task) string {
+	if t.Done {
+		return ""
+	}
+	var titles []string
+	for _, blocker := range store.OpenBlockers(tasks, t) {
+		titles = append(titles, blocker.Title)
+	}
+	if len(titles) == 0 {
+		return ""
+	}
+	return ", Blocked by: " + strings.Join(titles, ", ")
+}
@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+const documentHeader = `<!--
+Edit the tasks below, then save and close the editor.
+Each task is a checklist item: "- [ ] Priority: Title".
+Check the box ([x]) to mark a task done, delete a line to delete the task and
+add a line without an id comment to add a task. Priority defaults to Medium.
+-->
+`
+
+var (
+	itemPattern     = regexp.MustCompile(`^[-*]\s+\[([ xX])\]\s*(.*)$`)
+	idPattern       = regexp.MustCompile(`\s*<!--\s*id:\s*(\S+)\s*-->\s*$`)
+	priorityPattern = regexp.MustCompile(`(?i)^(low|medium|high):\s*`)
+)
+
+// documentTask is a task as written in the editor document. Priority is empty
+// when the line doesn't specify one, and ID is nil for new tasks.
+type documentTask struct {
+	ID       uuid.UUID
+	Title    string
+	Priority store.Priority
+	Done     bool
+	Line     int
+}
+
+func formatDocument(tasks []store.Task) string {
+	var b strings.Builder
+	b.WriteString(documentHeader)
+	for _, task := range tasks {
+		check := " "
+		if task.Done {
+			check = "x"
+		}
+		fmt.Fprintf(&b, "- [%s] %s: %s <!-- id:%s -->\n", check, task.Priority, task.Title, task.ID)
+	}
+	return b.String()
+}
+
+func parseDocument(doc string) ([]documentTask, error) {
+	var tasks []documentTask
+	inComment := false
+
+	for n, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if inComment {
+			inComment = !strings.Contains(line, "-->")
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "<!--") {
+			inComment = !strings.Contains(line, "-->")
+			continue
+		}
+
+		m := itemPattern.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: expected a checklist item like \"- [ ] Medium: Title\"", n+1)
+		}
+		task := documentTask{Done: m[1] != " ", Line: n + 1}
+		rest := m[2]
+
+		if id := idPattern.FindStringSubmatch(rest); id != nil {
+			parsed, err := uuid.Parse(id[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid task id %q", n+1, id[1])
+			}
+			task.ID = parsed
+			rest = rest[:len(rest)-len(id[0])]
+		}
+		if p := priorityPattern.FindStringSubmatch(rest); p != nil {
+			task.Priority, _ = mapStringToPriorityType(p[1])
+			rest = rest[len(p[0]):]
+		}
+
+		task.Title = strings.TrimSpace(rest)
+		if task.Title == "" {
+			return nil, fmt.Errorf("line %d: task title is empty", n+1)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// diffDocument turns the edited document into the operations that bring the
+// original tasks in line with it.
+func diffDocument(original []store.Task, edited []documentTask) ([]store.TaskOperation, error) {
+	byID := make(map[uuid.UUID]store.Task, len(original))
+	for _, task := range original {
+		byID[task.ID] = task
+	}
+
+	var ops []store.TaskOperation
+	seen := make(map[uuid.UUID]bool, len(edited))
+	for _, e := range edited {
+		if e.ID == uuid.Nil {
+			p := e.Priority
+			if p == "" {
+				p = store.Medium
+			}
+			id := uuid.New()
+			ops = append(ops, store.TaskOperation{Type: "Add", ID: id, Title: e.Title, Priority: p})
+			if e.Done {
+				ops = append(ops, store.TaskOperation{Type: "ToggleDone", ID: id})
+			}
+			continue
+		}
+
+		task, ok := byID[e.ID]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown task id %s", e.Line, e.ID)
+		}
+		if seen[e.ID] {
+			return nil, fmt.Errorf("line %d: task %s appears more than once", e.Line, e.ID)
+		}
+		seen[e.ID] = true
+
+		if e.Title != task.Title {
+			ops = append(ops, store.TaskOperation{Type: "Edit", ID: e.ID, Title: e.Title})
+		}
+		if e.Priority != "" && e.Priority != task.Priority {
+			ops = append(ops, store.TaskOperation{Type: "SetPriority", ID: e.ID, Priority: e.Priority})
+		}
+		if e.Done != task.Done {
+			ops = append(ops, store.TaskOperation{Type: "ToggleDone", ID: e.ID})
+		}
+	}
+
+	for _, task := range original {
+		if !seen[task.ID] {
+			ops = append(ops, store.TaskOperation{Type: "Delete", ID: task.ID})
+		}
+	}
+	return ops, nil
+}
+
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// editInEditor opens tasks in the user's editor and applies the changes made
+// to them through s.
+func editInEditor(s store.Store, tasks []store.Task) error {
+	f, err := os.CreateTemp("", "todo-*.md")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	original := formatDocument(tasks)
+	_, err = f.WriteString(original)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running editor: %w", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if string(b) == original {
+		os.Remove(path)
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	edited, err := parseDocument(string(b))
+	if err == nil {
+		var ops []store.TaskOperation
+		ops, err = diffDocument(tasks, edited)
+		if err == nil {
+			os.Remove(path)
+			return applyOperations(s, ops)
+		}
+	}
+	return fmt.Errorf("%w (your changes are kept in %s)", err, path)
+}
+
+func applyOperations(s store.Store, ops []store.TaskOperation) error {
+	counts := make(map[string]int)
+	for _, op := range ops {
+		if err := store.Apply(s, op); err != nil {
+			return fmt.Errorf("%s %s: %w", op.Type, op.ID, err)
+		}
+		counts[op.Type]++
+	}
+	fmt.Printf("Added %d, edited %d, toggled %d, deleted %d task(s).\n",
+		counts["Add"], counts["Edit"]+counts["SetPriority"], counts["ToggleDone"], counts["Delete"])
+	return nil
+}
+
+func editCommand(e *env, args []string) error {
+	if len(args) < 1 {
+		return usageError{"edit task_id [new_title] | edit --all"}
+	}
+	if e.script && (args[0] == "--all" || len(args) < 2) {
+		return usageError{"edit task_id new_title (the editor can't be opened from a script)"}
+	}
+	if args[0] == "--all" {
+		tasks, err := e.store.GetAllItems()
+		if err != nil {
+			return err
+		}
+		return editInEditor(e.store, tasks)
+	}
+
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		if err := e.store.EditTask(id, strings.Join(args[1:], " ")); err != nil {
+			return err
+		}
+		fmt.Println("Task edited")
+		return nil
+	}
+
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if task.ID == id {
+			return editInEditor(e.store, []store.Task{task})
+		}
+	}
+	return store.ErrTaskNotFound
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+func TestEditorDocument(t *testing.T) {
+	keep := store.Task{ID: uuid.New(), Title: "Keep", Priority: store.Low}
+	change := store.Task{ID: uuid.New(), Title: "Change", Priority: store.Low}
+	remove := store.Task{ID: uuid.New(), Title: "Remove", Priority: store.High, Done: true}
+	original := []store.Task{keep, change, remove}
+
+	t.Run("unchanged document", func(t *testing.T) {
+		edited, err := parseDocument(formatDocument(original))
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		ops, err := diffDocument(original, edited)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if len(ops) != 0 {
+			t.Errorf("expected no operations, got %+v", ops)
+		}
+	})
+
+	t.Run("edited document", func(t *testing.T) {
+		doc := formatDocument(original)
+		doc = strings.Replace(doc, "- [ ] Low: Change", "- [x] High: Changed title", 1)
+		doc = strings.Replace(doc, "- [x] High: Remove <!-- id:"+remove.ID.String()+" -->\n", "", 1)
+		doc += "- [ ] New task\n"
+
+		edited, err := parseDocument(doc)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		ops, err := diffDocument(original, edited)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		var types []string
+		for _, op := range ops {
+			types = append(types, op.Type)
+		}
+		expected := "Edit SetPriority ToggleDone Add Delete"
+		if strings.Join(types, " ") != expected {
+			t.Fatalf("expected operations %s, got %v", expected, types)
+		}
+		if ops[0].Title != "Changed title" || ops[1].Priority != store.High {
+			t.Errorf("unexpected operations %+v", ops[:2])
+		}
+		if ops[3].Title != "New task" || ops[3].Priority != store.Medium {
+			t.Errorf("expected new Medium task, got %+v", ops[3])
+		}
+		if ops[4].ID != remove.ID {
+			t.Errorf("expected %s to be deleted, got %s", remove.ID, ops[4].ID)
+		}
+	})
+
+	t.Run("invalid line", func(t *testing.T) {
+		if _, err := parseDocument("- [ ] Fine\nnot a task\n"); err == nil || !strings.HasPrefix(err.Error(), "line 2") {
+			t.Errorf("expected error on line 2, got %v", err)
+		}
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		edited, _ := parseDocument("- [ ] Low: Stranger <!-- id:" + uuid.New().String() + " -->\n")
+		if _, err := diffDocument(original, edited); err == nil {
+			t.Error("expected an error for an unknown task id")
+		}
+	})
+}
@@ -0,0 +1,538 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// journalEntry is an operation recorded while the server was unreachable,
+// together with the task as the CLI last saw it so that replaying can detect
+// changes made on the server in the meantime.
+type journalEntry struct {
+	Op     store.TaskOperation
+	Base   *store.Task `json:",omitempty"`
+	Queued time.Time
+}
+
+type offlineState struct {
+	Tasks   []store.Task
+	Lists   []store.List `json:",omitempty"`
+	Journal []journalEntry
+}
+
+type conflict struct {
+	Entry  journalEntry
+	Reason string
+}
+
+func (c conflict) String() string {
+	return fmt.Sprintf("%s %s queued at %s: %s", c.Entry.Op.Type, c.Entry.Op.ID, c.Entry.Queued.Format(time.DateTime), c.Reason)
+}
+
+// QueuedStore forwards operations to a RemoteStore and records them in a
+// local journal when the server cannot be reached, replaying the journal
+// once it is back.
+type QueuedStore struct {
+	remote *RemoteStore
+	path   string
+	state  offlineState
+}
+
+func NewQueuedStore(remote *RemoteStore, path string) (*QueuedStore, error) {
+	q := &QueuedStore{remote: remote, path: path}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &q.state); err != nil {
+		return nil, fmt.Errorf("reading offline journal %s: %w", path, err)
+	}
+	return q, nil
+}
+
+// journalPath returns where operations queued for the named profile are kept,
+// or those for the server given by --server when name is empty.
+func journalPath(name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	file := "journal.json"
+	if name != "" {
+		file = "journal-" + name + ".json"
+	}
+	return filepath.Join(dir, "todo", file)
+}
+
+func (q *QueuedStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(q.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(q.path, b, 0o600)
+}
+
+func (q *QueuedStore) Pending() int {
+	return len(q.state.Journal)
+}
+
+func (q *QueuedStore) GetAllItems() ([]store.Task, error) {
+	q.syncPending()
+	if q.Pending() > 0 {
+		fmt.Fprintf(os.Stderr, "Showing cached tasks, %d operation(s) still queued.\n", q.Pending())
+		return q.state.Tasks, nil
+	}
+	tasks, err := q.remote.GetAllItems()
+	if err == nil {
+		q.state.Tasks = tasks
+		return tasks, q.save()
+	}
+	if !errors.Is(err, errOffline) {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, "Server unreachable, showing cached tasks.")
+	return q.state.Tasks, nil
+}
+
+// GetLists falls back to the lists last seen while the server is
+// unreachable.
+func (q *QueuedStore) GetLists() ([]store.List, error) {
+	lists, err := q.remote.GetLists()
+	if err == nil {
+		q.state.Lists = lists
+		return lists, q.save()
+	}
+	if !errors.Is(err, errOffline) {
+		return nil, err
+	}
+	return q.state.Lists, nil
+}
+
+func (q *QueuedStore) AddItem(id uuid.UUID, t string, p store.Priority) error {
+	return q.run(store.TaskOperation{Type: "Add", ID: id, Title: t, Priority: p})
+}
+
+func (q *QueuedStore) DeleteItem(id uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "Delete", ID: id})
+}
+
+func (q *QueuedStore) EditTask(id uuid.UUID, t string) error {
+	return q.run(store.TaskOperation{Type: "Edit", ID: id, Title: t})
+}
+
+func (q *QueuedStore) ToggleDone(id uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "ToggleDone", ID: id})
+}
+
+func (q *QueuedStore) SetPriority(id uuid.UUID, p store.Priority) error {
+	return q.run(store.TaskOperation{Type: "SetPriority", ID: id, Priority: p})
+}
+
+func (q *QueuedStore) UpdateTask(t store.Task) error {
+	return q.run(store.UpdateOperation(t))
+}
+
+func (q *QueuedStore) MoveTask(id, before uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "Move", ID: id, Before: before})
+}
+
+func (q *QueuedStore) AddTag(id uuid.UUID, tag string) error {
+	return q.run(store.TaskOperation{Type: "AddTag", ID: id, Tag: tag})
+}
+
+func (q *QueuedStore) RemoveTag(id uuid.UUID, tag string) error {
+	return q.run(store.TaskOperation{Type: "RemoveTag", ID: id, Tag: tag})
+}
+
+func (q *QueuedStore) SetParent(id, parent uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "SetParent", ID: id, Parent: parent})
+}
+
+func (q *QueuedStore) SetAutoComplete(id uuid.UUID, auto bool) error {
+	return q.run(store.TaskOperation{Type: "SetAutoComplete", ID: id, AutoComplete: auto})
+}
+
+func (q *QueuedStore) DeleteTree(id uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "DeleteTree", ID: id})
+}
+
+func (q *QueuedStore) AddBlocker(id, blocker uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "AddBlocker", ID: id, Blocker: blocker})
+}
+
+func (q *QueuedStore) RemoveBlocker(id, blocker uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "RemoveBlocker", ID: id, Blocker: blocker})
+}
+
+func (q *QueuedStore) SetRepeat(id uuid.UUID, rule string) error {
+	return q.run(store.TaskOperation{Type: "SetRepeat", ID: id, Repeat: rule})
+}
+
+func (q *QueuedStore) SetList(id, list uuid.UUID) error {
+	return q.run(store.TaskOperation{Type: "SetList", ID: id, List: list})
+}
+
+// The lists themselves are only changed online: the names of lists created
+// offline couldn't be checked.
+
+func (q *QueuedStore) CreateList(id uuid.UUID, name string) error {
+	return q.runOnline(store.TaskOperation{Type: "CreateList", ID: id, Title: name})
+}
+
+func (q *QueuedStore) RenameList(id uuid.UUID, name string) error {
+	return q.runOnline(store.TaskOperation{Type: "RenameList", ID: id, Title: name})
+}
+
+func (q *QueuedStore) ArchiveList(id uuid.UUID, archived bool) error {
+	return q.runOnline(store.TaskOperation{Type: "ArchiveList", ID: id, Archived: archived})
+}
+
+func (q *QueuedStore) DeleteList(id uuid.UUID) error {
+	return q.runOnline(store.TaskOperation{Type: "DeleteList", ID: id})
+}
+
+// The trash isn't cached, so it is only seen and changed online.
+
+func (q *QueuedStore) GetTrash() ([]store.Task, error) {
+	return q.remote.GetTrash()
+}
+
+func (q *QueuedStore) RestoreTask(id uuid.UUID) error {
+	return q.runOnline(store.TaskOperation{Type: "Restore", ID: id})
+}
+
+func (q *QueuedStore) PurgeTask(id uuid.UUID) error {
+	return q.runOnline(store.TaskOperation{Type: "Purge", ID: id})
+}
+
+func (q *QueuedStore) EmptyTrash(before time.Time) error {
+	return q.runOnline(store.TaskOperation{Type: "EmptyTrash", DeletedBefore: before})
+}
+
+func (q *QueuedStore) ArchiveTask(id uuid.UUID, archived bool) error {
+	return q.run(store.TaskOperation{Type: "Archive", ID: id, Archived: archived})
+}
+
+// Which tasks ArchiveDone archives depends on when the server completed
+// them, so it needs the server.
+
+func (q *QueuedStore) ArchiveDone(before time.Time) error {
+	return q.runOnline(store.TaskOperation{Type: "ArchiveDone", CompletedBefore: before})
+}
+
+// Time entries aren't cached either, and a timer starts and stops when the
+// server sees it.
+
+func (q *QueuedStore) GetTimeEntries() ([]store.TimeEntry, error) {
+	return q.remote.GetTimeEntries()
+}
+
+func (q *QueuedStore) StartTimer(id, task uuid.UUID) error {
+	return q.runOnline(store.TaskOperation{Type: "StartTimer", ID: id, Task: task})
+}
+
+func (q *QueuedStore) StopTimer() error {
+	return q.runOnline(store.TaskOperation{Type: "StopTimer"})
+}
+
+func (q *QueuedStore) AddTimeEntry(e store.TimeEntry) error {
+	return q.runOnline(store.EntryOperation("AddTimeEntry", e))
+}
+
+func (q *QueuedStore) EditTimeEntry(e store.TimeEntry) error {
+	return q.runOnline(store.EntryOperation("EditTimeEntry", e))
+}
+
+func (q *QueuedStore) DeleteTimeEntry(id uuid.UUID) error {
+	return q.runOnline(store.TaskOperation{Type: "DeleteTimeEntry", ID: id})
+}
+
+// Undo and Redo work on the changes the server recorded, so they need it.
+
+func (q *QueuedStore) Undo() error {
+	return q.runOnline(store.TaskOperation{Type: "Undo"})
+}
+
+func (q *QueuedStore) Redo() error {
+	return q.runOnline(store.TaskOperation{Type: "Redo"})
+}
+
+// Batch goes straight to the server, an atomic batch can't be queued and
+// replayed piecemeal.
+func (q *QueuedStore) Batch(ops []store.TaskOperation) error {
+	return q.runOnline(store.TaskOperation{Type: "Batch", Batch: ops})
+}
+
+func (q *QueuedStore) runOnline(op store.TaskOperation) error {
+	q.syncPending()
+	if q.Pending() > 0 {
+		return fmt.Errorf("%w: %d queued operation(s) must be synced first", errOffline, q.Pending())
+	}
+	if err := store.Apply(q.remote, op); err != nil {
+		return err
+	}
+	tasks, err := applyLocal(q.state.Tasks, op)
+	if err == nil {
+		q.state.Tasks = tasks
+	}
+	return q.save()
+}
+
+func (q *QueuedStore) run(op store.TaskOperation) error {
+	q.syncPending()
+	if q.Pending() == 0 {
+		err := store.Apply(q.remote, op)
+		if !errors.Is(err, errOffline) {
+			if err == nil {
+				if tasks, err := applyLocal(q.state.Tasks, op); err == nil {
+					q.state.Tasks = tasks
+				}
+				return q.save()
+			}
+			return err
+		}
+	}
+
+	var base *store.Task
+	if i := taskIndex(q.state.Tasks, op.ID); i >= 0 {
+		task := q.state.Tasks[i]
+		base = &task
+	}
+	tasks, err := applyLocal(q.state.Tasks, op)
+	if err != nil {
+		return err
+	}
+	q.state.Tasks = tasks
+	q.state.Journal = append(q.state.Journal, journalEntry{Op: op, Base: base, Queued: time.Now()})
+	fmt.Fprintln(os.Stderr, "Server unreachable, operation queued for sync.")
+	return q.save()
+}
+
+// syncPending replays queued operations before any other request so the
+// server sees them in order, reporting conflicts on stderr.
+func (q *QueuedStore) syncPending() {
+	if q.Pending() == 0 {
+		return
+	}
+	synced, conflicts, err := q.Sync()
+	if synced > 0 || len(conflicts) > 0 {
+		fmt.Fprintf(os.Stderr, "Synced %d queued operation(s).\n", synced)
+	}
+	for _, c := range conflicts {
+		fmt.Fprintf(os.Stderr, "Conflict: %s\n", c)
+	}
+	if err != nil && !errors.Is(err, errOffline) {
+		fmt.Fprintf(os.Stderr, "Sync stopped: %s\n", err)
+	}
+}
+
+// Sync replays the journal against the server. Operations whose task changed
+// or vanished on the server since they were queued are dropped and reported
+// as conflicts. Sync stops at the first operation the server can't be reached
+// for, leaving it and the ones after it queued.
+func (q *QueuedStore) Sync() (int, []conflict, error) {
+	synced := 0
+	var conflicts []conflict
+
+	for len(q.state.Journal) > 0 {
+		entry := q.state.Journal[0]
+
+		reason, err := q.check(entry)
+		if err == nil && reason == "" {
+			err = store.Apply(q.remote, entry.Op)
+			if err != nil && !errors.Is(err, errOffline) {
+				reason, err = err.Error(), nil
+			}
+		}
+		if err != nil {
+			_ = q.save()
+			return synced, conflicts, err
+		}
+
+		if reason != "" {
+			conflicts = append(conflicts, conflict{Entry: entry, Reason: reason})
+		} else {
+			synced++
+		}
+		q.state.Journal = q.state.Journal[1:]
+	}
+
+	if tasks, err := q.remote.GetAllItems(); err == nil {
+		q.state.Tasks = tasks
+	}
+	return synced, conflicts, q.save()
+}
+
+// check compares the server's copy of the entry's task with the copy it was
+// queued against and returns the reason it conflicts, if any.
+func (q *QueuedStore) check(entry journalEntry) (string, error) {
+	current, err := q.remote.GetItem(entry.Op.ID)
+	if errors.Is(err, store.ErrTaskNotFound) {
+		if entry.Op.Type == "Add" {
+			return "", nil
+		}
+		return "task was deleted on the server", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if entry.Op.Type == "Add" {
+		return "a task with this ID already exists on the server", nil
+	}
+	if entry.Base != nil && !sameTask(current, *entry.Base) {
+		return fmt.Sprintf("task was changed on the server (now %q, %s, done: %t)", current.Title, current.Priority, current.Done), nil
+	}
+	return "", nil
+}
+
+func sameTask(a, b store.Task) bool {
+	return a.ID == b.ID && a.Title == b.Title && a.Priority == b.Priority && a.Done == b.Done &&
+		a.Description == b.Description && a.Due.Equal(b.Due) && slices.Equal(a.Tags, b.Tags) && a.List == b.List &&
+		a.Parent == b.Parent && a.AutoComplete == b.AutoComplete && slices.Equal(a.BlockedBy, b.BlockedBy) &&
+		a.Repeat == b.Repeat && a.Series == b.Series && a.Archived.IsZero() == b.Archived.IsZero()
+}
+
+func taskIndex(tasks []store.Task, id uuid.UUID) int {
+	return slices.IndexFunc(tasks, func(t store.Task) bool { return t.ID == id })
+}
+
+// applyLocal returns a copy of tasks with op applied, mirroring what the
+// server will do when the operation is replayed.
+func applyLocal(tasks []store.Task, op store.TaskOperation) ([]store.Task, error) {
+	tasks = slices.Clone(tasks)
+	switch op.Type {
+	case "Add":
+		return append(tasks, store.Task{ID: op.ID, Title: op.Title, Priority: op.Priority, Position: store.NextPosition(tasks)}), nil
+	case "Move":
+		return store.Reorder(tasks, op.ID, op.Before)
+	case "Delete", "DeleteTree":
+		return store.RemoveTask(tasks, op.ID, op.Type == "DeleteTree")
+	case "SetParent":
+		if err := store.CheckParent(tasks, op.ID, op.Parent); err != nil {
+			return nil, err
+		}
+		i := taskIndex(tasks, op.ID)
+		previous := tasks[i].Parent
+		tasks[i].Parent = op.Parent
+		store.RollUp(tasks, previous)
+		store.RollUp(tasks, op.Parent)
+		return tasks, nil
+	case "AddBlocker":
+		if err := store.CheckBlocker(tasks, op.ID, op.Blocker); err != nil {
+			return nil, err
+		}
+		i := taskIndex(tasks, op.ID)
+		tasks[i] = tasks[i].WithBlocker(op.Blocker)
+		return tasks, nil
+	case "ToggleDone":
+		if err := store.CheckDone(tasks, op.ID); err != nil {
+			return nil, err
+		}
+	case "CreateList", "RenameList", "ArchiveList", "Purge", "EmptyTrash",
+		"StartTimer", "StopTimer", "AddTimeEntry", "EditTimeEntry", "DeleteTimeEntry":
+		return tasks, nil
+	case "ArchiveDone":
+		store.ArchiveDone(tasks, op.CompletedBefore, time.Now())
+		return tasks, nil
+	case "DeleteList":
+		for i := range tasks {
+			if tasks[i].List == op.ID {
+				tasks[i].List = uuid.Nil
+			}
+		}
+		return tasks, nil
+	case "Batch":
+		for i, batchOp := range op.Batch {
+			var err error
+			if tasks, err = applyLocal(tasks, batchOp); err != nil {
+				return nil, fmt.Errorf("operation %d (%s): %w", i+1, batchOp.Type, err)
+			}
+		}
+		return tasks, nil
+	}
+
+	i := taskIndex(tasks, op.ID)
+	if i < 0 {
+		return nil, store.ErrTaskNotFound
+	}
+	switch op.Type {
+	case "Edit":
+		tasks[i].Title = op.Title
+	case "ToggleDone":
+		tasks[i] = tasks[i].WithDone(!tasks[i].Done)
+		tasks = store.Recur(tasks, op.ID)
+		store.RollUp(tasks, tasks[i].Parent)
+	case "RemoveBlocker":
+		tasks[i] = tasks[i].WithoutBlocker(op.Blocker)
+	case "SetRepeat":
+		rule, ok := store.NormalizeRecurrence(op.Repeat)
+		if !ok {
+			return nil, store.ErrInvalidRecurrence
+		}
+		tasks[i].Repeat = rule
+	case "SetAutoComplete":
+		tasks[i].AutoComplete = op.AutoComplete
+		store.RollUp(tasks, op.ID)
+	case "SetPriority":
+		tasks[i].Priority = op.Priority
+	case "AddTag", "RemoveTag":
+		tag, ok := store.NormalizeTag(op.Tag)
+		if !ok {
+			return nil, store.ErrInvalidTag
+		}
+		if op.Type == "AddTag" {
+			tasks[i] = tasks[i].WithTag(tag)
+		} else {
+			tasks[i] = tasks[i].WithoutTag(tag)
+		}
+	case "SetList":
+		tasks[i].List = op.List
+	case "Archive":
+		if err := store.ArchiveTask(tasks, op.ID, op.Archived, time.Now()); err != nil {
+			return nil, err
+		}
+	case "Update":
+		tasks[i].Title = op.Title
+		tasks[i].Priority = op.Priority
+		tasks[i].Description = op.Description
+		tasks[i].Due = op.Due
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Type)
+	}
+	return tasks, nil
+}
+
+func syncCommand(e *env, _ []string) error {
+	q, ok := e.store.(*QueuedStore)
+	if !ok {
+		return errors.New("sync needs a server, use a profile or --server URL")
+	}
+	if q.Pending() == 0 {
+		fmt.Println("Nothing to sync.")
+		return nil
+	}
+	synced, conflicts, err := q.Sync()
+	fmt.Printf("Synced %d queued operation(s).\n", synced)
+	for _, c := range conflicts {
+		fmt.Printf("Conflict: %s\n", c)
+	}
+	if err != nil {
+		return fmt.Errorf("%d operation(s) still queued: %w", q.Pending(), err)
+	}
+	return nil
+}
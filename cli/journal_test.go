@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"todoapp/server"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// offlineServer serves the REST API of backend only while online is true.
+func offlineServer(backend store.Store, online *atomic.Bool) *httptest.Server {
+	handler := server.NewTaskServer(backend).Handler()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online.Load() {
+			hj, _ := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func TestQueuedStore(t *testing.T) {
+	c := store.Config{LoadFromFile: false}
+
+	t.Run("replays queued operations", func(t *testing.T) {
+		backend, _ := store.NewInMemoryStore(c)
+		var online atomic.Bool
+		ts := offlineServer(backend, &online)
+		defer ts.Close()
+
+		q, err := NewQueuedStore(NewRemoteStore(ts.URL), filepath.Join(t.TempDir(), "journal.json"))
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		taskID := uuid.New()
+		if err := q.AddItem(taskID, "Test Task", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+		if err := q.ToggleDone(taskID); err != nil {
+			t.Fatalf("Error toggling task: %s", err)
+		}
+		if q.Pending() != 2 {
+			t.Fatalf("expected 2 queued operations, got %d", q.Pending())
+		}
+
+		online.Store(true)
+		synced, conflicts, err := q.Sync()
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if synced != 2 || len(conflicts) != 0 {
+			t.Errorf("expected 2 synced and no conflicts, got %d and %v", synced, conflicts)
+		}
+
+		tasks, _ := backend.GetAllItems()
+		if len(tasks) != 1 {
+			t.Fatalf("expected 1 task, got %d", len(tasks))
+		}
+		if tasks[0].ID != taskID || !tasks[0].Done {
+			t.Errorf("expected task %s done, got %+v", taskID, tasks[0])
+		}
+	})
+
+	t.Run("reports conflicts", func(t *testing.T) {
+		backend, _ := store.NewInMemoryStore(c)
+		var online atomic.Bool
+		online.Store(true)
+		ts := offlineServer(backend, &online)
+		defer ts.Close()
+
+		changedID, deletedID := uuid.New(), uuid.New()
+		_ = backend.AddItem(changedID, "Changed", store.Low)
+		_ = backend.AddItem(deletedID, "Deleted", store.Low)
+
+		q, _ := NewQueuedStore(NewRemoteStore(ts.URL), filepath.Join(t.TempDir(), "journal.json"))
+		if _, err := q.GetAllItems(); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		online.Store(false)
+		_ = q.EditTask(changedID, "Mine")
+		_ = q.ToggleDone(deletedID)
+
+		_ = backend.EditTask(changedID, "Theirs")
+		_ = backend.DeleteItem(deletedID)
+
+		online.Store(true)
+		synced, conflicts, err := q.Sync()
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if synced != 0 || len(conflicts) != 2 {
+			t.Fatalf("expected 0 synced and 2 conflicts, got %d and %v", synced, conflicts)
+		}
+		if q.Pending() != 0 {
+			t.Errorf("expected empty journal, got %d", q.Pending())
+		}
+
+		tasks, _ := backend.GetAllItems()
+		if len(tasks) != 1 || tasks[0].Title != "Theirs" {
+			t.Errorf("expected the server's change to be kept, got %+v", tasks)
+		}
+	})
+}
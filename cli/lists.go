@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+const listsUsage = "lists [add name | rename list new_name | archive list | unarchive list | remove list]"
+
+// takeList removes the "@list" argument from args and returns the name it
+// gives, "" without one.
+func takeList(args []string) (string, []string, error) {
+	var name string
+	var rest []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") {
+			rest = append(rest, arg)
+			continue
+		}
+		if name != "" {
+			return "", nil, errors.New("Only one @list may be given")
+		}
+		name = arg[1:]
+	}
+	return name, rest, nil
+}
+
+// findList returns the list named name, or with the ID name. The inbox is
+// the zero List.
+func findList(e *env, name string) (store.List, error) {
+	if strings.EqualFold(name, store.Inbox) {
+		return store.List{}, nil
+	}
+	lists, err := e.store.GetLists()
+	if err != nil {
+		return store.List{}, err
+	}
+	l, ok := store.FindList(lists, name)
+	if !ok {
+		return store.List{}, fmt.Errorf("Unknown list %q", name)
+	}
+	return l, nil
+}
+
+func listsCommand(e *env, args []string) error {
+	if len(args) == 0 {
+		return printLists(e)
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return usageError{"lists add name"}
+		}
+		id := uuid.New()
+		if err := e.store.CreateList(id, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("List added with ID: %s\n", id)
+
+	case "rename":
+		if len(args) < 3 {
+			return usageError{"lists rename list new_name"}
+		}
+		l, err := findList(e, args[1])
+		if err != nil {
+			return err
+		}
+		if err := e.store.RenameList(l.ID, args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("List %s renamed to %s\n", l.Name, args[2])
+
+	case "archive", "unarchive":
+		if len(args) < 2 {
+			return usageError{"lists " + args[0] + " list"}
+		}
+		l, err := findList(e, args[1])
+		if err != nil {
+			return err
+		}
+		if err := e.store.ArchiveList(l.ID, args[0] == "archive"); err != nil {
+			return err
+		}
+		fmt.Printf("List %s %sd\n", l.Name, args[0])
+
+	case "remove":
+		if len(args) < 2 {
+			return usageError{"lists remove list"}
+		}
+		l, err := findList(e, args[1])
+		if err != nil {
+			return err
+		}
+		if err := e.store.DeleteList(l.ID); err != nil {
+			return err
+		}
+		fmt.Printf("List %s removed, its tasks are back in the inbox\n", l.Name)
+
+	default:
+		return fmt.Errorf("Unknown lists command %q", args[0])
+	}
+	return nil
+}
+
+func printLists(e *env) error {
+	lists, err := e.store.GetLists()
+	if err != nil {
+		return err
+	}
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return err
+	}
+	counts := map[uuid.UUID]int{}
+	for _, t := range tasks {
+		if !t.Done {
+			counts[t.List]++
+		}
+	}
+	fmt.Println("Lists:")
+	fmt.Printf("Name: Inbox, Open tasks: %d\n", counts[uuid.Nil])
+	for _, l := range lists {
+		archived := ""
+		if l.Archived {
+			archived = ", Archived"
+		}
+		fmt.Printf("ID: %s, Name: %s, Open tasks: %d%s\n", l.ID, l.Name, counts[l.ID], archived)
+	}
+	return nil
+}
+
+// listNames maps the IDs of lists to their names, for printing tasks.
+func listNames(e *env) map[uuid.UUID]string {
+	names := map[uuid.UUID]string{}
+	lists, err := e.store.GetLists()
+	if err != nil {
+		return names
+	}
+	for _, l := range lists {
+		names[l.ID] = l.Name
+	}
+	return names
+}
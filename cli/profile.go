@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"todoapp/store"
+)
+
+// profile names a server and the user to act as on it.
+type profile struct {
+	Server string
+	User   string
+}
+
+type profileConfig struct {
+	Current  string
+	Profiles map[string]profile
+}
+
+type credential struct {
+	Token   string
+	Expires time.Time
+}
+
+func configDir() string {
+	if dir := os.Getenv("TODO_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "todo")
+}
+
+func readJSON(name string, v any) error {
+	b, err := os.ReadFile(filepath.Join(configDir(), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	return nil
+}
+
+func writeJSON(name string, v any) error {
+	if err := os.MkdirAll(configDir(), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(configDir(), name), b, 0o600)
+}
+
+func loadProfiles() (*profileConfig, error) {
+	config := &profileConfig{Profiles: map[string]profile{}}
+	if err := readJSON("config.json", config); err != nil {
+		return nil, err
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]profile{}
+	}
+	return config, nil
+}
+
+func (c *profileConfig) save() error {
+	return writeJSON("config.json", c)
+}
+
+func loadCredentials() (map[string]credential, error) {
+	credentials := map[string]credential{}
+	if err := readJSON("credentials.json", &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func saveCredential(name string, c *credential) error {
+	credentials, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		delete(credentials, name)
+	} else {
+		credentials[name] = *c
+	}
+	return writeJSON("credentials.json", credentials)
+}
+
+// activeProfile resolves the profile to use: the --profile flag, then the
+// TODO_PROFILE environment variable, then the current profile in the config.
+func activeProfile(config *profileConfig, flagValue string) (string, error) {
+	name := flagValue
+	if name == "" {
+		name = os.Getenv("TODO_PROFILE")
+	}
+	if name == "" {
+		return config.Current, nil
+	}
+	if _, ok := config.Profiles[name]; !ok {
+		return "", fmt.Errorf("unknown profile %q", name)
+	}
+	return name, nil
+}
+
+func (e *env) requireProfile() (profile, error) {
+	if e.profile == "" {
+		return profile{}, errors.New("no profile selected, add one with: profile add name server_url user")
+	}
+	return e.config.Profiles[e.profile], nil
+}
+
+func profileCommand(e *env, args []string) error {
+	if len(args) < 1 {
+		return usageError{"profile add name server_url user | profile use name | profile remove name | profile list"}
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 4 {
+			return usageError{"profile add name server_url user"}
+		}
+		e.config.Profiles[args[1]] = profile{Server: args[2], User: args[3]}
+		if e.config.Current == "" {
+			e.config.Current = args[1]
+		}
+		if err := e.config.save(); err != nil {
+			return err
+		}
+		fmt.Printf("Profile %s added\n", args[1])
+
+	case "use":
+		if len(args) < 2 {
+			return usageError{"profile use name"}
+		}
+		if _, ok := e.config.Profiles[args[1]]; !ok {
+			return fmt.Errorf("unknown profile %q", args[1])
+		}
+		e.config.Current = args[1]
+		if err := e.config.save(); err != nil {
+			return err
+		}
+		fmt.Printf("Using profile %s\n", args[1])
+
+	case "remove":
+		if len(args) < 2 {
+			return usageError{"profile remove name"}
+		}
+		if _, ok := e.config.Profiles[args[1]]; !ok {
+			return fmt.Errorf("unknown profile %q", args[1])
+		}
+		delete(e.config.Profiles, args[1])
+		if e.config.Current == args[1] {
+			e.config.Current = ""
+		}
+		if err := e.config.save(); err != nil {
+			return err
+		}
+		if err := saveCredential(args[1], nil); err != nil {
+			return err
+		}
+		fmt.Printf("Profile %s removed\n", args[1])
+
+	case "list":
+		if len(e.config.Profiles) == 0 {
+			fmt.Println("No profiles configured.")
+			return nil
+		}
+		credentials, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		for _, name := range slices.Sorted(maps.Keys(e.config.Profiles)) {
+			p := e.config.Profiles[name]
+			marker := " "
+			if name == e.profile {
+				marker = "*"
+			}
+			status := "logged out"
+			if c, ok := credentials[name]; ok && c.Expires.After(time.Now()) {
+				status = "logged in"
+			}
+			fmt.Printf("%s %s: %s@%s (%s)\n", marker, name, p.User, p.Server, status)
+		}
+
+	default:
+		return fmt.Errorf("Unknown profile command %q", args[0])
+	}
+	return nil
+}
+
+func loginCommand(e *env, _ []string) error {
+	p, err := e.requireProfile()
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(fmt.Sprintf("Password for %s@%s: ", p.User, p.Server))
+	if err != nil {
+		return err
+	}
+	c, err := NewUserRemoteStore(p.Server, "").Login(p.User, password)
+	if err != nil {
+		return err
+	}
+	if err := saveCredential(e.profile, &c); err != nil {
+		return err
+	}
+	fmt.Printf("Logged in as %s\n", p.User)
+	return nil
+}
+
+func registerCommand(e *env, _ []string) error {
+	p, err := e.requireProfile()
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(fmt.Sprintf("New password for %s@%s: ", p.User, p.Server))
+	if err != nil {
+		return err
+	}
+	remote := NewUserRemoteStore(p.Server, "")
+	if err := remote.Register(p.User, password); err != nil {
+		return err
+	}
+	c, err := remote.Login(p.User, password)
+	if err != nil {
+		return err
+	}
+	if err := saveCredential(e.profile, &c); err != nil {
+		return err
+	}
+	fmt.Printf("Registered and logged in as %s\n", p.User)
+	return nil
+}
+
+func logoutCommand(e *env, _ []string) error {
+	p, err := e.requireProfile()
+	if err != nil {
+		return err
+	}
+	credentials, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	if c, ok := credentials[e.profile]; ok {
+		if err := NewUserRemoteStore(p.Server, c.Token).Logout(); err != nil && !errors.Is(err, errOffline) {
+			fmt.Fprintf(os.Stderr, "Server logout failed: %s\n", err)
+		}
+	}
+	if err := saveCredential(e.profile, nil); err != nil {
+		return err
+	}
+	fmt.Println("Logged out")
+	return nil
+}
+
+// readPassword reads a line from stdin, turning off echo when stdin is a
+// terminal.
+func readPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		stty := func(arg string) {
+			cmd := exec.Command("stty", arg)
+			cmd.Stdin = os.Stdin
+			_ = cmd.Run()
+		}
+		stty("-echo")
+		defer func() {
+			stty("echo")
+			fmt.Println()
+		}()
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// openProfileStore returns the store of the profile's user on its server.
+func openProfileStore(name string, p profile) (store.Store, error) {
+	credentials, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	remote := NewUserRemoteStore(p.Server, credentials[name].Token)
+	return NewQueuedStore(remote, journalPath(name))
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+const repeatUsage = "repeat task_id daily|weekly|monthly|yearly|every N days|rrule|off"
+
+func repeatCommand(e *env, args []string) error {
+	if len(args) < 2 {
+		return usageError{repeatUsage}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	rule := strings.Join(args[1:], " ")
+	if rule == "off" {
+		rule = ""
+	}
+	if err := e.store.SetRepeat(id, rule); errors.Is(err, store.ErrInvalidRecurrence) {
+		return fmt.Errorf("Invalid rule %q. Try daily, weekly on mon,thu, every 2 weeks, monthly on day 15 or an RRULE", rule)
+	} else if err != nil {
+		return err
+	}
+	if rule == "" {
+		fmt.Println("Task no longer repeats")
+	} else {
+		fmt.Println("Task repeats " + describeRule(rule))
+	}
+	return nil
+}
+
+// historyCommand lists the completed occurrences of a recurring task.
+func historyCommand(e *env, args []string) error {
+	if len(args) < 1 {
+		return usageError{"history task_id"}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return err
+	}
+	i := taskIndex(tasks, id)
+	if i < 0 {
+		return store.ErrTaskNotFound
+	}
+	history := store.History(tasks, tasks[i])
+	if len(history) == 0 {
+		fmt.Println("No completed occurrences.")
+		return nil
+	}
+	fmt.Println("Completed occurrences:")
+	for _, t := range history {
+		due := "no due date"
+		if !t.Due.IsZero() {
+			due = "due " + t.Due.Format(time.DateOnly)
+		}
+		fmt.Printf("ID: %s, Title: %s, %s\n", t.ID, t.Title, due)
+	}
+	return nil
+}
+
+// describeRule returns a stored rule in words.
+func describeRule(rule string) string {
+	r, err := store.ParseRecurrence(rule)
+	if err != nil {
+		return rule
+	}
+	return r.Describe()
+}
+
+func formatRepeat(t store.Task) string {
+	if t.Repeat == "" || t.Done {
+		return ""
+	}
+	return ", Repeats: " + describeRule(t.Repeat)
+}
+
+// printNextOccurrence reports the occurrence that completing the recurring
+// task id added.
+func printNextOccurrence(e *env, id uuid.UUID) {
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return
+	}
+	i := taskIndex(tasks, id)
+	if i < 0 || !tasks[i].Done || tasks[i].Repeat == "" {
+		return
+	}
+	next := store.NextOccurrenceID(id)
+	if j := taskIndex(tasks, next); j >= 0 && !tasks[j].Done {
+		fmt.Printf("Next occurrence added with ID: %s, due %s\n", next, tasks[j].Due.Format(time.DateOnly))
+	}
+}
@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// errOffline marks failures to reach the server at all, as opposed to the
+// server answering with an error.
+var errOffline = errors.New("server unreachable")
+
+// RemoteStore implements store.Store on top of the server's REST API.
+type RemoteStore struct {
+	baseURL string
+	prefix  string
+	token   string
+	client  *http.Client
+}
+
+// NewRemoteStore returns a store using the single-user V1 API.
+func NewRemoteStore(baseURL string) *RemoteStore {
+	return &RemoteStore{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		prefix:  "/api/v1",
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// NewUserRemoteStore returns a store using the multi-user V2 API, acting as
+// the user the token was issued to.
+func NewUserRemoteStore(baseURL, token string) *RemoteStore {
+	r := NewRemoteStore(baseURL)
+	r.prefix = "/api/v2"
+	r.token = token
+	return r
+}
+
+func (r *RemoteStore) do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, r.baseURL+r.prefix+path, reader)
+	if err != nil {
+		return err
+	}
+	if method != http.MethodGet {
+		// The server takes changes only as JSON, even those without a body.
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errOffline, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return store.ErrTaskNotFound
+	}
+	if resp.StatusCode == http.StatusUnauthorized && path != "/login" {
+		return errors.New("not logged in, run: todo login")
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("server returned %s", resp.Status)
+		}
+		return errors.New(apiErr.Error)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func (r *RemoteStore) GetAllItems() ([]store.Task, error) {
+	var tasks []store.Task
+	err := r.do(http.MethodGet, "/tasks", nil, &tasks)
+	return tasks, err
+}
+
+func (r *RemoteStore) GetItem(id uuid.UUID) (store.Task, error) {
+	var task store.Task
+	err := r.do(http.MethodGet, "/tasks/"+id.String(), nil, &task)
+	return task, err
+}
+
+func (r *RemoteStore) AddItem(id uuid.UUID, t string, p store.Priority) error {
+	return r.do(http.MethodPost, "/tasks", store.Task{ID: id, Title: t, Priority: p}, nil)
+}
+
+func (r *RemoteStore) DeleteItem(id uuid.UUID) error {
+	return r.do(http.MethodDelete, "/tasks/"+id.String(), nil, nil)
+}
+
+func (r *RemoteStore) EditTask(id uuid.UUID, t string) error {
+	return r.do(http.MethodPatch, "/tasks/"+id.String(), map[string]string{"Title": t}, nil)
+}
+
+func (r *RemoteStore) SetPriority(id uuid.UUID, p store.Priority) error {
+	return r.do(http.MethodPatch, "/tasks/"+id.String(), map[string]store.Priority{"Priority": p}, nil)
+}
+
+func (r *RemoteStore) UpdateTask(t store.Task) error {
+	body := map[string]any{"Title": t.Title, "Priority": t.Priority, "Description": t.Description, "Due": nil}
+	if !t.Due.IsZero() {
+		body["Due"] = t.Due
+	}
+	return r.do(http.MethodPatch, "/tasks/"+t.ID.String(), body, nil)
+}
+
+func (r *RemoteStore) MoveTask(id, before uuid.UUID) error {
+	return r.do(http.MethodPost, "/tasks/"+id.String()+"/move", map[string]uuid.UUID{"Before": before}, nil)
+}
+
+func (r *RemoteStore) AddTag(id uuid.UUID, tag string) error {
+	return r.do(http.MethodPost, "/tasks/"+id.String()+"/tags", map[string]string{"Tag": tag}, nil)
+}
+
+func (r *RemoteStore) RemoveTag(id uuid.UUID, tag string) error {
+	return r.do(http.MethodDelete, "/tasks/"+id.String()+"/tags/"+url.PathEscape(tag), nil, nil)
+}
+
+func (r *RemoteStore) SetParent(id, parent uuid.UUID) error {
+	return r.do(http.MethodPost, "/tasks/"+id.String()+"/parent", map[string]uuid.UUID{"Parent": parent}, nil)
+}
+
+func (r *RemoteStore) SetAutoComplete(id uuid.UUID, auto bool) error {
+	return r.do(http.MethodPatch, "/tasks/"+id.String(), map[string]bool{"AutoComplete": auto}, nil)
+}
+
+func (r *RemoteStore) DeleteTree(id uuid.UUID) error {
+	return r.do(http.MethodDelete, "/tasks/"+id.String()+"?subtasks=delete", nil, nil)
+}
+
+func (r *RemoteStore) AddBlocker(id, blocker uuid.UUID) error {
+	return r.do(http.MethodPost, "/tasks/"+id.String()+"/blockers", map[string]uuid.UUID{"Blocker": blocker}, nil)
+}
+
+func (r *RemoteStore) RemoveBlocker(id, blocker uuid.UUID) error {
+	return r.do(http.MethodDelete, "/tasks/"+id.String()+"/blockers/"+blocker.String(), nil, nil)
+}
+
+func (r *RemoteStore) SetRepeat(id uuid.UUID, rule string) error {
+	return r.do(http.MethodPatch, "/tasks/"+id.String(), map[string]string{"Repeat": rule}, nil)
+}
+
+func (r *RemoteStore) SetList(id, list uuid.UUID) error {
+	return r.do(http.MethodPost, "/tasks/"+id.String()+"/list", map[string]uuid.UUID{"List": list}, nil)
+}
+
+func (r *RemoteStore) GetTrash() ([]store.Task, error) {
+	var trash []store.Task
+	err := r.do(http.MethodGet, "/trash", nil, &trash)
+	return trash, err
+}
+
+func (r *RemoteStore) RestoreTask(id uuid.UUID) error {
+	return r.do(http.MethodPost, "/trash/"+id.String()+"/restore", nil, nil)
+}
+
+func (r *RemoteStore) PurgeTask(id uuid.UUID) error {
+	return r.do(http.MethodDelete, "/trash/"+id.String(), nil, nil)
+}
+
+func (r *RemoteStore) EmptyTrash(before time.Time) error {
+	path := "/trash"
+	if !before.IsZero() {
+		path += "?before=" + url.QueryEscape(before.Format(time.RFC3339))
+	}
+	return r.do(http.MethodDelete, path, nil, nil)
+}
+
+func (r *RemoteStore) ArchiveTask(id uuid.UUID, archived bool) error {
+	return r.do(http.MethodPatch, "/tasks/"+id.String(), map[string]bool{"Archived": archived}, nil)
+}
+
+func (r *RemoteStore) ArchiveDone(before time.Time) error {
+	return r.do(http.MethodPost, "/archive?before="+url.QueryEscape(before.Format(time.RFC3339)), nil, nil)
+}
+
+func (r *RemoteStore) GetTimeEntries() ([]store.TimeEntry, error) {
+	var entries []store.TimeEntry
+	err := r.do(http.MethodGet, "/time", nil, &entries)
+	return entries, err
+}
+
+func (r *RemoteStore) StartTimer(id, task uuid.UUID) error {
+	return r.do(http.MethodPost, "/tasks/"+task.String()+"/timer", map[string]uuid.UUID{"ID": id}, nil)
+}
+
+func (r *RemoteStore) StopTimer() error {
+	return r.do(http.MethodDelete, "/timer", nil, nil)
+}
+
+func (r *RemoteStore) AddTimeEntry(e store.TimeEntry) error {
+	return r.do(http.MethodPost, "/time", e, nil)
+}
+
+func (r *RemoteStore) EditTimeEntry(e store.TimeEntry) error {
+	return r.do(http.MethodPatch, "/time/"+e.ID.String(), e, nil)
+}
+
+func (r *RemoteStore) DeleteTimeEntry(id uuid.UUID) error {
+	return r.do(http.MethodDelete, "/time/"+id.String(), nil, nil)
+}
+
+func (r *RemoteStore) Undo() error {
+	return r.do(http.MethodPost, "/undo", nil, nil)
+}
+
+func (r *RemoteStore) Redo() error {
+	return r.do(http.MethodPost, "/redo", nil, nil)
+}
+
+func (r *RemoteStore) GetLists() ([]store.List, error) {
+	var lists []store.List
+	err := r.do(http.MethodGet, "/lists", nil, &lists)
+	return lists, err
+}
+
+func (r *RemoteStore) CreateList(id uuid.UUID, name string) error {
+	return r.do(http.MethodPost, "/lists", store.List{ID: id, Name: name}, nil)
+}
+
+func (r *RemoteStore) RenameList(id uuid.UUID, name string) error {
+	return r.do(http.MethodPatch, "/lists/"+id.String(), map[string]string{"Name": name}, nil)
+}
+
+func (r *RemoteStore) ArchiveList(id uuid.UUID, archived bool) error {
+	return r.do(http.MethodPatch, "/lists/"+id.String(), map[string]bool{"Archived": archived}, nil)
+}
+
+func (r *RemoteStore) DeleteList(id uuid.UUID) error {
+	return r.do(http.MethodDelete, "/lists/"+id.String(), nil, nil)
+}
+
+func (r *RemoteStore) ToggleDone(id uuid.UUID) error {
+	return r.do(http.MethodPost, "/tasks/"+id.String()+"/toggle", nil, nil)
+}
+
+func (r *RemoteStore) Batch(ops []store.TaskOperation) error {
+	return r.do(http.MethodPost, "/batch", ops, nil)
+}
+
+// Register, Login and Logout are only served by the V2 API.
+
+func (r *RemoteStore) Register(user, password string) error {
+	return r.do(http.MethodPost, "/users", map[string]string{"Name": user, "Password": password}, nil)
+}
+
+func (r *RemoteStore) Login(user, password string) (credential, error) {
+	var c credential
+	err := r.do(http.MethodPost, "/login", map[string]string{"Name": user, "Password": password}, &c)
+	return c, err
+}
+
+func (r *RemoteStore) Logout() error {
+	return r.do(http.MethodPost, "/logout", nil, nil)
+}
@@ -0,0 +1,336 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// planStore simulates operations on a copy of the tasks, recording them so
+// they can be checked without mutating anything or applied as one batch.
+// Scripts move tasks between lists but don't change the lists.
+type planStore struct {
+	tasks []store.Task
+	lists []store.List
+	ops   []store.TaskOperation
+}
+
+var (
+	errListsInScript = errors.New("lists can't be changed from a script")
+	errTrashInScript = errors.New("the trash can't be changed from a script")
+	errUndoInScript  = errors.New("changes can't be undone from a script")
+	errTimeInScript  = errors.New("time can't be tracked from a script")
+)
+
+func newPlanStore(s store.Store) (*planStore, error) {
+	tasks, err := s.GetAllItems()
+	if err != nil {
+		return nil, err
+	}
+	lists, err := s.GetLists()
+	if err != nil {
+		return nil, err
+	}
+	return &planStore{tasks: tasks, lists: lists}, nil
+}
+
+func (p *planStore) GetAllItems() ([]store.Task, error) {
+	return p.tasks, nil
+}
+
+func (p *planStore) GetLists() ([]store.List, error) {
+	return p.lists, nil
+}
+
+func (p *planStore) AddItem(id uuid.UUID, t string, priority store.Priority) error {
+	return p.run(store.TaskOperation{Type: "Add", ID: id, Title: t, Priority: priority})
+}
+
+func (p *planStore) DeleteItem(id uuid.UUID) error {
+	return p.run(store.TaskOperation{Type: "Delete", ID: id})
+}
+
+func (p *planStore) EditTask(id uuid.UUID, t string) error {
+	return p.run(store.TaskOperation{Type: "Edit", ID: id, Title: t})
+}
+
+func (p *planStore) ToggleDone(id uuid.UUID) error {
+	return p.run(store.TaskOperation{Type: "ToggleDone", ID: id})
+}
+
+func (p *planStore) SetPriority(id uuid.UUID, priority store.Priority) error {
+	return p.run(store.TaskOperation{Type: "SetPriority", ID: id, Priority: priority})
+}
+
+func (p *planStore) UpdateTask(t store.Task) error {
+	return p.run(store.UpdateOperation(t))
+}
+
+func (p *planStore) MoveTask(id, before uuid.UUID) error {
+	return p.run(store.TaskOperation{Type: "Move", ID: id, Before: before})
+}
+
+func (p *planStore) AddTag(id uuid.UUID, tag string) error {
+	return p.run(store.TaskOperation{Type: "AddTag", ID: id, Tag: tag})
+}
+
+func (p *planStore) RemoveTag(id uuid.UUID, tag string) error {
+	return p.run(store.TaskOperation{Type: "RemoveTag", ID: id, Tag: tag})
+}
+
+func (p *planStore) SetParent(id, parent uuid.UUID) error {
+	return p.run(store.TaskOperation{Type: "SetParent", ID: id, Parent: parent})
+}
+
+func (p *planStore) SetAutoComplete(id uuid.UUID, auto bool) error {
+	return p.run(store.TaskOperation{Type: "SetAutoComplete", ID: id, AutoComplete: auto})
+}
+
+func (p *planStore) DeleteTree(id uuid.UUID) error {
+	return p.run(store.TaskOperation{Type: "DeleteTree", ID: id})
+}
+
+func (p *planStore) AddBlocker(id, blocker uuid.UUID) error {
+	return p.run(store.TaskOperation{Type: "AddBlocker", ID: id, Blocker: blocker})
+}
+
+func (p *planStore) RemoveBlocker(id, blocker uuid.UUID) error {
+	return p.run(store.TaskOperation{Type: "RemoveBlocker", ID: id, Blocker: blocker})
+}
+
+func (p *planStore) SetRepeat(id uuid.UUID, rule string) error {
+	return p.run(store.TaskOperation{Type: "SetRepeat", ID: id, Repeat: rule})
+}
+
+func (p *planStore) SetList(id, list uuid.UUID) error {
+	if list != uuid.Nil && !slices.ContainsFunc(p.lists, func(l store.List) bool { return l.ID == list }) {
+		return store.ErrListNotFound
+	}
+	return p.run(store.TaskOperation{Type: "SetList", ID: id, List: list})
+}
+
+func (p *planStore) CreateList(uuid.UUID, string) error {
+	return errListsInScript
+}
+
+func (p *planStore) RenameList(uuid.UUID, string) error {
+	return errListsInScript
+}
+
+func (p *planStore) ArchiveList(uuid.UUID, bool) error {
+	return errListsInScript
+}
+
+func (p *planStore) DeleteList(uuid.UUID) error {
+	return errListsInScript
+}
+
+func (p *planStore) GetTrash() ([]store.Task, error) {
+	return nil, errTrashInScript
+}
+
+func (p *planStore) RestoreTask(uuid.UUID) error {
+	return errTrashInScript
+}
+
+func (p *planStore) PurgeTask(uuid.UUID) error {
+	return errTrashInScript
+}
+
+func (p *planStore) EmptyTrash(time.Time) error {
+	return errTrashInScript
+}
+
+func (p *planStore) ArchiveTask(id uuid.UUID, archived bool) error {
+	return p.run(store.TaskOperation{Type: "Archive", ID: id, Archived: archived})
+}
+
+func (p *planStore) ArchiveDone(before time.Time) error {
+	return p.run(store.TaskOperation{Type: "ArchiveDone", CompletedBefore: before})
+}
+
+func (p *planStore) GetTimeEntries() ([]store.TimeEntry, error) {
+	return nil, errTimeInScript
+}
+
+func (p *planStore) StartTimer(uuid.UUID, uuid.UUID) error {
+	return errTimeInScript
+}
+
+func (p *planStore) StopTimer() error {
+	return errTimeInScript
+}
+
+func (p *planStore) AddTimeEntry(store.TimeEntry) error {
+	return errTimeInScript
+}
+
+func (p *planStore) EditTimeEntry(store.TimeEntry) error {
+	return errTimeInScript
+}
+
+func (p *planStore) DeleteTimeEntry(uuid.UUID) error {
+	return errTimeInScript
+}
+
+func (p *planStore) Undo() error {
+	return errUndoInScript
+}
+
+func (p *planStore) Redo() error {
+	return errUndoInScript
+}
+
+func (p *planStore) Batch(ops []store.TaskOperation) error {
+	return p.run(store.TaskOperation{Type: "Batch", Batch: ops})
+}
+
+func (p *planStore) run(op store.TaskOperation) error {
+	tasks, err := applyLocal(p.tasks, op)
+	if err != nil {
+		return err
+	}
+	p.tasks = tasks
+	if op.Type == "Batch" {
+		p.ops = append(p.ops, op.Batch...)
+	} else {
+		p.ops = append(p.ops, op)
+	}
+	return nil
+}
+
+// splitArgs splits a command line into words, keeping text in single or
+// double quotes together.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+func runCommand(e *env, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	keepGoing := fs.Bool("keep-going", false, "continue after a command fails")
+	dryRun := fs.Bool("dry-run", false, "check the script without changing any task")
+	atomic := fs.Bool("atomic", false, "apply all changes in one transaction, or none if a command fails")
+	if err := fs.Parse(args); err != nil {
+		return usageError{"run [--keep-going] [--dry-run] [--atomic] [script_file]"}
+	}
+	if *keepGoing && *atomic {
+		return errors.New("--keep-going and --atomic can't be combined")
+	}
+
+	var input io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	}
+
+	target := &env{store: e.store, profile: e.profile, config: e.config, script: true}
+	var plan *planStore
+	if *dryRun || *atomic {
+		var err error
+		if plan, err = newPlanStore(e.store); err != nil {
+			return err
+		}
+		target.store = plan
+	}
+
+	ran, failed := 0, 0
+	scanner := bufio.NewScanner(input)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ran++
+		err := runScriptLine(target, line)
+		if err == nil {
+			continue
+		}
+		failed++
+		fmt.Fprintf(os.Stderr, "line %d: %s\n", n, err)
+		if !*keepGoing {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	switch {
+	case failed > 0 && *atomic:
+		return fmt.Errorf("%d of %d command(s) failed, no changes were applied", failed, ran)
+	case failed > 0:
+		return fmt.Errorf("%d of %d command(s) failed", failed, ran)
+	case *dryRun:
+		fmt.Printf("Dry run: %d command(s) checked, %d change(s) would be applied.\n", ran, len(plan.ops))
+	case *atomic:
+		if err := e.store.Batch(plan.ops); err != nil {
+			return fmt.Errorf("no changes were applied: %w", err)
+		}
+		fmt.Printf("Ran %d command(s), applied %d change(s) atomically.\n", ran, len(plan.ops))
+	default:
+		fmt.Printf("Ran %d command(s).\n", ran)
+	}
+	return nil
+}
+
+func runScriptLine(e *env, line string) error {
+	args, err := splitArgs(line)
+	if err != nil {
+		return err
+	}
+	c, ok := lookupCommand(args[0])
+	if !ok {
+		return errors.New("Unknown command!")
+	}
+	if !c.scriptable {
+		return fmt.Errorf("%s can't be used in a script", c.name)
+	}
+	return c.run(e, args[1:])
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"todoapp/store"
+)
+
+func TestRunScript(t *testing.T) {
+	script := func(t *testing.T, lines string) string {
+		path := filepath.Join(t.TempDir(), "script.todo")
+		if err := os.WriteFile(path, []byte(lines), 0o600); err != nil {
+			t.Fatalf("Error writing script: %s", err)
+		}
+		return path
+	}
+	newEnv := func() *env {
+		s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+		return &env{store: s}
+	}
+	count := func(e *env) int {
+		tasks, _ := e.store.GetAllItems()
+		return len(tasks)
+	}
+
+	const failing = "# comment\nadd first low\ntoggle 00000000-0000-0000-0000-000000000000\nadd 'second task' high\n"
+
+	t.Run("stops on first error", func(t *testing.T) {
+		e := newEnv()
+		if err := runCommand(e, []string{script(t, failing)}); err == nil {
+			t.Error("expected an error")
+		}
+		if count(e) != 1 {
+			t.Errorf("expected 1 task, got %d", count(e))
+		}
+	})
+
+	t.Run("keep going", func(t *testing.T) {
+		e := newEnv()
+		if err := runCommand(e, []string{"--keep-going", script(t, failing)}); err == nil {
+			t.Error("expected an error")
+		}
+		if count(e) != 2 {
+			t.Errorf("expected 2 tasks, got %d", count(e))
+		}
+	})
+
+	t.Run("atomic", func(t *testing.T) {
+		e := newEnv()
+		if err := runCommand(e, []string{"--atomic", script(t, failing)}); err == nil {
+			t.Error("expected an error")
+		}
+		if count(e) != 0 {
+			t.Errorf("expected 0 tasks, got %d", count(e))
+		}
+
+		if err := runCommand(e, []string{"--atomic", script(t, "add first low\nadd second high\n")}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if count(e) != 2 {
+			t.Errorf("expected 2 tasks, got %d", count(e))
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		e := newEnv()
+		if err := runCommand(e, []string{"--dry-run", script(t, "add first low\nlist\n")}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if count(e) != 0 {
+			t.Errorf("expected 0 tasks, got %d", count(e))
+		}
+	})
+
+	t.Run("interactive commands are refused", func(t *testing.T) {
+		e := newEnv()
+		if err := runCommand(e, []string{script(t, "login\n")}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`add "Buy milk" 'high'  `)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if expected := []string{"add", "Buy milk", "high"}; !slices.Equal(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+	if _, err := splitArgs(`add "Buy milk`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
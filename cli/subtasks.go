@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// subtaskCommand adds a task under another one, in the same list.
+func subtaskCommand(e *env, args []string) error {
+	if len(args) < 3 {
+		return usageError{"subtask parent_id title priority [+tag...]"}
+	}
+	parentID, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	p, valid := mapStringToPriorityType(args[2])
+	if !valid {
+		return errors.New("Invalid priority. Valid values are: low, medium, high")
+	}
+	tags, _, err := parseTags(args[3:], false)
+	if err != nil {
+		return err
+	}
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tasks, func(t store.Task) bool { return t.ID == parentID })
+	if i < 0 {
+		return store.ErrTaskNotFound
+	}
+	id := uuid.New()
+
+	if err := e.store.AddItem(id, args[1], p); err != nil {
+		return fmt.Errorf("Error adding task: %w", err)
+	}
+	if err := e.store.SetParent(id, parentID); err != nil {
+		return fmt.Errorf("Error nesting task: %w", err)
+	}
+	if list := tasks[i].List; list != uuid.Nil {
+		if err := e.store.SetList(id, list); err != nil {
+			return fmt.Errorf("Error moving task to list: %w", err)
+		}
+	}
+	for _, tag := range tags {
+		if err := e.store.AddTag(id, tag); err != nil {
+			return fmt.Errorf("Error tagging task: %w", err)
+		}
+	}
+	fmt.Printf("Subtask added with ID: %s\n", id)
+	return nil
+}
+
+// nestCommand makes a task a subtask of another one, or a top-level task
+// again.
+func nestCommand(e *env, args []string) error {
+	if len(args) < 2 {
+		return usageError{"nest task_id parent_id|top"}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	parent := uuid.Nil
+	if args[1] != "top" {
+		if parent, err = parseTaskID(args[1]); err != nil {
+			return errors.New("Invalid parent. Valid values are: top or a task ID")
+		}
+	}
+	if err := e.store.SetParent(id, parent); err != nil {
+		return err
+	}
+	if parent == uuid.Nil {
+		fmt.Println("Task moved to the top level")
+	} else {
+		fmt.Println("Task nested")
+	}
+	return nil
+}
+
+func autocompleteCommand(e *env, args []string) error {
+	if len(args) < 2 || (args[1] != "on" && args[1] != "off") {
+		return usageError{"autocomplete task_id on|off"}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	if err := e.store.SetAutoComplete(id, args[1] == "on"); err != nil {
+		return err
+	}
+	if args[1] == "on" {
+		fmt.Println("Task will be completed with its subtasks")
+	} else {
+		fmt.Println("Task will no longer be completed with its subtasks")
+	}
+	return nil
+}
+
+// formatProgress shows how many subtasks of the task id are done.
+func formatProgress(tasks []store.Task, id uuid.UUID) string {
+	done, total := store.Progress(tasks, id)
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", Subtasks: %d/%d done", done, total)
+}
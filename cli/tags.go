@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"todoapp/store"
+)
+
+// parseTags reads "+tag" arguments, and "-tag" ones too when removals is
+// set. It returns the tags to add and to remove, normalized.
+func parseTags(args []string, removals bool) (add, remove []string, err error) {
+	for _, arg := range args {
+		sign, name := arg[:min(len(arg), 1)], arg[min(len(arg), 1):]
+		if sign != "+" && (!removals || sign != "-") {
+			return nil, nil, fmt.Errorf("Invalid tag %q. Tags are written +tag", arg)
+		}
+		tag, ok := store.NormalizeTag(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("Invalid tag %q. Tags may only contain letters, digits, '_', '.' and '-'", arg)
+		}
+		if sign == "+" {
+			add = append(add, tag)
+		} else {
+			remove = append(remove, tag)
+		}
+	}
+	return add, remove, nil
+}
+
+func tagCommand(e *env, args []string) error {
+	if len(args) < 2 {
+		return usageError{"tag task_id +tag|-tag..."}
+	}
+	id, err := parseTaskID(args[0])
+	if err != nil {
+		return err
+	}
+	add, remove, err := parseTags(args[1:], true)
+	if err != nil {
+		return err
+	}
+	for _, tag := range add {
+		if err := e.store.AddTag(id, tag); err != nil {
+			return err
+		}
+	}
+	for _, tag := range remove {
+		if err := e.store.RemoveTag(id, tag); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Task tags updated")
+	return nil
+}
+
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return ", Tags: +" + strings.Join(tags, " +")
+}
@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+const (
+	timerUsage  = "timer [start task_id | stop]"
+	timeUsage   = "time [task_id | add task_id start end | edit entry_id start end | delete entry_id]"
+	reportUsage = "report task|tag|day [days]"
+
+	// entryLayout is how times of entries are given and shown, in local
+	// time.
+	entryLayout = "2006-01-02T15:04"
+)
+
+// timerCommand shows the running timer, or starts or stops it.
+func timerCommand(e *env, args []string) error {
+	if len(args) == 0 {
+		entries, err := e.store.GetTimeEntries()
+		if err != nil {
+			return err
+		}
+		i := store.RunningTimer(entries)
+		if i < 0 {
+			fmt.Println("No timer is running.")
+			return nil
+		}
+		fmt.Printf("Timer running on %s for %s\n", taskTitles(e)[entries[i].Task], store.FormatDuration(entries[i].Duration(time.Now())))
+		return nil
+	}
+	switch args[0] {
+	case "start":
+		if len(args) < 2 {
+			return usageError{"timer start task_id"}
+		}
+		task, err := parseTaskID(args[1])
+		if err != nil {
+			return err
+		}
+		if err := e.store.StartTimer(uuid.New(), task); err != nil {
+			return err
+		}
+		fmt.Println("Timer started")
+	case "stop":
+		if err := e.store.StopTimer(); err != nil {
+			return err
+		}
+		fmt.Println("Timer stopped")
+	default:
+		return usageError{timerUsage}
+	}
+	return nil
+}
+
+// timeCommand lists the time entries, of a task if given, or adds, edits or
+// deletes one.
+func timeCommand(e *env, args []string) error {
+	if len(args) == 0 {
+		return printEntries(e, uuid.Nil)
+	}
+	switch args[0] {
+	case "add", "edit":
+		if len(args) < 4 {
+			return usageError{timeUsage}
+		}
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			return errors.New("invalid ID format")
+		}
+		start, err1 := time.ParseInLocation(entryLayout, args[2], time.Local)
+		end, err2 := time.ParseInLocation(entryLayout, args[3], time.Local)
+		if err := cmp.Or(err1, err2); err != nil {
+			return fmt.Errorf("times must be given as %s", entryLayout)
+		}
+		if args[0] == "add" {
+			err = e.store.AddTimeEntry(store.TimeEntry{ID: uuid.New(), Task: id, Start: start, End: end})
+		} else {
+			err = editEntry(e, id, start, end)
+		}
+		if err != nil {
+			return err
+		}
+		if args[0] == "add" {
+			fmt.Println("Time entry added")
+		} else {
+			fmt.Println("Time entry edited")
+		}
+	case "delete":
+		if len(args) < 2 {
+			return usageError{"time delete entry_id"}
+		}
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			return errors.New("invalid ID format")
+		}
+		if err := e.store.DeleteTimeEntry(id); err != nil {
+			return err
+		}
+		fmt.Println("Time entry deleted")
+	default:
+		task, err := parseTaskID(args[0])
+		if err != nil {
+			return usageError{timeUsage}
+		}
+		return printEntries(e, task)
+	}
+	return nil
+}
+
+// editEntry changes when the entry id started and ended, keeping its task.
+func editEntry(e *env, id uuid.UUID, start, end time.Time) error {
+	entries, err := e.store.GetTimeEntries()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(entries, func(entry store.TimeEntry) bool { return entry.ID == id })
+	if i < 0 {
+		return store.ErrEntryNotFound
+	}
+	return e.store.EditTimeEntry(store.TimeEntry{ID: id, Task: entries[i].Task, Start: start, End: end})
+}
+
+// printEntries prints the time entries of task, or all of them if it is
+// uuid.Nil.
+func printEntries(e *env, task uuid.UUID) error {
+	entries, err := e.store.GetTimeEntries()
+	if err != nil {
+		return err
+	}
+	if task != uuid.Nil {
+		entries = slices.DeleteFunc(entries, func(entry store.TimeEntry) bool { return entry.Task != task })
+	}
+	if len(entries) == 0 {
+		fmt.Println("No time tracked.")
+		return nil
+	}
+	titles, now := taskTitles(e), time.Now()
+	var total time.Duration
+	for _, entry := range entries {
+		end := "running"
+		if !entry.Running() {
+			end = entry.End.Local().Format(entryLayout)
+		}
+		fmt.Printf("ID: %s, Task: %s, Start: %s, End: %s, Time: %s\n", entry.ID, titles[entry.Task],
+			entry.Start.Local().Format(entryLayout), end, store.FormatDuration(entry.Duration(now)))
+		total += entry.Duration(now)
+	}
+	fmt.Printf("Total: %s\n", store.FormatDuration(total))
+	return nil
+}
+
+// reportCommand totals the time tracked by task, tag or day, over the last
+// days if given.
+func reportCommand(e *env, args []string) error {
+	if len(args) < 1 || !slices.Contains(store.ReportKinds, args[0]) {
+		return usageError{reportUsage}
+	}
+	var from time.Time
+	if len(args) > 1 {
+		days, err := strconv.Atoi(args[1])
+		if err != nil || days < 0 {
+			return usageError{reportUsage}
+		}
+		from = time.Now().AddDate(0, 0, -days)
+	}
+	entries, err := e.store.GetTimeEntries()
+	if err != nil {
+		return err
+	}
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return err
+	}
+	// Time spent on tasks since deleted still counts.
+	if trash, err := e.store.GetTrash(); err == nil {
+		tasks = append(tasks, trash...)
+	}
+	rows := store.TimeReport(tasks, entries, args[0], from, time.Time{}, time.Now(), time.Local)
+	if len(rows) == 0 {
+		fmt.Println("No time tracked.")
+		return nil
+	}
+	var total time.Duration
+	for _, row := range rows {
+		key := row.Key
+		if args[0] == "tag" && key == "" {
+			key = "(no tags)"
+		}
+		fmt.Printf("%8s  %s\n", store.FormatDuration(row.Total), key)
+		if args[0] != "tag" {
+			total += row.Total
+		}
+	}
+	if args[0] != "tag" {
+		fmt.Printf("%8s  Total\n", store.FormatDuration(total))
+	}
+	return nil
+}
+
+// taskTitles returns the titles of the tasks by ID, those in the trash
+// included if it can be read.
+func taskTitles(e *env) map[uuid.UUID]string {
+	titles := map[uuid.UUID]string{}
+	tasks, err := e.store.GetAllItems()
+	if err != nil {
+		return titles
+	}
+	if trash, err := e.store.GetTrash(); err == nil {
+		tasks = append(tasks, trash...)
+	}
+	for _, t := range tasks {
+		titles[t.ID] = t.Title
+	}
+	return titles
+}
+
+// timeSpent returns the time tracked on each task, none if it can't be
+// read.
+func timeSpent(e *env) map[uuid.UUID]time.Duration {
+	entries, err := e.store.GetTimeEntries()
+	if err != nil {
+		return nil
+	}
+	return store.TimeSpent(entries, time.Now())
+}
+
+func formatTime(spent map[uuid.UUID]time.Duration, id uuid.UUID) string {
+	if spent[id] == 0 {
+		return ""
+	}
+	return ", Time: " + store.FormatDuration(spent[id])
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const trashUsage = "trash [restore task_id | purge task_id | empty [days]]"
+
+// trashCommand lists the deleted tasks, restores them or removes them for
+// good.
+func trashCommand(e *env, args []string) error {
+	if len(args) == 0 {
+		return printTrash(e)
+	}
+	switch args[0] {
+	case "restore", "purge":
+		if len(args) < 2 {
+			return usageError{"trash " + args[0] + " task_id"}
+		}
+		id, err := parseTaskID(args[1])
+		if err != nil {
+			return err
+		}
+		if args[0] == "restore" {
+			if err := e.store.RestoreTask(id); err != nil {
+				return err
+			}
+			fmt.Println("Task restored")
+		} else {
+			if err := e.store.PurgeTask(id); err != nil {
+				return err
+			}
+			fmt.Println("Task deleted for good")
+		}
+
+	case "empty":
+		var before time.Time
+		if len(args) > 1 {
+			days, err := strconv.Atoi(args[1])
+			if err != nil || days < 0 {
+				return usageError{"trash empty [days]"}
+			}
+			before = time.Now().AddDate(0, 0, -days)
+		}
+		if err := e.store.EmptyTrash(before); err != nil {
+			return err
+		}
+		if before.IsZero() {
+			fmt.Println("Trash emptied")
+		} else {
+			fmt.Printf("Deleted the tasks in the trash for more than %s days\n", args[1])
+		}
+
+	default:
+		return usageError{trashUsage}
+	}
+	return nil
+}
+
+func printTrash(e *env) error {
+	trash, err := e.store.GetTrash()
+	if err != nil {
+		return err
+	}
+	if len(trash) == 0 {
+		fmt.Println("The trash is empty.")
+		return nil
+	}
+	for _, t := range trash {
+		fmt.Printf("ID: %s, Title: %s, Deleted: %s\n", t.ID, t.Title, t.Deleted.Local().Format(time.DateTime))
+	}
+	return nil
+}
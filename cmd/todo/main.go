@@ -0,0 +1,10 @@
+package main
+
+import (
+	"os"
+	"todoapp/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:]))
+}
@@ -1,6 +1,6 @@
 module todoapp
 
-go 1.23.4
+go 1.24
 
 require github.com/google/uuid v1.6.0
 
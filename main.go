@@ -1,27 +1,122 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	"todoapp/reminders"
 	"todoapp/server"
 	"todoapp/store"
 )
 
 func main() {
+	dev := flag.String("dev", "", "serve templates and static files from this directory, reloading them on change (e.g. server)")
+	remind := flag.String("remind", "1d,0", "send reminders this long before tasks are due, separated by commas (e.g. 1d,2h), empty for none")
+	dueTime := flag.Duration("due-time", 9*time.Hour, "time of day tasks are due")
+	webhook := flag.String("webhook", "", "also post reminders as JSON to this URL")
+	smtpAddr := flag.String("smtp", "", "also mail reminders through the SMTP server at this address (e.g. localhost:1025)")
+	smtpFrom := flag.String("smtp-from", "todo@localhost", "sender of reminder mails")
+	smtpTo := flag.String("smtp-to", "", "recipient of reminder mails")
+	purgeAfter := flag.Int("purge-after", 30, "delete tasks for good after this many days in the trash, 0 to keep them")
+	archiveAfter := flag.Int("archive-after", 0, "archive tasks done for more than this many days, 0 to leave them")
+	flag.Parse()
+
+	offsets, err := reminders.ParseOffsets(*remind)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *purgeAfter < 0 {
+		log.Fatal("-purge-after can't be negative")
+	}
+	if *archiveAfter < 0 {
+		log.Fatal("-archive-after can't be negative")
+	}
+	if *smtpAddr != "" && *smtpTo == "" {
+		log.Fatal("-smtp needs a recipient in -smtp-to")
+	}
+
 	killChan := make(chan os.Signal, 1)
 	signal.Notify(killChan, os.Interrupt, syscall.SIGTERM)
 
 	c := store.Config{LoadFromFile: true, DBName: "todo_app"}
 
-	s, _ := store.NewPostgresStore(c)
+	s, err := store.NewPostgresStore(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	accounts, err := store.NewPostgresAccounts(s.Db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stores := store.NewRegistry(func(user string) (store.Store, error) {
+		return s.ForUser(user), nil
+	})
 	//s, _ := store.NewInMemoryStore(c)
+	//accounts, _ := store.NewInMemoryAccounts(c)
+	//stores := store.NewRegistry(func(user string) (store.Store, error) {
+	//	return store.NewInMemoryStore(store.Config{LoadFromFile: true, User: user})
+	//})
+
+	go server.Start(s, accounts, stores, *dev)
 
-	go server.Start(s)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if len(offsets) > 0 {
+		sent, err := store.NewPostgresReminderLog(s.Db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		notifiers := []reminders.Notifier{reminders.LogNotifier{}}
+		if *webhook != "" {
+			notifiers = append(notifiers, reminders.WebhookNotifier{URL: *webhook})
+		}
+		if *smtpAddr != "" {
+			notifiers = append(notifiers, reminders.SMTPNotifier{Addr: *smtpAddr, From: *smtpFrom, To: *smtpTo})
+		}
+		config := reminders.Config{Offsets: offsets, DueTime: *dueTime}
+		go reminders.NewScheduler(config, reminders.AllStores(s, accounts, stores), sent, notifiers...).Run(ctx)
+	}
+	if *purgeAfter > 0 {
+		go hourly(ctx, reminders.AllStores(s, accounts, stores), "purging the trash", func(st store.Store) error {
+			return st.EmptyTrash(time.Now().AddDate(0, 0, -*purgeAfter))
+		})
+	}
+	if *archiveAfter > 0 {
+		go hourly(ctx, reminders.AllStores(s, accounts, stores), "archiving done tasks", func(st store.Store) error {
+			return store.AutoArchive(st, time.Now().AddDate(0, 0, -*archiveAfter))
+		})
+	}
 
 	<-killChan
 
 	//s.SaveTasksToFile()
 	fmt.Println("Server shut down ...")
 }
+
+// hourly runs job on every store, once an hour until ctx is done. what
+// names the job in errors.
+func hourly(ctx context.Context, stores func() (map[string]store.Store, error), what string, job func(store.Store) error) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		all, err := stores()
+		if err != nil {
+			log.Printf("Error %s: %v", what, err)
+		}
+		for user, st := range all {
+			if err := job(st); err != nil {
+				log.Printf("Error %s of %q: %v", what, user, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package reminders
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notifier delivers reminders. Name tells notifiers apart in the reminder
+// log, so it must not change between restarts.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// LogNotifier writes reminders to the server log.
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string { return "log" }
+
+func (LogNotifier) Notify(ctx context.Context, r Reminder) error {
+	if r.User == "" {
+		log.Printf("Reminder: %s", r.Message())
+	} else {
+		log.Printf("Reminder for %s: %s", r.User, r.Message())
+	}
+	return nil
+}
+
+// WebhookNotifier posts reminders as JSON to URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	User    string    `json:"user"`
+	TaskID  string    `json:"task_id"`
+	Title   string    `json:"title"`
+	Due     time.Time `json:"due"`
+	Offset  string    `json:"offset"`
+	Message string    `json:"message"`
+}
+
+func (WebhookNotifier) Name() string { return "webhook" }
+
+func (n WebhookNotifier) Notify(ctx context.Context, r Reminder) error {
+	body, err := json.Marshal(webhookPayload{
+		User:    r.User,
+		TaskID:  r.Task.ID.String(),
+		Title:   r.Task.Title,
+		Due:     r.Due,
+		Offset:  FormatOffset(r.Offset),
+		Message: r.Message(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier mails reminders to To through the server at Addr, which
+// needs no authentication.
+type SMTPNotifier struct {
+	Addr string
+	From string
+	To   string
+}
+
+func (SMTPNotifier) Name() string { return "smtp" }
+
+func (n SMTPNotifier) Notify(ctx context.Context, r Reminder) error {
+	subject := "Reminder: " + r.Task.Title
+	if r.User != "" {
+		subject = fmt.Sprintf("Reminder for %s: %s", r.User, r.Task.Title)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s.\r\n", r.Message())
+	return n.send(ctx, msg.String())
+}
+
+// send mails msg as smtp.SendMail does, giving up once ctx is done.
+func (n SMTPNotifier) send(ctx context.Context, msg string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(n.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(n.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
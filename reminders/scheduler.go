@@ -0,0 +1,219 @@
+// Package reminders sends reminders of the tasks coming due in every store,
+// at set offsets before their due time.
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"todoapp/store"
+)
+
+// Reminder is sent Offset before the task of User is due.
+type Reminder struct {
+	User   string
+	Task   store.Task
+	Due    time.Time
+	Offset time.Duration
+}
+
+// Message describes the reminder in a sentence.
+func (r Reminder) Message() string {
+	if r.Offset == 0 {
+		return fmt.Sprintf("%q is due now", r.Task.Title)
+	}
+	return fmt.Sprintf("%q is due in %s, on %s", r.Task.Title, FormatOffset(r.Offset), r.Due.Format("Mon Jan 2 15:04"))
+}
+
+type Config struct {
+	// Offsets are how long before tasks are due to remind of them.
+	Offsets []time.Duration
+	// DueTime is the time of day tasks are due, in Location.
+	DueTime  time.Duration
+	Location *time.Location
+	// Late is how long after their time reminders missed while the server
+	// was down are still sent.
+	Late time.Duration
+	// Interval is the longest the scheduler sleeps before looking for
+	// changed due dates.
+	Interval time.Duration
+}
+
+// Scheduler sends reminders through its notifiers, recording them in a
+// ReminderLog so that none is sent twice.
+type Scheduler struct {
+	config    Config
+	stores    func() (map[string]store.Store, error)
+	log       store.ReminderLog
+	notifiers []Notifier
+	now       func() time.Time
+}
+
+// NewScheduler returns a scheduler watching the stores returned by stores,
+// keyed by user.
+func NewScheduler(config Config, stores func() (map[string]store.Store, error), sent store.ReminderLog, notifiers ...Notifier) *Scheduler {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Late == 0 {
+		config.Late = 24 * time.Hour
+	}
+	if config.Interval == 0 {
+		config.Interval = time.Minute
+	}
+	return &Scheduler{config: config, stores: stores, log: sent, notifiers: notifiers, now: time.Now}
+}
+
+// AllStores returns the shared store and those of every user with an account.
+func AllStores(shared store.Store, accounts store.Accounts, stores *store.Registry) func() (map[string]store.Store, error) {
+	return func() (map[string]store.Store, error) {
+		all := map[string]store.Store{"": shared}
+		users, err := accounts.Users()
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if all[user], err = stores.ForUser(user); err != nil {
+				return nil, err
+			}
+		}
+		return all, nil
+	}
+}
+
+// Run sends reminders until ctx is done, waking up when the next one is due
+// or at least every Interval.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		wait := s.config.Interval
+		next, err := s.Check(ctx)
+		if err != nil {
+			log.Printf("Error checking reminders: %v", err)
+		} else if !next.IsZero() {
+			wait = min(wait, max(next.Sub(s.now()), time.Second))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Check sends the reminders that are due and returns when the next one is,
+// zero if none is.
+func (s *Scheduler) Check(ctx context.Context) (time.Time, error) {
+	stores, err := s.stores()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := s.now()
+	var next time.Time
+	for user, st := range stores {
+		tasks, err := st.GetAllItems()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("loading the tasks of %q: %w", user, err)
+		}
+		for _, t := range tasks {
+			if t.Done || t.Due.IsZero() {
+				continue
+			}
+			// Of the reminders whose time has come only the latest is sent,
+			// so that a server that was down doesn't send them all at once.
+			due := s.dueAt(t.Due)
+			latest := -1
+			for i, offset := range s.config.Offsets {
+				at := due.Add(-offset)
+				switch {
+				case at.After(now):
+					if next.IsZero() || at.Before(next) {
+						next = at
+					}
+				case now.Sub(at) <= s.config.Late && (latest < 0 || offset < s.config.Offsets[latest]):
+					latest = i
+				}
+			}
+			if latest >= 0 {
+				s.send(ctx, Reminder{User: user, Task: t, Due: due, Offset: s.config.Offsets[latest]})
+			}
+		}
+	}
+	// Reminders are claimed once their time has come, so those claimed
+	// more than Late ago can't be sent again whatever the log says.
+	if err := s.log.Prune(now.Add(-s.config.Late)); err != nil {
+		return next, fmt.Errorf("pruning the reminder log: %w", err)
+	}
+	return next, nil
+}
+
+func (s *Scheduler) dueAt(date time.Time) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, s.config.Location).Add(s.config.DueTime)
+}
+
+// send delivers r through every notifier that hasn't delivered it yet.
+// Reminders a notifier fails to deliver are tried again on the next check.
+func (s *Scheduler) send(ctx context.Context, r Reminder) {
+	for _, n := range s.notifiers {
+		key := strings.Join([]string{r.User, r.Task.ID.String(), r.Due.Format(time.RFC3339), r.Offset.String(), n.Name()}, "/")
+		claimed, err := s.log.Claim(key)
+		if err != nil {
+			log.Printf("Error recording reminder %s: %v", key, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := n.Notify(ctx, r); err != nil {
+			log.Printf("Error sending reminder through %s: %v", n.Name(), err)
+			if err := s.log.Release(key); err != nil {
+				log.Printf("Error releasing reminder %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// ParseOffsets reads offsets separated by commas, durations like 90m or 2h
+// or a number of days like 1d.
+func ParseOffsets(s string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var offset time.Duration
+		var err error
+		if days, ok := strings.CutSuffix(field, "d"); ok {
+			var n int
+			n, err = strconv.Atoi(days)
+			offset = time.Duration(n) * 24 * time.Hour
+		} else {
+			offset, err = time.ParseDuration(field)
+		}
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid reminder offset %q", field)
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+// FormatOffset writes an offset the way ParseOffsets reads it, in days when
+// it is a whole number of them.
+func FormatOffset(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,254 @@
+package reminders
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+type recorder struct {
+	name string
+	sent []string
+	err  error
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Notify(ctx context.Context, rem Reminder) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, rem.User+": "+rem.Message())
+	return nil
+}
+
+func TestParseOffsets(t *testing.T) {
+	offsets, err := ParseOffsets("1d, 2h,90m,0")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	expected := []time.Duration{24 * time.Hour, 2 * time.Hour, 90 * time.Minute, 0}
+	if !slices.Equal(offsets, expected) {
+		t.Errorf("expected %v, got %v", expected, offsets)
+	}
+	var formatted []string
+	for _, offset := range offsets {
+		formatted = append(formatted, FormatOffset(offset))
+	}
+	if expected := []string{"1d", "2h", "1h30m", "0s"}; !slices.Equal(formatted, expected) {
+		t.Errorf("expected %v, got %v", expected, formatted)
+	}
+	for _, s := range []string{"soon", "-1h", "xd"} {
+		if _, err := ParseOffsets(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	shared, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	alice, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	stores := func() (map[string]store.Store, error) {
+		return map[string]store.Store{"": shared, "alice": alice}, nil
+	}
+	add := func(s store.Store, title string, due time.Time) uuid.UUID {
+		id := uuid.New()
+		if err := s.AddItem(id, title, store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+		if err := s.UpdateTask(store.Task{ID: id, Title: title, Priority: store.Low, Due: due}); err != nil {
+			t.Fatalf("Error updating task: %s", err)
+		}
+		return id
+	}
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	add(shared, "Pay rent", day(11))
+	add(alice, "Dentist", day(10))
+	done := add(alice, "Old task", day(10))
+	_ = alice.ToggleDone(done)
+	add(alice, "Later", day(20))
+
+	config := Config{Offsets: []time.Duration{24 * time.Hour, 0}, DueTime: 9 * time.Hour, Location: time.UTC}
+	sent, _ := store.NewInMemoryReminderLog(store.Config{LoadFromFile: false})
+	notifier := &recorder{name: "test"}
+	s := NewScheduler(config, stores, sent, notifier)
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC) }
+
+	next, err := s.Check(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	// The dentist is due, so only the latest of its reminders is sent.
+	expected := []string{
+		`: "Pay rent" is due in 1d, on Wed Mar 11 09:00`,
+		`alice: "Dentist" is due now`,
+	}
+	slices.Sort(notifier.sent)
+	if !slices.Equal(notifier.sent, expected) {
+		t.Errorf("expected %q, got %q", expected, notifier.sent)
+	}
+	if expected := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("expected the next reminder at %s, got %s", expected, next)
+	}
+
+	// Neither another check nor a restarted scheduler sends them again.
+	notifier.sent = nil
+	_, _ = s.Check(context.Background())
+	restarted := NewScheduler(config, stores, sent, notifier)
+	restarted.now = s.now
+	_, _ = restarted.Check(context.Background())
+	if len(notifier.sent) != 0 {
+		t.Errorf("expected no reminder sent twice, got %q", notifier.sent)
+	}
+
+	// A notifier that fails tries again on the next check.
+	failing := &recorder{name: "failing", err: errors.New("unreachable")}
+	s = NewScheduler(config, stores, sent, failing)
+	s.now = restarted.now
+	_, _ = s.Check(context.Background())
+	failing.err = nil
+	_, _ = s.Check(context.Background())
+	if len(failing.sent) != 2 {
+		t.Errorf("expected the failed reminders sent again, got %q", failing.sent)
+	}
+
+	// Reminders missed for longer than Late are dropped.
+	late := &recorder{name: "late"}
+	s = NewScheduler(config, stores, sent, late)
+	s.now = func() time.Time { return time.Date(2026, 3, 11, 10, 0, 0, 0, time.UTC) }
+	_, _ = s.Check(context.Background())
+	if expected := []string{`: "Pay rent" is due now`}; !slices.Equal(late.sent, expected) {
+		t.Errorf("expected %q, got %q", expected, late.sent)
+	}
+
+	// Checks forget the reminders claimed too long ago to be sent again.
+	_, _ = sent.Claim("old")
+	s.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	if _, err := s.Check(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if ok, _ := sent.Claim("old"); !ok {
+		t.Error("expected the old claim pruned")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got webhookPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	r := Reminder{User: "alice", Task: store.Task{ID: uuid.New(), Title: "Dentist"}, Due: time.Now(), Offset: time.Hour}
+	if err := (WebhookNotifier{URL: ts.URL}).Notify(context.Background(), r); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if got.User != "alice" || got.TaskID != r.Task.ID.String() || got.Offset != "1h" {
+		t.Errorf("expected alice's reminder, got %+v", got)
+	}
+
+	failing := httptest.NewServer(http.NotFoundHandler())
+	defer failing.Close()
+	if err := (WebhookNotifier{URL: failing.URL}).Notify(context.Background(), r); err == nil {
+		t.Error("expected an error for a failed delivery")
+	}
+}
+
+// smtpServer accepts one message, sending its data to the returned channel.
+func smtpServer(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				messages <- data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return l.Addr().String(), messages
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := smtpServer(t)
+	n := SMTPNotifier{Addr: addr, From: "todo@localhost", To: "alice@localhost"}
+	r := Reminder{User: "alice", Task: store.Task{Title: "Dentist"}, Due: time.Now()}
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	msg := <-messages
+	if !strings.Contains(msg, "Subject: Reminder for alice: Dentist\r\n") || !strings.Contains(msg, `"Dentist" is due now.`) {
+		t.Errorf("expected a reminder of the dentist, got %q", msg)
+	}
+}
+
+func TestSMTPNotifierGivesUp(t *testing.T) {
+	// A server that never answers.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	n := SMTPNotifier{Addr: l.Addr().String(), From: "todo@localhost", To: "alice@localhost"}
+	start := time.Now()
+	if err := n.Notify(ctx, Reminder{Task: store.Task{Title: "Dentist"}, Due: time.Now()}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the notifier to give up with its context, took %s", elapsed)
+	}
+}
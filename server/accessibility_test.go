@@ -0,0 +1,232 @@
+package server
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+var (
+	tagPattern       = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)((?:\s+[a-zA-Z-:]+(?:\s*=\s*"[^"]*")?)*)\s*/?>`)
+	attrPattern      = regexp.MustCompile(`([a-zA-Z-:]+)(?:\s*=\s*"([^"]*)")?`)
+	labelPattern     = regexp.MustCompile(`(?s)<label\b[^>]*>(.*?)</label>`)
+	buttonPattern    = regexp.MustCompile(`(?s)<button\b([^>]*)>(.*?)</button>`)
+	markupPattern    = regexp.MustCompile(`<[^>]*>`)
+	ariaHidden       = regexp.MustCompile(`aria-hidden="true"`)
+	labelledElements = map[string]bool{"input": true, "select": true, "textarea": true}
+)
+
+type element struct {
+	tag   string
+	attrs map[string]string
+}
+
+func elements(doc string) []element {
+	var found []element
+	for _, m := range tagPattern.FindAllStringSubmatch(doc, -1) {
+		e := element{tag: strings.ToLower(m[1]), attrs: map[string]string{}}
+		for _, a := range attrPattern.FindAllStringSubmatch(m[2], -1) {
+			e.attrs[strings.ToLower(a[1])] = html.UnescapeString(a[2])
+		}
+		found = append(found, e)
+	}
+	return found
+}
+
+func text(markup string) string {
+	return strings.TrimSpace(html.UnescapeString(markupPattern.ReplaceAllString(markup, "")))
+}
+
+// checkAccessibility reports the problems a screen reader or keyboard user
+// would run into in doc: fields without a label pointing at them or an ARIA
+// name, buttons without a name, empty labels, duplicate IDs and references
+// to missing elements.
+func checkAccessibility(t *testing.T, name, doc string) {
+	t.Helper()
+	all := elements(doc)
+
+	ids := map[string]bool{}
+	for _, e := range all {
+		if id, ok := e.attrs["id"]; ok {
+			if ids[id] {
+				t.Errorf("%s: duplicate id %q", name, id)
+			}
+			ids[id] = true
+		}
+	}
+
+	labelled := map[string]bool{}
+	for _, e := range all {
+		if e.tag == "label" && e.attrs["for"] != "" {
+			labelled[e.attrs["for"]] = true
+		}
+		for _, attr := range []string{"for", "form", "aria-labelledby", "aria-describedby"} {
+			for _, ref := range strings.Fields(e.attrs[attr]) {
+				if !ids[ref] {
+					t.Errorf("%s: <%s %s=%q> refers to a missing element", name, e.tag, attr, ref)
+				}
+			}
+		}
+		if e.tag == "html" && e.attrs["lang"] == "" {
+			t.Errorf("%s: <html> has no lang", name)
+		}
+		if _, ok := e.attrs["alt"]; e.tag == "img" && !ok {
+			t.Errorf("%s: <img> has no alt", name)
+		}
+	}
+
+	for _, e := range all {
+		if !labelledElements[e.tag] {
+			continue
+		}
+		switch e.attrs["type"] {
+		case "hidden", "submit", "button":
+			continue
+		}
+		if e.attrs["aria-label"] == "" && e.attrs["aria-labelledby"] == "" && !labelled[e.attrs["id"]] {
+			t.Errorf("%s: <%s name=%q> has no label", name, e.tag, e.attrs["name"])
+		}
+	}
+
+	for _, m := range labelPattern.FindAllStringSubmatch(doc, -1) {
+		if text(m[1]) == "" {
+			t.Errorf("%s: empty <label>", name)
+		}
+	}
+	for _, m := range buttonPattern.FindAllStringSubmatch(doc, -1) {
+		if ariaHidden.MatchString(m[1]) {
+			continue
+		}
+		if text(m[2]) == "" && !strings.Contains(m[1], `aria-label="`) {
+			t.Errorf("%s: button %s has no name", name, m[1])
+		}
+	}
+}
+
+func TestAccessibility(t *testing.T) {
+	c := store.Config{LoadFromFile: false}
+	s, _ := store.NewInMemoryStore(c)
+	h := NewTaskServer(s).Handler()
+	for _, title := range []string{"Write report", "Call Alice"} {
+		if err := s.AddItem(uuid.New(), title, store.Medium); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	tasks, _ := s.GetAllItems()
+	if err := s.ToggleDone(tasks[1].ID); err != nil {
+		t.Fatalf("Error toggling task: %s", err)
+	}
+
+	get := func(path, fragment string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(fragmentHeader, fragment)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status %d, got %d", path, http.StatusOK, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	list := uuid.New()
+	if err := s.CreateList(list, "Work"); err != nil {
+		t.Fatalf("Error creating list: %s", err)
+	}
+	if err := s.SetList(tasks[0].ID, list); err != nil {
+		t.Fatalf("Error moving task: %s", err)
+	}
+
+	subtask := uuid.New()
+	if err := s.AddItem(subtask, "Draft outline", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	if err := s.SetParent(subtask, tasks[0].ID); err != nil {
+		t.Fatalf("Error nesting task: %s", err)
+	}
+	if err := s.AddBlocker(tasks[0].ID, subtask); err != nil {
+		t.Fatalf("Error adding blocker: %s", err)
+	}
+	if err := s.SetRepeat(tasks[1].ID, "weekly on mon,thu"); err != nil {
+		t.Fatalf("Error repeating task: %s", err)
+	}
+	trashed := uuid.New()
+	if err := s.AddItem(trashed, "Old idea", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	if err := s.DeleteItem(trashed); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+	archived := uuid.New()
+	if err := s.AddItem(archived, "Last year's taxes", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	if err := s.ToggleDone(archived); err != nil {
+		t.Fatalf("Error toggling task: %s", err)
+	}
+	if err := s.ArchiveTask(archived, true); err != nil {
+		t.Fatalf("Error archiving task: %s", err)
+	}
+	start := time.Now().Add(-2 * time.Hour)
+	if err := s.AddTimeEntry(store.TimeEntry{ID: uuid.New(), Task: archived, Start: start, End: start.Add(time.Hour)}); err != nil {
+		t.Fatalf("Error adding time entry: %s", err)
+	}
+	if err := s.StartTimer(uuid.New(), subtask); err != nil {
+		t.Fatalf("Error starting timer: %s", err)
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		for _, path := range []string{"/", "/?q=nothing", "/?sort=title&status=done", "/?list=inbox", "/?list=" + list.String(), "/?status=archived", "/trash", "/time", "/time?by=day"} {
+			checkAccessibility(t, path, get(path, ""))
+		}
+	})
+
+	t.Run("list fragment", func(t *testing.T) {
+		checkAccessibility(t, "list", get("/", "list"))
+	})
+
+	t.Run("invalid row", func(t *testing.T) {
+		form := url.Values{"ID": {tasks[0].ID.String()}, "title": {""}, "priority": {"Urgent"}, "due": {"soon"}, csrfField: {csrfToken("secret")}}
+		req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+		}
+		checkAccessibility(t, "invalid row", rec.Body.String())
+	})
+
+	t.Run("keyboard shortcuts", func(t *testing.T) {
+		body := get("/", "")
+		for _, key := range []string{"/", "n", "e", "x"} {
+			if !strings.Contains(body, `aria-keyshortcuts="`+key+`"`) {
+				t.Errorf("expected a control with shortcut %q", key)
+			}
+		}
+		if !strings.Contains(body, `aria-label="Mark Call Alice as to do"`) || !strings.Contains(body, `aria-label="Delete Write report"`) {
+			t.Errorf("expected buttons named after their task, got %s", body)
+		}
+	})
+
+	t.Run("account pages", func(t *testing.T) {
+		accounts, _ := store.NewInMemoryAccounts(c)
+		stores := store.NewRegistry(func(user string) (store.Store, error) {
+			return store.NewInMemoryStore(c)
+		})
+		h := NewMultiUserTaskServer(s, accounts, stores).Handler()
+		for _, path := range []string{"/login", "/register"} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			checkAccessibility(t, path, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,411 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// storeHandler serves a request against the store of the requesting user.
+type storeHandler func(w http.ResponseWriter, r *http.Request, st store.Store)
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, store.ErrTaskNotFound), errors.Is(err, store.ErrListNotFound), errors.Is(err, store.ErrEntryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, store.ErrInvalidTag), errors.Is(err, store.ErrInvalidList), errors.Is(err, store.ErrInvalidParent),
+		errors.Is(err, store.ErrDependencyCycle), errors.Is(err, store.ErrInvalidRecurrence), errors.Is(err, store.ErrInvalidEntry):
+		status = http.StatusBadRequest
+	case errors.Is(err, store.ErrTaskExists), errors.Is(err, store.ErrEntryExists), errors.Is(err, store.ErrListExists), errors.Is(err, store.ErrBlocked), errors.Is(err, store.ErrNotDone), errors.Is(err, store.ErrNothingToUndo),
+		errors.Is(err, store.ErrNothingToRedo), errors.Is(err, store.ErrUndoConflict), errors.Is(err, store.ErrTimerRunning), errors.Is(err, store.ErrNoTimer):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+// single serves h with the store of a single-user server. Without a login
+// to check, changes must be sent as JSON: browsers don't send that cross-site
+// without asking the server first, so other sites can't make them.
+func (s *TaskServer) single(h storeHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, apiError{Error: "changes must be sent as application/json"})
+				return
+			}
+		}
+		h(w, r, s.store)
+	}
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid task ID"})
+		return uuid.UUID{}, false
+	}
+	return id, true
+}
+
+func findTask(s store.Store, id uuid.UUID) (store.Task, error) {
+	tasks, err := s.GetAllItems()
+	if err != nil {
+		return store.Task{}, err
+	}
+	for _, task := range tasks {
+		if task.ID == id {
+			return task, nil
+		}
+	}
+	return store.Task{}, store.ErrTaskNotFound
+}
+
+// apiListTasks lists the tasks, searched, filtered and sorted with the
+// query parameters of the web page: q, priority, status, tag, list and sort.
+// Under /lists/{list} it lists the tasks of that list.
+func (s *TaskServer) apiListTasks(w http.ResponseWriter, r *http.Request, st store.Store) {
+	tasks, err := st.GetAllItems()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q := parseQuery(r.URL.Query())
+	q.PageSize = 0
+	if r.PathValue("list") != "" {
+		l, ok := pathList(w, r, st)
+		if !ok {
+			return
+		}
+		q.List = store.ListKey(l.ID)
+	}
+	tasks, _ = q.Run(tasks)
+	if tasks == nil {
+		tasks = []store.Task{}
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *TaskServer) apiGetTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	task, err := findTask(st, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// apiAddTask adds a task, to the list given as List or under
+// /lists/{list}. A subtask, given a Parent, goes to its parent's list unless
+// told otherwise.
+func (s *TaskServer) apiAddTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	var task store.Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if r.PathValue("list") != "" {
+		l, ok := pathList(w, r, st)
+		if !ok {
+			return
+		}
+		task.List = l.ID
+	}
+	task.Tags = normalizeTags(task.Tags)
+	if errs := validateTask(task); errs != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errs.Error()})
+		return
+	}
+	if task.ID == uuid.Nil {
+		task.ID = uuid.New()
+	}
+	task.Done = false
+	task.Due = dateOnly(task.Due)
+	task.Repeat, _ = store.NormalizeRecurrence(task.Repeat)
+	if task.Parent != uuid.Nil {
+		parent, err := findTask(st, task.Parent)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		task.List = cmp.Or(task.List, parent.List)
+	}
+
+	var err error
+	if ops := addOperations(task); len(ops) == 1 {
+		err = st.AddItem(task.ID, task.Title, task.Priority)
+	} else {
+		err = st.Batch(ops)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, task)
+}
+
+func (s *TaskServer) apiEditTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Title       *string         `json:"Title"`
+		Priority    *store.Priority `json:"Priority"`
+		Description *string         `json:"Description"`
+		// Due is the task's due date, null or "" to clear it.
+		Due  json.RawMessage `json:"Due"`
+		Tags *[]string       `json:"Tags"`
+		// AutoComplete completes the task with its subtasks.
+		AutoComplete *bool `json:"AutoComplete"`
+		// Repeat is the task's recurrence rule, "" to stop it repeating.
+		Repeat *string `json:"Repeat"`
+		// Archived archives the task once it is done, or unarchives it.
+		Archived *bool `json:"Archived"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	task, err := findTask(st, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Title != nil {
+		task.Title = *body.Title
+	}
+	if body.Priority != nil {
+		task.Priority = *body.Priority
+	}
+	if body.Description != nil {
+		task.Description = *body.Description
+	}
+	if body.Due != nil {
+		if task.Due, err = parseDue(body.Due); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "Due must be an RFC 3339 time, or null to clear it"})
+			return
+		}
+	}
+	tags, rule := task.Tags, task.Repeat
+	if body.Tags != nil {
+		task.Tags = normalizeTags(*body.Tags)
+	}
+	if body.Repeat != nil {
+		task.Repeat = *body.Repeat
+	}
+	if errs := validateTask(task); errs != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errs.Error()})
+		return
+	}
+	ops := append([]store.TaskOperation{store.UpdateOperation(task)}, tagOperations(id, tags, task.Tags)...)
+	if body.AutoComplete != nil && *body.AutoComplete != task.AutoComplete {
+		ops = append(ops, store.TaskOperation{Type: "SetAutoComplete", ID: id, AutoComplete: *body.AutoComplete})
+	}
+	if task.Repeat, _ = store.NormalizeRecurrence(task.Repeat); task.Repeat != rule {
+		ops = append(ops, store.TaskOperation{Type: "SetRepeat", ID: id, Repeat: task.Repeat})
+	}
+	if body.Archived != nil && *body.Archived == task.Archived.IsZero() {
+		ops = append(ops, store.TaskOperation{Type: "Archive", ID: id, Archived: *body.Archived})
+	}
+	if len(ops) > 1 {
+		err = st.Batch(ops)
+	} else {
+		err = st.UpdateTask(task)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseDue reads the Due of an edit: a time, or null or "" for no due date.
+func parseDue(raw json.RawMessage) (time.Time, error) {
+	var v *string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return time.Time{}, err
+	}
+	if v == nil || *v == "" {
+		return time.Time{}, nil
+	}
+	due, err := time.Parse(time.RFC3339, *v)
+	return dateOnly(due), err
+}
+
+func (s *TaskServer) apiToggleTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := st.ToggleDone(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TaskServer) apiTagTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Tag string `json:"Tag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if err := st.AddTag(id, body.Tag); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TaskServer) apiUntagTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := st.RemoveTag(id, r.PathValue("tag")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiSetParent makes a task a subtask of the task given as Parent, or a
+// top-level task without one.
+func (s *TaskServer) apiSetParent(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Parent uuid.UUID `json:"Parent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if err := st.SetParent(id, body.Parent); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiMoveTask moves a task just before the task given as Before, or to the
+// end of the list without one.
+func (s *TaskServer) apiMoveTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Before uuid.UUID `json:"Before"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if err := st.MoveTask(id, body.Before); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiDeleteTask deletes a task, moving its subtasks up to its parent, or
+// deleting them too given subtasks=delete.
+func (s *TaskServer) apiDeleteTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	if r.URL.Query().Get("subtasks") == "delete" {
+		err = st.DeleteTree(id)
+	} else {
+		err = st.DeleteItem(id)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validOperation(op store.TaskOperation) bool {
+	switch op.Type {
+	case "Add":
+		return op.Title != "" && op.Priority.Valid()
+	case "Edit":
+		return op.Title != ""
+	case "SetPriority":
+		return op.Priority.Valid()
+	case "Update":
+		return validateTask(store.Task{Title: op.Title, Priority: op.Priority, Description: op.Description}) == nil
+	case "AddTag", "RemoveTag":
+		_, ok := store.NormalizeTag(op.Tag)
+		return ok
+	case "SetRepeat":
+		_, ok := store.NormalizeRecurrence(op.Repeat)
+		return ok
+	case "CreateList", "RenameList":
+		_, ok := store.NormalizeListName(op.Title)
+		return ok
+	case "Delete", "ToggleDone", "Move", "SetList", "ArchiveList", "DeleteList", "SetParent", "SetAutoComplete", "DeleteTree",
+		"AddBlocker", "RemoveBlocker", "Restore", "Purge", "EmptyTrash", "Archive", "ArchiveDone", "StartTimer", "StopTimer",
+		"AddTimeEntry", "EditTimeEntry", "DeleteTimeEntry":
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *TaskServer) apiBatch(w http.ResponseWriter, r *http.Request, st store.Store) {
+	var ops []store.TaskOperation
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	for i, op := range ops {
+		if !validOperation(op) {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("operation %d (%s) is invalid", i+1, op.Type)})
+			return
+		}
+	}
+	if err := st.Batch(ops); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// apiArchiveDone archives the tasks completed before the "before" time, all
+// the done tasks if it isn't given.
+func (s *TaskServer) apiArchiveDone(w http.ResponseWriter, r *http.Request, st store.Store) {
+	before := time.Now()
+	if v := r.URL.Query().Get("before"); v != "" {
+		var err error
+		if before, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "before must be an RFC 3339 time"})
+			return
+		}
+	}
+	if err := st.ArchiveDone(before); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiUnarchive unarchives the archived tasks matching the query parameters
+// of apiListTasks in one change.
+func (s *TaskServer) apiUnarchive(w http.ResponseWriter, r *http.Request, st store.Store) {
+	tasks, err := st.GetAllItems()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if ops := unarchiveOperations(tasks, parseQuery(r.URL.Query())); len(ops) > 0 {
+		if err := st.Batch(ops); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unarchiveOperations returns the operations unarchiving the archived tasks
+// among tasks that match q, on any page.
+func unarchiveOperations(tasks []store.Task, q store.Query) []store.TaskOperation {
+	q.Status, q.PageSize = "archived", 0
+	tasks, _ = q.Run(tasks)
+	var ops []store.TaskOperation
+	for _, t := range tasks {
+		ops = append(ops, store.TaskOperation{Type: "Archive", ID: t.ID})
+	}
+	return ops
+}
+
+// archive archives a done task from the web page or, with archived set to
+// false, unarchives it.
+func (s *TaskServer) archive(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
+
+	taskID, err := ParseID(r)
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	archived := r.PostFormValue("archived") != "false"
+	recorded, err := s.change(r, store.TaskOperation{Type: "Archive", ID: taskID, Archived: archived})
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error archiving task"))
+		return
+	}
+
+	if archived {
+		s.succeeded(w, r, taskID, "Task archived", recorded)
+	} else {
+		s.succeeded(w, r, taskID, "Task unarchived", recorded)
+	}
+}
+
+// unarchiveAll unarchives the archived tasks matching the filters of the
+// page, on every page of it.
+func (s *TaskServer) unarchiveAll(w http.ResponseWriter, r *http.Request) {
+	st := s.storeFor(r)
+	q := parseQuery(r.URL.Query())
+	tasks, err := st.GetAllItems()
+	if err == nil {
+		// As on the page, archived lists only show when selected.
+		var lists []store.List
+		if lists, err = st.GetLists(); err == nil && q.List == "" {
+			tasks = store.WithoutArchived(tasks, lists)
+		}
+	}
+	ops := unarchiveOperations(tasks, q)
+	var recorded bool
+	if err == nil && len(ops) > 0 {
+		recorded, err = s.change(r, ops...)
+	}
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error unarchiving tasks"))
+		return
+	}
+
+	message := "No archived tasks to unarchive"
+	switch len(ops) {
+	case 0:
+		s.flashed(w, r, uuid.Nil, flash{Kind: "success", Message: message})
+		return
+	case 1:
+		message = "Task unarchived"
+	default:
+		message = fmt.Sprintf("%d tasks unarchived", len(ops))
+	}
+	s.succeeded(w, r, uuid.Nil, message, recorded)
+}
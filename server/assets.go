@@ -0,0 +1,128 @@
+package server
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"todoapp/store"
+)
+
+//go:embed templates static
+var embedded embed.FS
+
+const templatePattern = "templates/*.html"
+
+var templateFuncs = template.FuncMap{
+	"priorities": func() []store.Priority { return []store.Priority{store.High, store.Medium, store.Low} },
+	"inc":        func(n int) int { return n + 1 },
+	"tags":       func(tags []string) string { return strings.Join(tags, " ") },
+	"dec":        func(n int) int { return n - 1 },
+	"listKey":    store.ListKey,
+	"duration":   store.FormatDuration,
+	// repeat describes a recurrence rule in words, leaving invalid rules as
+	// they were typed.
+	"repeat": func(rule string) string {
+		r, err := store.ParseRecurrence(rule)
+		if rule == "" || err != nil {
+			return rule
+		}
+		return r.Describe()
+	},
+	// indent caps how far subtasks are indented.
+	"indent": func(depth int) int { return min(depth, 5) },
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.DateOnly)
+	},
+}
+
+func parseTemplates(files fs.FS) (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs).ParseFS(files, templatePattern)
+}
+
+// assets holds the page templates and static files. Embedded assets are
+// parsed once; assets read from a directory are parsed again whenever a
+// template file changes on disk.
+type assets struct {
+	files  fs.FS
+	reload bool
+
+	mu       sync.Mutex
+	tmpl     *template.Template
+	modified time.Time
+}
+
+var embeddedAssets = &assets{
+	files: embedded,
+	tmpl:  template.Must(parseTemplates(embedded)),
+}
+
+func loadAssets(dir string) (*assets, error) {
+	a := &assets{files: os.DirFS(dir), reload: true}
+	if _, err := a.template(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *assets) template() (*template.Template, error) {
+	if !a.reload {
+		return a.tmpl, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	modified, err := a.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if a.tmpl != nil && !modified.After(a.modified) {
+		return a.tmpl, nil
+	}
+	tmpl, err := parseTemplates(a.files)
+	if err != nil {
+		return nil, err
+	}
+	a.tmpl, a.modified = tmpl, modified
+	return tmpl, nil
+}
+
+func (a *assets) lastModified() (time.Time, error) {
+	names, err := fs.Glob(a.files, templatePattern)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var latest time.Time
+	for _, name := range names {
+		info, err := fs.Stat(a.files, name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (a *assets) static() http.Handler {
+	files, err := fs.Sub(a.files, "static")
+	if err != nil {
+		panic(err)
+	}
+	h := http.StripPrefix("/static/", http.FileServerFS(files))
+	if !a.reload {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"todoapp/store"
+)
+
+const (
+	passwordIterations = 600_000
+	tokenLifetime      = 30 * 24 * time.Hour
+)
+
+var userNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+var errInvalidCredentials = errors.New("invalid user name or password")
+
+// dummyHash is checked for unknown users, so that logging in as them takes as
+// long as for a user that exists.
+var dummyHash = fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+	base64.RawStdEncoding.EncodeToString(make([]byte, 16)), base64.RawStdEncoding.EncodeToString(make([]byte, sha256.Size)))
+
+// hashPassword returns a PBKDF2-SHA256 hash of password encoded as
+// "pbkdf2-sha256$iterations$salt$hash".
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+func (s *TaskServer) register(name, password string) error {
+	if !userNamePattern.MatchString(name) {
+		return errors.New("user name must be 1-32 letters, digits, '.', '_' or '-'")
+	}
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.accounts.AddUser(store.User{Name: name, PasswordHash: hash})
+}
+
+func (s *TaskServer) login(name, password string, lifetime time.Duration) (store.Session, error) {
+	user, err := s.accounts.GetUser(name)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			checkPassword(dummyHash, password)
+			return store.Session{}, errInvalidCredentials
+		}
+		return store.Session{}, err
+	}
+	if !checkPassword(user.PasswordHash, password) {
+		return store.Session{}, errInvalidCredentials
+	}
+
+	session := store.Session{
+		Token:   rand.Text(),
+		User:    user.Name,
+		Expires: time.Now().Add(lifetime).UTC(),
+	}
+	return session, s.accounts.AddSession(session)
+}
+
+type credentials struct {
+	Name     string `json:"Name"`
+	Password string `json:"Password"`
+}
+
+func (s *TaskServer) apiRegister(w http.ResponseWriter, r *http.Request) {
+	var body credentials
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	err := s.register(body.Name, body.Password)
+	switch {
+	case errors.Is(err, store.ErrUserExists):
+		writeJSON(w, http.StatusConflict, apiError{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusCreated, map[string]string{"Name": body.Name})
+	}
+}
+
+func (s *TaskServer) apiLogin(w http.ResponseWriter, r *http.Request) {
+	var body credentials
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	session, err := s.login(body.Name, body.Password, tokenLifetime)
+	if errors.Is(err, errInvalidCredentials) {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (s *TaskServer) apiLogout(w http.ResponseWriter, r *http.Request, _ store.Store) {
+	if err := s.accounts.DeleteSession(bearerToken(r)); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// authorized serves h with the store of the user owning the request's bearer
+// token.
+func (s *TaskServer) authorized(h storeHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.accounts.GetSession(bearerToken(r))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "not logged in"})
+			return
+		}
+		st, err := s.stores.ForUser(session.User)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		h(w, r, st)
+	}
+}
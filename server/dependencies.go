@@ -0,0 +1,102 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+func (s *TaskServer) apiAddBlocker(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Blocker uuid.UUID `json:"Blocker"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if err := st.AddBlocker(id, body.Blocker); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TaskServer) apiRemoveBlocker(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	blocker, err := uuid.Parse(r.PathValue("blocker"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid blocker ID"})
+		return
+	}
+	if err := st.RemoveBlocker(id, blocker); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// block makes a task wait on another one, or with blocked=false stop
+// waiting on it.
+func (s *TaskServer) block(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
+
+	taskID, err := ParseID(r)
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+	blocker, err := uuid.Parse(r.PostFormValue("blocker"))
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid blocking task")
+		return
+	}
+
+	blocked := r.PostFormValue("blocked") != "false"
+	op := store.TaskOperation{Type: "AddBlocker", ID: taskID, Blocker: blocker}
+	if !blocked {
+		op.Type = "RemoveBlocker"
+	}
+	recorded, err := s.change(r, op)
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error changing blockers"))
+		return
+	}
+
+	message := "Task blocked"
+	if !blocked {
+		message = "Task unblocked"
+	}
+	s.succeeded(w, r, taskID, message, recorded)
+}
+
+// blockers returns the tasks t waits on, and the open tasks among listed it
+// could wait on without creating a cycle.
+func blockers(tasks, listed []store.Task, t store.Task) (current, candidates []store.Task) {
+	for _, other := range tasks {
+		if slices.Contains(t.BlockedBy, other.ID) {
+			current = append(current, other)
+		}
+	}
+	blocking := store.Blocking(tasks, t.ID)
+	for _, other := range listed {
+		if !other.Done && other.ID != t.ID && !slices.Contains(t.BlockedBy, other.ID) && !slices.Contains(blocking, other.ID) {
+			candidates = append(candidates, other)
+		}
+	}
+	return current, candidates
+}
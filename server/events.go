@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"todoapp/store"
+)
+
+const heartbeatInterval = 30 * time.Second
+
+// events streams store changes to the page as Server-Sent Events. Clients
+// reconnecting with Last-Event-ID get the events they missed, or a "reset"
+// event when those are no longer available.
+func (s *TaskServer) events(w http.ResponseWriter, r *http.Request) {
+	notifier, ok := s.storeFor(r).(store.Notifier)
+	if !ok {
+		http.Error(w, "Live updates are not supported", http.StatusNotImplemented)
+		return
+	}
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	sub := notifier.Events().Subscribe(lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+
+	if sub.Reset {
+		writeEvent(w, "reset", sub.Last, struct{}{})
+	}
+	for _, e := range sub.Missed {
+		writeEvent(w, "change", e.ID, e)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			writeEvent(w, "change", e.ID, e)
+		case <-heartbeat.C:
+			io.WriteString(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w io.Writer, name string, id uint64, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, name, b)
+}
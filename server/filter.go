@@ -0,0 +1,138 @@
+package server
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+const pageSize = 20
+
+// parseQuery reads the list, search, filters, sort order and page of the
+// tasks page from its URL, ignoring values it doesn't recognise.
+func parseQuery(v url.Values) store.Query {
+	q := store.Query{Search: strings.TrimSpace(v.Get("q")), Page: 1, PageSize: pageSize}
+	if list := v.Get("list"); list == store.Inbox {
+		q.List = list
+	} else if id, err := uuid.Parse(list); err == nil {
+		q.List = store.ListKey(id)
+	}
+	if p := store.Priority(v.Get("priority")); p.Valid() {
+		q.Priority = p
+	}
+	if status := v.Get("status"); slices.Contains([]string{"open", "done", "ready", "blocked", "archived"}, status) {
+		q.Status = status
+	}
+	for _, tag := range normalizeTags(v["tag"]) {
+		if _, ok := store.NormalizeTag(tag); ok {
+			q.Tags = append(q.Tags, tag)
+		}
+	}
+	if sort := v.Get("sort"); store.ValidSort(sort) {
+		q.Sort = sort
+	}
+	if n, err := strconv.Atoi(v.Get("page")); err == nil && n > 0 {
+		q.Page = n
+	}
+	return q
+}
+
+func queryValues(q store.Query) url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("list", q.List)
+	set("q", q.Search)
+	set("priority", string(q.Priority))
+	set("status", q.Status)
+	for _, tag := range q.Tags {
+		v.Add("tag", tag)
+	}
+	set("sort", q.Sort)
+	if q.Page > 1 {
+		v.Set("page", strconv.Itoa(q.Page))
+	}
+	return v
+}
+
+func link(v url.Values) string {
+	if len(v) == 0 {
+		return "/"
+	}
+	return "/?" + v.Encode()
+}
+
+// SortLink links to the page sorted by column, reversing the order when it
+// is already sorted by it.
+func (p page) SortLink(column string) string {
+	v := queryValues(p.Filter)
+	v.Del("page")
+	if p.Filter.Sort == column {
+		column = "-" + column
+	}
+	v.Set("sort", column)
+	return link(v)
+}
+
+// SortOrder is the aria-sort value of column.
+func (p page) SortOrder(column string) string {
+	switch p.Filter.Sort {
+	case column:
+		return "ascending"
+	case "-" + column:
+		return "descending"
+	}
+	return "none"
+}
+
+func (p page) PageLink(n int) string {
+	q := p.Filter
+	q.Page = n
+	return link(queryValues(q))
+}
+
+// TagLink links to the tasks having tag, on top of the current filters.
+func (p page) TagLink(tag string) string {
+	q := p.Filter
+	q.Page = 1
+	q.Tags = normalizeTags(append(slices.Clone(q.Tags), tag))
+	return link(queryValues(q))
+}
+
+// UntagLink links to the page without the tag filter tag.
+func (p page) UntagLink(tag string) string {
+	q := p.Filter
+	q.Page = 1
+	q.Tags = slices.DeleteFunc(slices.Clone(q.Tags), func(t string) bool { return t == tag })
+	return link(queryValues(q))
+}
+
+func (p page) TagSelected(tag string) bool {
+	return slices.Contains(p.Filter.Tags, tag)
+}
+
+// ListLink links to the list with the List filter key, "" for all the
+// tasks, keeping the other filters.
+func (p page) ListLink(key string) string {
+	q := p.Filter
+	q.Page = 1
+	q.List = key
+	return link(queryValues(q))
+}
+
+// ClearLink links to the list without search or filters, keeping the order.
+func (p page) ClearLink() string {
+	return link(queryValues(store.Query{List: p.Filter.List, Sort: p.Filter.Sort}))
+}
+
+func (p page) Filtered() bool {
+	q := p.Filter
+	return q.Search != "" || q.Priority != "" || q.Status != "" || len(q.Tags) > 0
+}
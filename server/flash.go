@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+const flashCookie = "flash"
+
+// flash is a message shown once on the page a form submission redirects to.
+// Action is "undo" or "redo" when it offers to undo the change or redo it.
+type flash struct {
+	Kind    string
+	Message string
+	Action  string `json:",omitempty"`
+}
+
+func setFlash(w http.ResponseWriter, f flash) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// takeFlash returns the pending flash message, if any, and clears it.
+func takeFlash(w http.ResponseWriter, r *http.Request) *flash {
+	c, err := r.Cookie(flashCookie)
+	if err != nil {
+		return nil
+	}
+	http.SetCookie(w, &http.Cookie{Name: flashCookie, Path: "/", MaxAge: -1})
+
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return nil
+	}
+	var f flash
+	if err := json.Unmarshal(b, &f); err != nil || f.Message == "" {
+		return nil
+	}
+	if f.Kind != "success" {
+		f.Kind = "error"
+	}
+	return &f
+}
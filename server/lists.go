@@ -0,0 +1,240 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// findList returns the list named by key: its ID, its name, or "inbox" for
+// the zero List.
+func findList(st store.Store, key string) (store.List, error) {
+	if key == store.Inbox {
+		return store.List{}, nil
+	}
+	lists, err := st.GetLists()
+	if err != nil {
+		return store.List{}, err
+	}
+	l, ok := store.FindList(lists, key)
+	if !ok {
+		return store.List{}, store.ErrListNotFound
+	}
+	return l, nil
+}
+
+// pathList resolves the {list} path value, writing the error response when
+// there is no such list.
+func pathList(w http.ResponseWriter, r *http.Request, st store.Store) (store.List, bool) {
+	l, err := findList(st, r.PathValue("list"))
+	if err != nil {
+		writeError(w, err)
+		return store.List{}, false
+	}
+	return l, true
+}
+
+func (s *TaskServer) apiLists(w http.ResponseWriter, r *http.Request, st store.Store) {
+	lists, err := st.GetLists()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if lists == nil {
+		lists = []store.List{}
+	}
+	writeJSON(w, http.StatusOK, lists)
+}
+
+func (s *TaskServer) apiCreateList(w http.ResponseWriter, r *http.Request, st store.Store) {
+	var l store.List
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	l.Name, _ = store.NormalizeListName(l.Name)
+	if err := st.CreateList(l.ID, l.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	if l.Archived {
+		if err := st.ArchiveList(l.ID, true); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusCreated, l)
+}
+
+// apiEditList renames or archives a list, given a Name or Archived.
+func (s *TaskServer) apiEditList(w http.ResponseWriter, r *http.Request, st store.Store) {
+	var body struct {
+		Name     *string `json:"Name"`
+		Archived *bool   `json:"Archived"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	l, ok := pathList(w, r, st)
+	if !ok {
+		return
+	}
+	if l.ID == uuid.Nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "the inbox can't be changed"})
+		return
+	}
+	var ops []store.TaskOperation
+	if body.Name != nil {
+		ops = append(ops, store.TaskOperation{Type: "RenameList", ID: l.ID, Title: *body.Name})
+	}
+	if body.Archived != nil {
+		ops = append(ops, store.TaskOperation{Type: "ArchiveList", ID: l.ID, Archived: *body.Archived})
+	}
+	if err := st.Batch(ops); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiDeleteList deletes a list, moving its tasks to the inbox.
+func (s *TaskServer) apiDeleteList(w http.ResponseWriter, r *http.Request, st store.Store) {
+	l, ok := pathList(w, r, st)
+	if !ok {
+		return
+	}
+	if l.ID == uuid.Nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "the inbox can't be deleted"})
+		return
+	}
+	if err := st.DeleteList(l.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiSetList moves a task to the list given as List, or to the inbox
+// without one.
+func (s *TaskServer) apiSetList(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		List uuid.UUID `json:"List"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if err := st.SetList(id, body.List); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addList creates a list from the web page and shows it.
+func (s *TaskServer) addList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
+
+	id := uuid.New()
+	recorded, err := s.change(r, store.TaskOperation{Type: "CreateList", ID: id, Title: r.PostFormValue("name")})
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error adding list"))
+		return
+	}
+
+	r.URL.RawQuery = url.Values{"list": {id.String()}}.Encode()
+	s.succeeded(w, r, uuid.Nil, "List added", recorded)
+}
+
+func (s *TaskServer) renameList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
+
+	id, err := ParseID(r)
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	recorded, err := s.change(r, store.TaskOperation{Type: "RenameList", ID: id, Title: r.PostFormValue("name")})
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error renaming list"))
+		return
+	}
+
+	s.succeeded(w, r, uuid.Nil, "List renamed", recorded)
+}
+
+// archiveList archives a list, or restores it when "archived" is "false".
+func (s *TaskServer) archiveList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
+
+	id, err := ParseID(r)
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	archived := r.PostFormValue("archived") != "false"
+	recorded, err := s.change(r, store.TaskOperation{Type: "ArchiveList", ID: id, Archived: archived})
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error archiving list"))
+		return
+	}
+
+	message := "List archived"
+	if !archived {
+		message = "List restored"
+	}
+	s.succeeded(w, r, uuid.Nil, message, recorded)
+}
+
+// deleteList deletes a list and goes back to all the tasks, which now
+// include the list's tasks in the inbox.
+func (s *TaskServer) deleteList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
+
+	id, err := ParseID(r)
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	recorded, err := s.change(r, store.TaskOperation{Type: "DeleteList", ID: id})
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error deleting list"))
+		return
+	}
+
+	q := parseQuery(r.URL.Query())
+	q.List = ""
+	r.URL.RawQuery = queryValues(q).Encode()
+	s.succeeded(w, r, uuid.Nil, "List deleted, its tasks are in the inbox", recorded)
+}
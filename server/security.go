@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	csrfCookie = "csrf"
+	csrfField  = "csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+var securityHeaders = map[string]string{
+	"Content-Security-Policy":      "default-src 'self'; object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'",
+	"X-Frame-Options":              "DENY",
+	"X-Content-Type-Options":       "nosniff",
+	"Referrer-Policy":              "same-origin",
+	"Cross-Origin-Opener-Policy":   "same-origin",
+	"Cross-Origin-Resource-Policy": "same-origin",
+}
+
+func withSecurityHeaders(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range securityHeaders {
+			w.Header().Set(name, value)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// csrfSecret returns the secret the CSRF token of r is derived from: the
+// session token of the logged-in user, or else a random value kept in a
+// cookie, which is set if the request doesn't have one yet.
+func csrfSecret(w http.ResponseWriter, r *http.Request) string {
+	if u, ok := r.Context().Value(webUserKey{}).(*webUser); ok {
+		return u.session.Token
+	}
+	if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	secret := rand.Text()
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    secret,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return secret
+}
+
+func csrfToken(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkCSRF serves h only to requests carrying the CSRF token of their
+// session, in the form or the X-CSRF-Token header.
+func checkCSRF(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(csrfHeader)
+		if token == "" {
+			token = r.PostFormValue(csrfField)
+		}
+		expected := csrfToken(csrfSecret(w, r))
+		if !hmac.Equal([]byte(token), []byte(expected)) {
+			http.Error(w, "Invalid or missing CSRF token, reload the page and try again", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
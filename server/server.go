@@ -1,50 +1,356 @@
 package server
 
 import (
-	"html/template"
+	"cmp"
+	"errors"
 	"log"
 	"net/http"
-	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 	"todoapp/store"
 
 	"github.com/google/uuid"
 )
 
 type TaskServer struct {
-	store store.Store
+	store    store.Store
+	accounts store.Accounts
+	stores   *store.Registry
+	assets   *assets
 }
 
 func NewTaskServer(store store.Store) *TaskServer {
-	return &TaskServer{store: store}
+	return &TaskServer{store: store, assets: embeddedAssets}
 }
 
-func LoadTemplate() (*template.Template, error) {
-	tmplPath := filepath.Join("server", "todo_app.html")
+// NewMultiUserTaskServer returns a server that additionally serves the V2 API,
+// which scopes every request to the tasks of the logged-in user.
+func NewMultiUserTaskServer(store store.Store, accounts store.Accounts, stores *store.Registry) *TaskServer {
+	return &TaskServer{store: store, accounts: accounts, stores: stores, assets: embeddedAssets}
+}
 
-	return template.ParseFiles(tmplPath)
+// UseAssetDir serves templates and static files from dir instead of the ones
+// embedded in the binary, reloading templates when they change.
+func (s *TaskServer) UseAssetDir(dir string) error {
+	a, err := loadAssets(dir)
+	if err != nil {
+		return err
+	}
+	s.assets = a
+	return nil
 }
 
-func (s *TaskServer) renderTasksPage(w http.ResponseWriter) {
+// fragmentHeader asks for part of the page instead of all of it: "list" for
+// the task list or "row" for the row of the task the request acted on.
+const fragmentHeader = "X-Fragment"
+
+// flashHeader carries the message a fragment would have been shown with as a
+// page, and flashActionHeader the change it offers to undo or redo.
+const (
+	flashHeader       = "X-Flash"
+	flashActionHeader = "X-Flash-Action"
+)
+
+// page is the data of the tasks page. Query holds the query string of the
+// current view, which forms post back so their redirect returns to it.
+type page struct {
+	Rows   []row
+	Flash  *flash
+	Query  string
+	Filter store.Query
+	Total  int
+	Pages  int
+	User   string
+	CSRF   string
+	// Tags lists every tag in use, for the tag filter.
+	Tags []string
+	// Lists holds the lists tasks can be moved to: those that aren't
+	// archived, and the list shown. List is the list shown, nil for the
+	// inbox or all tasks.
+	Lists []store.List
+	List  *store.List
+}
+
+// row is a task on the page. Prev and Next are the IDs of its neighbours
+// under the same parent among the tasks shown, across pages, which the move
+// buttons place it around; they are only set while the page is in manual
+// order, where subtasks are nested Depth levels under their parent.
+type row struct {
+	store.Task
+	Query   string
+	CSRF    string
+	Errors  fieldErrors
+	Movable bool
+	Prev    string
+	Next    string
+	// ListName names the task's list when all tasks are shown.
+	ListName string
+	Lists    []store.List
+	// Subtasks counts the task's subtasks, SubtasksDone those done.
+	Subtasks     int
+	SubtasksDone int
+	Depth        int
+	ParentTitle  string
+	// Grandparent is the ID of the task the outdent button moves the task
+	// under, "" for the top level.
+	Grandparent string
+	// Blockers are the tasks this one waits on, Candidates the open tasks
+	// among those listed that it could wait on.
+	Blockers   []store.Task
+	Candidates []store.Task
+	Blocked    bool
+	// Spent is the time tracked on the task, Timing whether its timer is
+	// running.
+	Spent  time.Duration
+	Timing bool
+}
 
-	tasks, err := s.store.GetAllItems()
+func (s *TaskServer) renderTasksPage(w http.ResponseWriter, r *http.Request, taskID uuid.UUID, f *flash) {
+	s.renderPage(w, r, http.StatusOK, taskID, f, nil)
+}
+
+// renderPage renders the tasks page, or the fragment of it asked for. An
+// invalid row replaces the stored task it edits, to show the submitted values
+// with their errors.
+func (s *TaskServer) renderPage(w http.ResponseWriter, r *http.Request, status int, taskID uuid.UUID, f *flash, invalid *row) {
+
+	st := s.storeFor(r)
+	tasks, err := st.GetAllItems()
 	if err != nil {
 		log.Println("Error loading tasks")
+		http.Error(w, "Error loading tasks", http.StatusInternalServerError)
+		return
+	}
+	lists, err := st.GetLists()
+	if err != nil {
+		log.Println("Error loading lists")
+		http.Error(w, "Error loading lists", http.StatusInternalServerError)
 		return
 	}
+	entries, err := st.GetTimeEntries()
+	if err != nil {
+		log.Println("Error loading time entries")
+		http.Error(w, "Error loading time entries", http.StatusInternalServerError)
+		return
+	}
+	spent, timing := store.TimeSpent(entries, time.Now()), uuid.Nil
+	if i := store.RunningTimer(entries); i >= 0 {
+		timing = entries[i].Task
+	}
 
-	tmpl, err := LoadTemplate()
+	tmpl, err := s.assets.template()
 	if err != nil {
+		log.Println(err)
 		http.Error(w, "Error loading template", http.StatusInternalServerError)
 		return
 	}
-	err = tmpl.Execute(w, tasks)
+
+	p := page{
+		Flash:  f,
+		Query:  query(r),
+		Filter: parseQuery(r.URL.Query()),
+		User:   userName(r),
+		CSRF:   csrfToken(csrfSecret(w, r)),
+		Tags:   store.AllTags(tasks),
+	}
+	names := map[uuid.UUID]string{}
+	for _, l := range lists {
+		names[l.ID] = l.Name
+		if store.ListKey(l.ID) == p.Filter.List {
+			p.List = &l
+		}
+		if !l.Archived || p.List != nil && p.List.ID == l.ID {
+			p.Lists = append(p.Lists, l)
+		}
+	}
+	stored := tasks
+	if p.Filter.List == "" {
+		tasks = store.WithoutArchived(tasks, lists)
+	}
+	var shown []store.Task
+	shown, p.Total = p.Filter.Run(tasks)
+	p.Pages = max((p.Total+pageSize-1)/pageSize, 1)
+	if p.Filter.Page > p.Pages {
+		p.Filter.Page = p.Pages
+		shown, _ = p.Filter.Run(tasks)
+	}
+	// In manual order subtasks follow their parent, and the move buttons
+	// swap tasks with their neighbours under the same parent.
+	all := p.Filter
+	all.PageSize = 0
+	matched, _ := all.Run(tasks)
+	var nested []store.Task
+	var depths []int
+	first := (p.Filter.Page - 1) * pageSize
+	if p.Filter.Sort == "" {
+		nested, depths = store.Nest(matched)
+		shown = nested[min(first, len(nested)):min(first+pageSize, len(nested))]
+	}
+	for i, t := range shown {
+		rw := row{Task: t, Query: p.Query, CSRF: p.CSRF, Movable: p.Filter.Sort == "", Lists: p.Lists}
+		if p.Filter.List == "" {
+			rw.ListName = names[t.List]
+		}
+		if invalid != nil && invalid.ID == t.ID {
+			rw.Task, rw.Errors = invalid.Task, invalid.Errors
+			rw.Done, rw.Parent, rw.AutoComplete = t.Done, t.Parent, t.AutoComplete
+		}
+		rw.SubtasksDone, rw.Subtasks = store.Progress(tasks, t.ID)
+		rw.Blockers, rw.Candidates = blockers(stored, matched, t)
+		rw.Blocked = store.Blocked(stored, t)
+		rw.Spent, rw.Timing = spent[t.ID], t.ID == timing
+		if rw.Movable {
+			rw.Depth = depths[first+i]
+			rw.Prev, rw.Next = siblings(nested, depths, first+i)
+		}
+		if rw.Depth > 0 {
+			parent := nested[slices.IndexFunc(nested, func(n store.Task) bool { return n.ID == t.Parent })]
+			rw.ParentTitle = parent.Title
+			if parent.Parent != uuid.Nil {
+				rw.Grandparent = parent.Parent.String()
+			}
+		}
+		p.Rows = append(p.Rows, rw)
+	}
+
+	w.Header().Set("Vary", fragmentHeader)
+	name, data := "todo_app.html", any(p)
+	switch r.Header.Get(fragmentHeader) {
+	case "list":
+		name = "task-list"
+	case "row":
+		i := slices.IndexFunc(p.Rows, func(r row) bool { return r.ID == taskID })
+		if i < 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		name, data = "task-row", p.Rows[i]
+	}
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	err = tmpl.ExecuteTemplate(w, name, data)
 	if err != nil {
 		http.Error(w, "Error rendering tasks", http.StatusInternalServerError)
 	}
 }
 
+// siblings returns the IDs of the tasks before and after nested[i] at the
+// same depth under the same parent, "" where there is none.
+func siblings(nested []store.Task, depths []int, i int) (prev, next string) {
+	for j := i - 1; j >= 0 && depths[j] >= depths[i]; j-- {
+		if depths[j] == depths[i] {
+			prev = nested[j].ID.String()
+			break
+		}
+	}
+	for j := i + 1; j < len(nested) && depths[j] >= depths[i]; j++ {
+		if depths[j] == depths[i] {
+			next = nested[j].ID.String()
+			break
+		}
+	}
+	return prev, next
+}
+
+func query(r *http.Request) string {
+	if r.URL.RawQuery == "" {
+		return ""
+	}
+	return "?" + r.URL.RawQuery
+}
+
+// change performs ops on the store of r, reporting whether Undo reverts
+// them. Stores that can't tell offer no undo.
+func (s *TaskServer) change(r *http.Request, ops ...store.TaskOperation) (bool, error) {
+	st := s.storeFor(r)
+	if recorder, ok := st.(store.Recorder); ok {
+		return recorder.Record(ops...)
+	}
+	if len(ops) == 1 {
+		return false, store.Apply(st, ops[0])
+	}
+	return false, st.Batch(ops)
+}
+
+// succeeded finishes a form submission that changed the tasks, offering to
+// undo the change when it was recorded.
+func (s *TaskServer) succeeded(w http.ResponseWriter, r *http.Request, taskID uuid.UUID, message string, recorded bool) {
+	f := flash{Kind: "success", Message: message}
+	if recorded {
+		f.Action = "undo"
+	}
+	s.flashed(w, r, taskID, f)
+}
+
+// flashed finishes a form submission with f. Fragment requests get the
+// changed part of the page; other requests are redirected back to the list
+// so that reloading the page doesn't submit the form again.
+func (s *TaskServer) flashed(w http.ResponseWriter, r *http.Request, taskID uuid.UUID, f flash) {
+	if r.Header.Get(fragmentHeader) != "" {
+		w.Header().Set(flashHeader, f.Message)
+		w.Header().Set(flashActionHeader, f.Action)
+		s.renderTasksPage(w, r, taskID, nil)
+		return
+	}
+	setFlash(w, f)
+	http.Redirect(w, r, "/"+query(r), http.StatusSeeOther)
+}
+
+func (s *TaskServer) failed(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if r.Header.Get(fragmentHeader) != "" {
+		http.Error(w, message, status)
+		return
+	}
+	setFlash(w, flash{Kind: "error", Message: message})
+	http.Redirect(w, r, "/"+query(r), http.StatusSeeOther)
+}
+
+func storeStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, store.ErrTaskNotFound):
+		return http.StatusNotFound, "Task not found"
+	case errors.Is(err, store.ErrListNotFound):
+		return http.StatusNotFound, "List not found"
+	case errors.Is(err, store.ErrTaskExists):
+		return http.StatusConflict, "A task with this ID already exists"
+	case errors.Is(err, store.ErrListExists):
+		return http.StatusConflict, "A list with this name already exists"
+	case errors.Is(err, store.ErrInvalidParent):
+		return http.StatusBadRequest, "A task can't be a subtask of itself or of its own subtasks"
+	case errors.Is(err, store.ErrDependencyCycle):
+		return http.StatusBadRequest, "A task can't wait on itself or on a task waiting on it"
+	case errors.Is(err, store.ErrBlocked):
+		return http.StatusConflict, "This task waits on open tasks, finish them first"
+	case errors.Is(err, store.ErrInvalidRecurrence):
+		return http.StatusBadRequest, "Repeat must be a rule like daily, weekly on mon,thu, every 2 weeks or monthly on day 15"
+	case errors.Is(err, store.ErrInvalidList):
+		return http.StatusBadRequest, "List names must be 1 to 50 characters long and can't be Inbox"
+	case errors.Is(err, store.ErrNotDone):
+		return http.StatusConflict, "Only done tasks can be archived"
+	case errors.Is(err, store.ErrNothingToUndo):
+		return http.StatusConflict, "There is nothing to undo"
+	case errors.Is(err, store.ErrNothingToRedo):
+		return http.StatusConflict, "There is nothing to redo"
+	case errors.Is(err, store.ErrUndoConflict):
+		return http.StatusConflict, "The tasks were changed since, so the change was left as it is"
+	case errors.Is(err, store.ErrEntryNotFound):
+		return http.StatusNotFound, "Time entry not found"
+	case errors.Is(err, store.ErrEntryExists):
+		return http.StatusConflict, "A time entry with this ID already exists"
+	case errors.Is(err, store.ErrInvalidEntry):
+		return http.StatusBadRequest, "A time entry must end after it starts"
+	case errors.Is(err, store.ErrTimerRunning):
+		return http.StatusConflict, "A timer is already running, stop it first"
+	case errors.Is(err, store.ErrNoTimer):
+		return http.StatusConflict, "No timer is running"
+	}
+	return http.StatusInternalServerError, ""
+}
+
 func ParseID(r *http.Request) (uuid.UUID, error) {
-	idStr := r.FormValue("ID")
+	idStr := r.PostFormValue("ID")
 	taskID, err := uuid.Parse(idStr)
 	if err != nil {
 		return uuid.UUID{}, err
@@ -52,102 +358,378 @@ func ParseID(r *http.Request) (uuid.UUID, error) {
 	return taskID, nil
 }
 
-func (s *TaskServer) home(w http.ResponseWriter, _ *http.Request) {
-	s.renderTasksPage(w)
+func (s *TaskServer) home(w http.ResponseWriter, r *http.Request) {
+	s.renderTasksPage(w, r, uuid.Nil, takeFlash(w, r))
 }
 
 func (s *TaskServer) addTask(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
 		return
 	}
 
 	task := store.Task{
 		ID:       uuid.New(),
-		Title:    r.FormValue("title"),
-		Priority: store.Priority(r.FormValue("priority")),
+		Title:    r.PostFormValue("title"),
+		Priority: store.Priority(r.PostFormValue("priority")),
+		Tags:     splitTags(r.PostFormValue("tags")),
+		Repeat:   strings.TrimSpace(r.PostFormValue("repeat")),
+	}
+	errs := validateTask(task)
+	if msg := cmp.Or(errs["Tags"], errs["Repeat"]); msg != "" {
+		s.failed(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	task.Repeat, _ = store.NormalizeRecurrence(task.Repeat)
+	var err error
+	if task.List, err = formList(r); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid list")
+		return
 	}
 
-	if err := s.store.AddItem(task.ID, task.Title, task.Priority); err != nil {
-		http.Error(w, "Error adding task", http.StatusInternalServerError)
+	st := s.storeFor(r)
+	if parent := r.PostFormValue("parent"); parent != "" {
+		// Subtasks go to the list of their parent.
+		if task.Parent, err = uuid.Parse(parent); err != nil {
+			s.failed(w, r, http.StatusBadRequest, "Invalid parent task")
+			return
+		}
+		var p store.Task
+		if p, err = findTask(st, task.Parent); err == nil {
+			task.List = p.List
+		}
+	}
+	var recorded bool
+	if err == nil {
+		recorded, err = s.change(r, addOperations(task)...)
+	}
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error adding task"))
 		return
 	}
 
-	s.renderTasksPage(w)
+	s.succeeded(w, r, task.ID, "Task added", recorded)
 }
 
 func (s *TaskServer) deleteTask(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
 		return
 	}
 
 	taskID, err := ParseID(r)
 	if err != nil {
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
 		return
 	}
 
-	if err := s.store.DeleteItem(taskID); err != nil {
-		http.Error(w, "Error deleting task", http.StatusInternalServerError)
+	op := store.TaskOperation{Type: "Delete", ID: taskID}
+	if r.PostFormValue("subtasks") == "delete" {
+		op.Type = "DeleteTree"
+	}
+	recorded, err := s.change(r, op)
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error deleting task"))
 		return
 	}
 
-	s.renderTasksPage(w)
+	s.succeeded(w, r, taskID, "Task moved to the trash", recorded)
 }
 
 func (s *TaskServer) toggleDone(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
 		return
 	}
 
 	taskID, err := ParseID(r)
 	if err != nil {
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
 		return
 	}
 
-	if err := s.store.ToggleDone(taskID); err != nil {
-		http.Error(w, "Error toggling task", http.StatusInternalServerError)
+	recorded, err := s.change(r, store.TaskOperation{Type: "ToggleDone", ID: taskID})
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error toggling task"))
 		return
 	}
 
-	s.renderTasksPage(w)
+	s.succeeded(w, r, taskID, "Task updated", recorded)
 }
 
-func (s *TaskServer) edit(w http.ResponseWriter, r *http.Request) {
+func (s *TaskServer) update(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
 		return
 	}
 
 	taskID, err := ParseID(r)
 	if err != nil {
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
 		return
 	}
 
-	taskTitle := r.FormValue("title")
-	if err := s.store.EditTask(taskID, taskTitle); err != nil {
-		http.Error(w, "Error editing task", http.StatusInternalServerError)
+	task := store.Task{
+		ID:          taskID,
+		Title:       strings.TrimSpace(r.PostFormValue("title")),
+		Priority:    store.Priority(r.PostFormValue("priority")),
+		Description: strings.TrimSpace(r.PostFormValue("description")),
+		Tags:        splitTags(r.PostFormValue("tags")),
+		Repeat:      strings.TrimSpace(r.PostFormValue("repeat")),
+	}
+	_, moving := r.PostForm["list"]
+	_, repeating := r.PostForm["repeat"]
+	if task.List, err = formList(r); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid list")
+		return
+	}
+	errs := validateTask(task)
+	if due := r.PostFormValue("due"); due != "" {
+		if task.Due, err = time.Parse(time.DateOnly, due); err != nil {
+			if errs == nil {
+				errs = fieldErrors{}
+			}
+			errs["Due"] = "Due date must be a date like 2006-01-02"
+		}
+	}
+	if errs != nil {
+		s.renderPage(w, r, http.StatusUnprocessableEntity, taskID, &flash{Kind: "error", Message: "Task not saved, check the highlighted fields"}, &row{Task: task, Errors: errs})
+		return
+	}
+
+	var recorded bool
+	current, err := findTask(s.storeFor(r), taskID)
+	if err == nil {
+		ops := append([]store.TaskOperation{store.UpdateOperation(task)}, tagOperations(taskID, current.Tags, task.Tags)...)
+		if moving && task.List != current.List {
+			ops = append(ops, store.TaskOperation{Type: "SetList", ID: taskID, List: task.List})
+		}
+		if auto, ok := r.PostForm["autocomplete"]; ok && (auto[len(auto)-1] == "on") != current.AutoComplete {
+			ops = append(ops, store.TaskOperation{Type: "SetAutoComplete", ID: taskID, AutoComplete: !current.AutoComplete})
+		}
+		if rule, _ := store.NormalizeRecurrence(task.Repeat); repeating && rule != current.Repeat {
+			ops = append(ops, store.TaskOperation{Type: "SetRepeat", ID: taskID, Repeat: rule})
+		}
+		recorded, err = s.change(r, ops...)
+	}
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error updating task"))
 		return
 	}
 
-	s.renderTasksPage(w)
+	s.succeeded(w, r, taskID, "Task updated", recorded)
 }
 
-func Start(store store.Store) {
-	log.Println("Web API server is running on http://localhost:8080")
+// move places a task just before the task given as "before", or just after
+// the one given as "after".
+func (s *TaskServer) move(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
 
-	taskServer := NewTaskServer(store)
-	http.HandleFunc("/", taskServer.home)
-	http.HandleFunc("/add", taskServer.addTask)
-	http.HandleFunc("/delete", taskServer.deleteTask)
-	http.HandleFunc("/toggle", taskServer.toggleDone)
-	http.HandleFunc("/edit", taskServer.edit)
+	taskID, err := ParseID(r)
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	var recorded bool
+	before, err := moveTarget(s.storeFor(r), r.PostFormValue("before"), r.PostFormValue("after"))
+	if err == nil {
+		recorded, err = s.change(r, store.TaskOperation{Type: "Move", ID: taskID, Before: before})
+	}
+	if errors.Is(err, errInvalidPosition) {
+		s.failed(w, r, http.StatusBadRequest, "Invalid position")
+		return
+	}
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error moving task"))
+		return
+	}
+
+	s.succeeded(w, r, taskID, "Task moved", recorded)
+}
+
+// formList reads the "list" field of a form, the ID of a list or empty for
+// the inbox.
+func formList(r *http.Request) (uuid.UUID, error) {
+	list := r.PostFormValue("list")
+	if list == "" {
+		return uuid.Nil, nil
+	}
+	return uuid.Parse(list)
+}
+
+// nest makes a task a subtask of "parent", or a top-level task when it's
+// empty.
+func (s *TaskServer) nest(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+		return
+	}
+
+	taskID, err := ParseID(r)
+	if err != nil {
+		s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+	parent := uuid.Nil
+	if p := r.PostFormValue("parent"); p != "" {
+		if parent, err = uuid.Parse(p); err != nil {
+			s.failed(w, r, http.StatusBadRequest, "Invalid parent task")
+			return
+		}
+	}
+
+	recorded, err := s.change(r, store.TaskOperation{Type: "SetParent", ID: taskID, Parent: parent})
+	if err != nil {
+		status, message := storeStatus(err)
+		s.failed(w, r, status, cmp.Or(message, "Error nesting task"))
+		return
+	}
+
+	s.succeeded(w, r, taskID, "Task moved", recorded)
+}
+
+var errInvalidPosition = errors.New("invalid position")
+
+// moveTarget returns the task a task moved before or after another one ends
+// up in front of, uuid.Nil for the end of the list.
+func moveTarget(st store.Store, before, after string) (uuid.UUID, error) {
+	if before != "" {
+		id, err := uuid.Parse(before)
+		if err != nil {
+			return uuid.Nil, errInvalidPosition
+		}
+		return id, nil
+	}
+	id, err := uuid.Parse(after)
+	if err != nil {
+		return uuid.Nil, errInvalidPosition
+	}
+	tasks, err := st.GetAllItems()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	i := slices.IndexFunc(tasks, func(t store.Task) bool { return t.ID == id })
+	switch {
+	case i < 0:
+		return uuid.Nil, store.ErrTaskNotFound
+	case i+1 < len(tasks):
+		return tasks[i+1].ID, nil
+	}
+	return uuid.Nil, nil
+}
+
+func (s *TaskServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.loggedIn(s.home))
+	mux.HandleFunc("POST /add", s.loggedIn(checkCSRF(s.addTask)))
+	mux.HandleFunc("POST /delete", s.loggedIn(checkCSRF(s.deleteTask)))
+	mux.HandleFunc("POST /toggle", s.loggedIn(checkCSRF(s.toggleDone)))
+	mux.HandleFunc("POST /update", s.loggedIn(checkCSRF(s.update)))
+	mux.HandleFunc("POST /move", s.loggedIn(checkCSRF(s.move)))
+	mux.HandleFunc("POST /nest", s.loggedIn(checkCSRF(s.nest)))
+	mux.HandleFunc("POST /block", s.loggedIn(checkCSRF(s.block)))
+	mux.HandleFunc("POST /archive", s.loggedIn(checkCSRF(s.archive)))
+	mux.HandleFunc("POST /unarchive", s.loggedIn(checkCSRF(s.unarchiveAll)))
+	mux.HandleFunc("POST /lists/add", s.loggedIn(checkCSRF(s.addList)))
+	mux.HandleFunc("POST /lists/rename", s.loggedIn(checkCSRF(s.renameList)))
+	mux.HandleFunc("POST /lists/archive", s.loggedIn(checkCSRF(s.archiveList)))
+	mux.HandleFunc("POST /lists/delete", s.loggedIn(checkCSRF(s.deleteList)))
+	mux.HandleFunc("POST /undo", s.loggedIn(checkCSRF(s.undo(false))))
+	mux.HandleFunc("POST /redo", s.loggedIn(checkCSRF(s.undo(true))))
+	mux.HandleFunc("GET /trash", s.loggedIn(s.trash))
+	mux.HandleFunc("POST /trash/restore", s.loggedIn(checkCSRF(s.trashAction(false))))
+	mux.HandleFunc("POST /trash/purge", s.loggedIn(checkCSRF(s.trashAction(true))))
+	mux.HandleFunc("POST /trash/empty", s.loggedIn(checkCSRF(s.emptyTrash)))
+	mux.HandleFunc("POST /timer/start", s.loggedIn(checkCSRF(s.timer(false))))
+	mux.HandleFunc("POST /timer/stop", s.loggedIn(checkCSRF(s.timer(true))))
+	mux.HandleFunc("GET /time", s.loggedIn(s.timePage))
+	mux.HandleFunc("POST /time/add", s.loggedIn(checkCSRF(s.entryAction("add"))))
+	mux.HandleFunc("POST /time/edit", s.loggedIn(checkCSRF(s.entryAction("edit"))))
+	mux.HandleFunc("POST /time/delete", s.loggedIn(checkCSRF(s.entryAction("delete"))))
+	mux.HandleFunc("GET /events", s.loggedIn(s.events))
+	mux.Handle("GET /static/", s.assets.static())
+	if s.accounts != nil {
+		mux.HandleFunc("GET /login", s.loginPage)
+		mux.HandleFunc("POST /login", checkCSRF(s.webLogin))
+		mux.HandleFunc("GET /register", s.registerPage)
+		mux.HandleFunc("POST /register", checkCSRF(s.webRegister))
+		mux.HandleFunc("POST /logout", s.loggedIn(checkCSRF(s.webLogout)))
+	}
+
+	if s.accounts == nil {
+		s.apiRoutes(mux, "/api/v1", s.single)
+	} else {
+		// With accounts the V1 API too acts as the logged-in user, there's
+		// no shared store to serve anonymously.
+		s.apiRoutes(mux, "/api/v1", s.authorized)
+		mux.HandleFunc("POST /api/v2/users", s.apiRegister)
+		mux.HandleFunc("POST /api/v2/login", s.apiLogin)
+		mux.HandleFunc("POST /api/v2/logout", s.authorized(s.apiLogout))
+		s.apiRoutes(mux, "/api/v2", s.authorized)
+	}
+	return withSecurityHeaders(mux)
+}
+
+func (s *TaskServer) apiRoutes(mux *http.ServeMux, prefix string, wrap func(storeHandler) http.HandlerFunc) {
+	mux.HandleFunc("GET "+prefix+"/tasks", wrap(s.apiListTasks))
+	mux.HandleFunc("POST "+prefix+"/tasks", wrap(s.apiAddTask))
+	mux.HandleFunc("GET "+prefix+"/tasks/{id}", wrap(s.apiGetTask))
+	mux.HandleFunc("PATCH "+prefix+"/tasks/{id}", wrap(s.apiEditTask))
+	mux.HandleFunc("DELETE "+prefix+"/tasks/{id}", wrap(s.apiDeleteTask))
+	mux.HandleFunc("POST "+prefix+"/tasks/{id}/toggle", wrap(s.apiToggleTask))
+	mux.HandleFunc("POST "+prefix+"/tasks/{id}/move", wrap(s.apiMoveTask))
+	mux.HandleFunc("POST "+prefix+"/tasks/{id}/tags", wrap(s.apiTagTask))
+	mux.HandleFunc("DELETE "+prefix+"/tasks/{id}/tags/{tag}", wrap(s.apiUntagTask))
+	mux.HandleFunc("POST "+prefix+"/tasks/{id}/list", wrap(s.apiSetList))
+	mux.HandleFunc("POST "+prefix+"/tasks/{id}/parent", wrap(s.apiSetParent))
+	mux.HandleFunc("POST "+prefix+"/tasks/{id}/blockers", wrap(s.apiAddBlocker))
+	mux.HandleFunc("DELETE "+prefix+"/tasks/{id}/blockers/{blocker}", wrap(s.apiRemoveBlocker))
+	mux.HandleFunc("GET "+prefix+"/lists", wrap(s.apiLists))
+	mux.HandleFunc("POST "+prefix+"/lists", wrap(s.apiCreateList))
+	mux.HandleFunc("PATCH "+prefix+"/lists/{list}", wrap(s.apiEditList))
+	mux.HandleFunc("DELETE "+prefix+"/lists/{list}", wrap(s.apiDeleteList))
+	mux.HandleFunc("GET "+prefix+"/lists/{list}/tasks", wrap(s.apiListTasks))
+	mux.HandleFunc("POST "+prefix+"/lists/{list}/tasks", wrap(s.apiAddTask))
+	mux.HandleFunc("POST "+prefix+"/archive", wrap(s.apiArchiveDone))
+	mux.HandleFunc("POST "+prefix+"/unarchive", wrap(s.apiUnarchive))
+	mux.HandleFunc("GET "+prefix+"/trash", wrap(s.apiTrash))
+	mux.HandleFunc("DELETE "+prefix+"/trash", wrap(s.apiEmptyTrash))
+	mux.HandleFunc("POST "+prefix+"/trash/{id}/restore", wrap(s.apiRestoreTask))
+	mux.HandleFunc("DELETE "+prefix+"/trash/{id}", wrap(s.apiPurgeTask))
+	mux.HandleFunc("POST "+prefix+"/tasks/{id}/timer", wrap(s.apiStartTimer))
+	mux.HandleFunc("DELETE "+prefix+"/timer", wrap(s.apiStopTimer))
+	mux.HandleFunc("GET "+prefix+"/time", wrap(s.apiTimeEntries))
+	mux.HandleFunc("POST "+prefix+"/time", wrap(s.apiAddTimeEntry))
+	mux.HandleFunc("PATCH "+prefix+"/time/{id}", wrap(s.apiEditTimeEntry))
+	mux.HandleFunc("DELETE "+prefix+"/time/{id}", wrap(s.apiDeleteTimeEntry))
+	mux.HandleFunc("GET "+prefix+"/report", wrap(s.apiReport))
+	mux.HandleFunc("POST "+prefix+"/undo", wrap(s.apiUndo))
+	mux.HandleFunc("POST "+prefix+"/redo", wrap(s.apiRedo))
+	mux.HandleFunc("POST "+prefix+"/batch", wrap(s.apiBatch))
+}
+
+func Start(store store.Store, accounts store.Accounts, stores *store.Registry, assetDir string) {
+	taskServer := NewMultiUserTaskServer(store, accounts, stores)
+	if assetDir != "" {
+		if err := taskServer.UseAssetDir(assetDir); err != nil {
+			log.Println(err)
+			return
+		}
+		log.Printf("Serving templates and static files from %s", assetDir)
+	}
+
+	log.Println("Web API server is running on http://localhost:8080")
 
-	err := http.ListenAndServe(":8080", nil)
+	err := http.ListenAndServe(":8080", taskServer.Handler())
 	if err != nil {
 		log.Println(err)
 	}
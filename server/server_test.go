@@ -1,11 +1,25 @@
 package server
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 	"todoapp/store"
+
+	"github.com/google/uuid"
 )
 
 func BenchmarkServer(b *testing.B) {
@@ -36,3 +50,1756 @@ func BenchmarkServer(b *testing.B) {
 		}
 	})
 }
+
+func TestAPI(t *testing.T) {
+	c := store.Config{LoadFromFile: false}
+
+	t.Run("add and get task", func(t *testing.T) {
+		s, _ := store.NewInMemoryStore(c)
+		ts := httptest.NewServer(NewTaskServer(s).Handler())
+		defer ts.Close()
+
+		taskID := uuid.New()
+		body := fmt.Sprintf(`{"ID":%q,"Title":"Test Task","Priority":"High"}`, taskID)
+		resp, err := http.Post(ts.URL+"/api/v1/tasks", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+
+		resp, err = http.Get(ts.URL + "/api/v1/tasks/" + taskID.String())
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		var task store.Task
+		if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+			t.Fatalf("Error decoding task: %v", err)
+		}
+		if task.ID != taskID || task.Title != "Test Task" || task.Priority != store.High {
+			t.Errorf("unexpected task %+v", task)
+		}
+	})
+
+	t.Run("invalid priority", func(t *testing.T) {
+		s, _ := store.NewInMemoryStore(c)
+		ts := httptest.NewServer(NewTaskServer(s).Handler())
+		defer ts.Close()
+
+		resp, err := http.Post(ts.URL+"/api/v1/tasks", "application/json", strings.NewReader(`{"Title":"Test Task","Priority":"Urgent"}`))
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+
+	t.Run("missing task", func(t *testing.T) {
+		s, _ := store.NewInMemoryStore(c)
+		ts := httptest.NewServer(NewTaskServer(s).Handler())
+		defer ts.Close()
+
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/v1/tasks/"+uuid.New().String(), nil)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+
+	t.Run("changes from forms", func(t *testing.T) {
+		s, _ := store.NewInMemoryStore(c)
+		ts := httptest.NewServer(NewTaskServer(s).Handler())
+		defer ts.Close()
+
+		// What another site can make a browser send without asking first.
+		taskID := uuid.New()
+		_ = s.AddItem(taskID, "Test Task", store.Low)
+		for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/tasks/"+taskID.String()+"/toggle", strings.NewReader(`{}`))
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed request: %v", err)
+			}
+			if resp.StatusCode != http.StatusUnsupportedMediaType {
+				t.Errorf("%q: expected status %d, got %d", contentType, http.StatusUnsupportedMediaType, resp.StatusCode)
+			}
+		}
+		if tasks, _ := s.GetAllItems(); tasks[0].Done {
+			t.Error("expected the task left open")
+		}
+	})
+}
+
+func TestAPIV2(t *testing.T) {
+	c := store.Config{LoadFromFile: false}
+	s, _ := store.NewInMemoryStore(c)
+	accounts, _ := store.NewInMemoryAccounts(c)
+	stores := store.NewRegistry(func(user string) (store.Store, error) {
+		return store.NewInMemoryStore(c)
+	})
+	ts := httptest.NewServer(NewMultiUserTaskServer(s, accounts, stores).Handler())
+	defer ts.Close()
+
+	request := func(method, path, token, body string) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		return resp
+	}
+	login := func(name string) string {
+		resp := request(http.MethodPost, "/api/v2/users", "", fmt.Sprintf(`{"Name":%q,"Password":"password123"}`, name))
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+		resp = request(http.MethodPost, "/api/v2/login", "", fmt.Sprintf(`{"Name":%q,"Password":"password123"}`, name))
+		var session store.Session
+		if err := json.NewDecoder(resp.Body).Decode(&session); err != nil || session.Token == "" {
+			t.Fatalf("expected a session, got %v", err)
+		}
+		return session.Token
+	}
+
+	alice, bob := login("alice"), login("bob")
+
+	t.Run("tasks are scoped to the user", func(t *testing.T) {
+		resp := request(http.MethodPost, "/api/v2/tasks", alice, `{"Title":"Alice's task","Priority":"Low"}`)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+
+		var tasks []store.Task
+		_ = json.NewDecoder(request(http.MethodGet, "/api/v2/tasks", bob, "").Body).Decode(&tasks)
+		if len(tasks) != 0 {
+			t.Errorf("expected bob to see 0 tasks, got %d", len(tasks))
+		}
+		_ = json.NewDecoder(request(http.MethodGet, "/api/v2/tasks", alice, "").Body).Decode(&tasks)
+		if len(tasks) != 1 {
+			t.Errorf("expected alice to see 1 task, got %d", len(tasks))
+		}
+	})
+
+	t.Run("the v1 api needs a login", func(t *testing.T) {
+		if resp := request(http.MethodGet, "/api/v1/tasks", "", ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+		if resp := request(http.MethodPost, "/api/v1/tasks", "", `{"Title":"Anonymous task","Priority":"Low"}`); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+		var tasks []store.Task
+		_ = json.NewDecoder(request(http.MethodGet, "/api/v1/tasks", alice, "").Body).Decode(&tasks)
+		if len(tasks) != 1 || tasks[0].Title != "Alice's task" {
+			t.Errorf("expected alice's tasks through the v1 api, got %+v", tasks)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		resp := request(http.MethodPost, "/api/v2/login", "", `{"Name":"alice","Password":"wrong password"}`)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		resp := request(http.MethodPost, "/api/v2/logout", bob, "")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, resp.StatusCode)
+		}
+		resp = request(http.MethodGet, "/api/v2/tasks", bob, "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+	})
+}
+
+func TestAssets(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	get := func(t *testing.T, h http.Handler, path string) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	t.Run("embedded", func(t *testing.T) {
+		h := NewTaskServer(s).Handler()
+		if code, body := get(t, h, "/"); code != http.StatusOK || !strings.Contains(body, "Todo List") {
+			t.Errorf("expected the tasks page, got %d: %s", code, body)
+		}
+		if code, body := get(t, h, "/static/style.css"); code != http.StatusOK || !strings.Contains(body, ".container") {
+			t.Errorf("expected the stylesheet, got %d: %s", code, body)
+		}
+	})
+
+	t.Run("reload from directory", func(t *testing.T) {
+		dir := t.TempDir()
+		page := filepath.Join(dir, "templates", "todo_app.html")
+		for _, name := range []string{page, filepath.Join(dir, "static", "style.css")} {
+			if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(name, []byte("first"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ts := NewTaskServer(s)
+		if err := ts.UseAssetDir(dir); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		h := ts.Handler()
+		if _, body := get(t, h, "/"); body != "first" {
+			t.Errorf("expected %q, got %q", "first", body)
+		}
+
+		if err := os.WriteFile(page, []byte("second"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(page, later, later); err != nil {
+			t.Fatal(err)
+		}
+		if _, body := get(t, h, "/"); body != "second" {
+			t.Errorf("expected %q, got %q", "second", body)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		if err := NewTaskServer(s).UseAssetDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestFragments(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	taskID := uuid.New()
+	if err := s.AddItem(taskID, "Test Task", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	post := func(path, fragment string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, fragment)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("row", func(t *testing.T) {
+		rec := post("/toggle", "row", url.Values{"ID": {taskID.String()}})
+		body := rec.Body.String()
+		if !strings.HasPrefix(strings.TrimSpace(body), `<tr id="task-`+taskID.String()+`"`) {
+			t.Errorf("expected the task row, got %s", body)
+		}
+		if strings.Contains(body, "<html") || !strings.Contains(body, "Mark as To Do") {
+			t.Errorf("expected only the toggled row, got %s", body)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		rec := post("/add", "list", url.Values{"title": {"Second Task"}, "priority": {"High"}})
+		body := strings.TrimSpace(rec.Body.String())
+		if !strings.HasPrefix(body, `<div id="tasks"`) || !strings.Contains(body, "Second Task") {
+			t.Errorf("expected the task list, got %s", body)
+		}
+	})
+
+	t.Run("deleted row", func(t *testing.T) {
+		rec := post("/delete", "row", url.Values{"ID": {taskID.String()}})
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+		}
+	})
+
+	t.Run("no fragment", func(t *testing.T) {
+		rec := post("/add", "", url.Values{"title": {"Third Task"}, "priority": {"Low"}})
+		if rec.Code != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, rec.Code)
+		}
+	})
+}
+
+func TestPostRedirectGet(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path string, form url.Values, cookies ...*http.Cookie) *http.Response {
+		if form != nil {
+			form.Set(csrfField, csrfToken("secret"))
+		}
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+	body := func(resp *http.Response) string {
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	t.Run("redirects with a flash message", func(t *testing.T) {
+		resp := do(http.MethodPost, "/add?view=open", url.Values{"title": {"Test Task"}, "priority": {"Low"}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d", http.StatusSeeOther, resp.StatusCode)
+		}
+		if location := resp.Header.Get("Location"); location != "/?view=open" {
+			t.Errorf("expected redirect to %q, got %q", "/?view=open", location)
+		}
+
+		page := do(http.MethodGet, "/?view=open", nil, resp.Cookies()...)
+		content := body(page)
+		if !strings.Contains(content, "Task added") || !strings.Contains(content, "Test Task") {
+			t.Errorf("expected the flash message and the task, got %s", content)
+		}
+		if !strings.Contains(content, `action="/toggle?view=open"`) {
+			t.Errorf("expected forms to keep the query, got %s", content)
+		}
+		if cookies := page.Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+			t.Errorf("expected the flash cookie to be cleared, got %v", cookies)
+		}
+	})
+
+	t.Run("errors are flashed", func(t *testing.T) {
+		resp := do(http.MethodPost, "/toggle", url.Values{"ID": {uuid.NewString()}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d", http.StatusSeeOther, resp.StatusCode)
+		}
+		if content := body(do(http.MethodGet, "/", nil, resp.Cookies()...)); !strings.Contains(content, "Task not found") {
+			t.Errorf("expected the error message, got %s", content)
+		}
+	})
+
+	t.Run("only POST is allowed", func(t *testing.T) {
+		for _, path := range []string{"/add", "/delete", "/toggle", "/update"} {
+			if resp := do(http.MethodGet, path, nil); resp.StatusCode != http.StatusMethodNotAllowed {
+				t.Errorf("%s: expected status %d, got %d", path, http.StatusMethodNotAllowed, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestEvents(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	ts := httptest.NewServer(NewTaskServer(s).Handler())
+	defer ts.Close()
+	subscribe := func(t *testing.T, lastID string) (*bufio.Reader, func()) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events", nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected an event stream, got %q", ct)
+		}
+		return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+	}
+	next := func(t *testing.T, r *bufio.Reader) string {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Error reading event: %s", err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	events, closeEvents := subscribe(t, "")
+	defer closeEvents()
+	taskID := uuid.New()
+	if err := s.AddItem(taskID, "Test Task", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	if event := next(t, events); !strings.HasPrefix(event, "id: 1\nevent: change\n") || !strings.Contains(event, taskID.String()) {
+		t.Errorf("expected the add event, got %q", event)
+	}
+
+	if err := s.ToggleDone(taskID); err != nil {
+		t.Fatalf("Error toggling task: %s", err)
+	}
+	resumed, closeResumed := subscribe(t, "1")
+	defer closeResumed()
+	if event := next(t, resumed); !strings.HasPrefix(event, "id: 2\nevent: change\n") || !strings.Contains(event, `"ToggleDone"`) {
+		t.Errorf("expected the missed toggle event, got %q", event)
+	}
+
+	reset, closeReset := subscribe(t, "42")
+	defer closeReset()
+	if event := next(t, reset); !strings.HasPrefix(event, "id: 2\nevent: reset\n") {
+		t.Errorf("expected a reset event, got %q", event)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	for i := range 25 {
+		priority := store.Low
+		if i%5 == 0 {
+			priority = store.High
+		}
+		if err := s.AddItem(uuid.New(), fmt.Sprintf("Task %02d", i), priority); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	h := NewTaskServer(s).Handler()
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Body.String()
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		body := get("/")
+		if strings.Count(body, `<tr id="task-`) != pageSize || !strings.Contains(body, "Page 1 of 2") {
+			t.Errorf("expected the first of 2 pages, got %s", body)
+		}
+		if !strings.Contains(body, `href="/?page=2"`) {
+			t.Errorf("expected a link to the next page, got %s", body)
+		}
+		if body := get("/?page=9"); !strings.Contains(body, "Page 2 of 2") || !strings.Contains(body, "Task 24") {
+			t.Errorf("expected the last page, got %s", body)
+		}
+	})
+
+	t.Run("search and filters", func(t *testing.T) {
+		body := get("/?q=task+1&priority=High&sort=-title")
+		if strings.Count(body, `<tr id="task-`) != 2 || strings.Index(body, "Task 15") > strings.Index(body, "Task 10") {
+			t.Errorf("expected Task 15 and Task 10, got %s", body)
+		}
+		if !strings.Contains(body, `value="task 1"`) || !strings.Contains(body, `<option value="High" selected>`) {
+			t.Errorf("expected the controls to show the filters, got %s", body)
+		}
+		if !strings.Contains(body, `aria-sort="descending"><a href="/?priority=High&amp;q=task&#43;1&amp;sort=title"`) {
+			t.Errorf("expected the title header to reverse the order, got %s", body)
+		}
+		if !strings.Contains(body, `action="/toggle?q=task&#43;1&amp;priority=High&amp;sort=-title"`) {
+			t.Errorf("expected forms to keep the filters, got %s", body)
+		}
+	})
+
+	t.Run("nothing matches", func(t *testing.T) {
+		if body := get("/?q=missing"); !strings.Contains(body, "No tasks match") {
+			t.Errorf("expected an empty result, got %s", body)
+		}
+	})
+}
+
+func TestWebSessions(t *testing.T) {
+	c := store.Config{LoadFromFile: false}
+	s, _ := store.NewInMemoryStore(c)
+	accounts, _ := store.NewInMemoryAccounts(c)
+	stores := store.NewRegistry(func(user string) (store.Store, error) {
+		return store.NewInMemoryStore(c)
+	})
+	ts := httptest.NewServer(NewMultiUserTaskServer(s, accounts, stores).Handler())
+	defer ts.Close()
+
+	newClient := func() *http.Client {
+		jar, _ := cookiejar.New(nil)
+		return &http.Client{Jar: jar}
+	}
+	get := func(t *testing.T, client *http.Client, path string) (*http.Response, string) {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+	csrfPattern := regexp.MustCompile(`name="csrf" value="([^"]+)"`)
+	post := func(t *testing.T, client *http.Client, path string, form url.Values) (*http.Response, string) {
+		_, page := get(t, client, "/")
+		m := csrfPattern.FindStringSubmatch(page)
+		if m == nil {
+			t.Fatalf("expected a CSRF token, got %s", page)
+		}
+		if form == nil {
+			form = url.Values{}
+		}
+		form.Set("csrf", m[1])
+		resp, err := client.PostForm(ts.URL+path, form)
+		if err != nil {
+			t.Fatalf("Failed request: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+	register := func(t *testing.T, name string) *http.Client {
+		client := newClient()
+		form := url.Values{"name": {name}, "password": {"password123"}, "confirm": {"password123"}, "next": {"/"}}
+		resp, body := post(t, client, "/register", form)
+		if resp.Request.URL.Path != "/" || !strings.Contains(body, "Signed in as "+name) {
+			t.Fatalf("expected to be logged in as %s, got %s: %s", name, resp.Request.URL, body)
+		}
+		return client
+	}
+
+	t.Run("pages require a session", func(t *testing.T) {
+		resp, _ := get(t, newClient(), "/?status=open")
+		if resp.Request.URL.Path != "/login" || resp.Request.URL.Query().Get("next") != "/?status=open" {
+			t.Errorf("expected the login page, got %s", resp.Request.URL)
+		}
+		if resp, _ := post(t, newClient(), "/add", url.Values{"title": {"Task"}}); resp.Request.URL.Path != "/login" {
+			t.Errorf("expected the login page, got %s", resp.Request.URL)
+		}
+	})
+
+	alice, bob := register(t, "alice"), register(t, "bob")
+
+	t.Run("tasks are scoped to the user", func(t *testing.T) {
+		post(t, alice, "/add", url.Values{"title": {"Alice's task"}, "priority": {"Low"}})
+		if _, body := get(t, alice, "/"); !strings.Contains(body, "Alice&#39;s task") {
+			t.Errorf("expected Alice's task, got %s", body)
+		}
+		if _, body := get(t, bob, "/"); strings.Contains(body, "Alice&#39;s task") {
+			t.Errorf("expected Bob not to see Alice's task, got %s", body)
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		resp, body := post(t, newClient(), "/login", url.Values{"name": {"alice"}, "password": {"wrong password"}})
+		if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, "Invalid user name or password") {
+			t.Errorf("expected the login to fail, got %d: %s", resp.StatusCode, body)
+		}
+
+		client := newClient()
+		resp, body = post(t, client, "/login", url.Values{"name": {"alice"}, "password": {"password123"}, "next": {"//example.com"}})
+		if resp.Request.URL.Host != strings.TrimPrefix(ts.URL, "http://") || !strings.Contains(body, "Alice&#39;s task") {
+			t.Errorf("expected Alice's tasks on this server, got %s", resp.Request.URL)
+		}
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		resp, body := post(t, bob, "/logout", nil)
+		if resp.Request.URL.Path != "/login" || !strings.Contains(body, "Logged out") {
+			t.Errorf("expected the login page, got %s: %s", resp.Request.URL, body)
+		}
+		if resp, _ := get(t, bob, "/"); resp.Request.URL.Path != "/login" {
+			t.Errorf("expected the login page, got %s", resp.Request.URL)
+		}
+	})
+}
+
+func TestCSRF(t *testing.T) {
+	c := store.Config{LoadFromFile: false}
+	s, _ := store.NewInMemoryStore(c)
+	accounts, _ := store.NewInMemoryAccounts(c)
+	stores := store.NewRegistry(func(user string) (store.Store, error) {
+		return s, nil
+	})
+	h := NewMultiUserTaskServer(s, accounts, stores).Handler()
+	ts := &TaskServer{accounts: accounts}
+	if err := ts.register("alice", "password123"); err != nil {
+		t.Fatalf("Error registering: %s", err)
+	}
+	session, err := ts.login("alice", "password123", time.Hour)
+	if err != nil {
+		t.Fatalf("Error logging in: %s", err)
+	}
+	post := func(path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	sessionCookie := &http.Cookie{Name: sessionCookie, Value: session.Token}
+
+	t.Run("security headers", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+		for name, value := range securityHeaders {
+			if got := rec.Header().Get(name); got != value {
+				t.Errorf("expected %s %q, got %q", name, value, got)
+			}
+		}
+		if body := rec.Body.String(); strings.Contains(body, "style=") || strings.Contains(body, "<script>") {
+			t.Errorf("expected no inline styles or scripts, got %s", body)
+		}
+	})
+
+	t.Run("missing or foreign tokens are rejected", func(t *testing.T) {
+		form := url.Values{"title": {"Forged task"}, "priority": {"Low"}}
+		if rec := post("/add", form, sessionCookie); rec.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+		form.Set(csrfField, csrfToken("another session"))
+		if rec := post("/add", form, sessionCookie); rec.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+		if rec := post("/login", url.Values{"name": {"alice"}, "password": {"password123"}}); rec.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+		if tasks, _ := s.GetAllItems(); len(tasks) != 0 {
+			t.Errorf("expected no tasks, got %+v", tasks)
+		}
+	})
+
+	t.Run("the session's token is accepted", func(t *testing.T) {
+		form := url.Values{"title": {"Task"}, "priority": {"Low"}, csrfField: {csrfToken(session.Token)}}
+		if rec := post("/add", form, sessionCookie); rec.Code != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, rec.Code)
+		}
+		if tasks, _ := s.GetAllItems(); len(tasks) != 1 {
+			t.Errorf("expected 1 task, got %+v", tasks)
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	taskID := uuid.New()
+	if err := s.AddItem(taskID, "Test Task", store.Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	post := func(fragment string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("ID", taskID.String())
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, fragment)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("all fields", func(t *testing.T) {
+		rec := post("row", url.Values{"title": {"Renamed"}, "priority": {"High"}, "description": {"Some details"}, "due": {"2026-11-05"}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		tasks, _ := s.GetAllItems()
+		expected := store.Task{ID: taskID, Title: "Renamed", Priority: store.High, Description: "Some details", Due: time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC), Position: 1}
+		if !reflect.DeepEqual(tasks[0], expected) {
+			t.Errorf("expected %+v, got %+v", expected, tasks[0])
+		}
+		if body := rec.Body.String(); !strings.Contains(body, `value="2026-11-05"`) || !strings.Contains(body, "Some details</textarea>") {
+			t.Errorf("expected the updated row, got %s", body)
+		}
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		rec := post("row", url.Values{"title": {" "}, "priority": {"Urgent"}, "description": {"Kept"}, "due": {"tomorrow"}})
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, msg := range []string{"Title is required", "Priority must be Low, Medium or High", "Due date must be a date"} {
+			if !strings.Contains(body, msg) {
+				t.Errorf("expected %q in %s", msg, body)
+			}
+		}
+		if strings.Count(body, `aria-invalid="true"`) != 3 || !strings.Contains(body, "Kept</textarea>") {
+			t.Errorf("expected the submitted values with 3 invalid fields, got %s", body)
+		}
+		if tasks, _ := s.GetAllItems(); tasks[0].Title != "Renamed" {
+			t.Errorf("expected the task to be unchanged, got %+v", tasks[0])
+		}
+
+		rec = post("", url.Values{"title": {strings.Repeat("x", maxTitleLength+1)}, "priority": {"Low"}})
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "<html") {
+			t.Errorf("expected the full page with status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+		}
+	})
+
+	t.Run("api", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/tasks/"+taskID.String(), strings.NewReader(`{"Description":"","Due":"0001-01-01T00:00:00Z"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		tasks, _ := s.GetAllItems()
+		if tasks[0].Title != "Renamed" || tasks[0].Description != "" || !tasks[0].Due.IsZero() {
+			t.Errorf("expected only the description and due date to be cleared, got %+v", tasks[0])
+		}
+	})
+
+	t.Run("api clears the due date", func(t *testing.T) {
+		patch := func(body string) int {
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/tasks/"+taskID.String(), strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		for _, clear := range []string{`null`, `""`} {
+			if code := patch(`{"Due":"2026-11-05T00:00:00Z"}`); code != http.StatusNoContent {
+				t.Fatalf("expected status %d, got %d", http.StatusNoContent, code)
+			}
+			if code := patch(`{"Title":"Renamed"}`); code != http.StatusNoContent {
+				t.Fatalf("expected status %d, got %d", http.StatusNoContent, code)
+			}
+			if tasks, _ := s.GetAllItems(); tasks[0].Due.IsZero() {
+				t.Fatalf("expected the due date kept when not given, got %+v", tasks[0])
+			}
+			if code := patch(`{"Due":` + clear + `}`); code != http.StatusNoContent {
+				t.Fatalf("expected status %d, got %d", http.StatusNoContent, code)
+			}
+			if tasks, _ := s.GetAllItems(); !tasks[0].Due.IsZero() {
+				t.Errorf("expected the due date cleared by %s, got %+v", clear, tasks[0])
+			}
+		}
+		if code := patch(`{"Due":"next week"}`); code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an invalid due date, got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+func TestMove(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for i, id := range ids {
+		if err := s.AddItem(id, fmt.Sprintf("Task %d", i+1), store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	post := func(fragment string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, fragment)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	order := func() []uuid.UUID {
+		tasks, _ := s.GetAllItems()
+		var got []uuid.UUID
+		for _, task := range tasks {
+			got = append(got, task.ID)
+		}
+		return got
+	}
+
+	t.Run("before and after", func(t *testing.T) {
+		rec := post("list", url.Values{"ID": {ids[2].String()}, "before": {ids[0].String()}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		if expected := []uuid.UUID{ids[2], ids[0], ids[1]}; !slices.Equal(order(), expected) {
+			t.Errorf("expected %v, got %v", expected, order())
+		}
+		body := rec.Body.String()
+		if strings.Index(body, "Task 3") > strings.Index(body, "Task 1") {
+			t.Errorf("expected the moved task first in the list, got %s", body)
+		}
+
+		rec = post("", url.Values{"ID": {ids[2].String()}, "after": {ids[1].String()}})
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d", http.StatusSeeOther, rec.Code)
+		}
+		if expected := []uuid.UUID{ids[0], ids[1], ids[2]}; !slices.Equal(order(), expected) {
+			t.Errorf("expected %v, got %v", expected, order())
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if rec := post("list", url.Values{"ID": {ids[0].String()}}); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d without a position, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if rec := post("list", url.Values{"ID": {ids[0].String()}, "after": {uuid.NewString()}}); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for a missing task, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("buttons", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, `name="after" value="`+ids[1].String()+`"`) || strings.Count(body, `title="Move up" disabled`)+strings.Count(body, `title="Move down" disabled`) != 2 {
+			t.Errorf("expected move buttons around each task, disabled at the ends, got %s", body)
+		}
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?sort=title", nil))
+		if strings.Contains(rec.Body.String(), `action="/move`) {
+			t.Errorf("expected no move buttons on a sorted page")
+		}
+	})
+
+	t.Run("api", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+ids[0].String()+"/move", strings.NewReader(`{"Before":"`+ids[2].String()+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if expected := []uuid.UUID{ids[1], ids[0], ids[2]}; !slices.Equal(order(), expected) {
+			t.Errorf("expected %v, got %v", expected, order())
+		}
+	})
+}
+
+func TestTags(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "row")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	tagsOf := func(id uuid.UUID) []string {
+		task, _ := findTask(s, id)
+		return task.Tags
+	}
+
+	var task store.Task
+	t.Run("api", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1/tasks", `{"Title":"Deploy","Priority":"High","Tags":["Ops","+backend","ops"]}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		}
+		json.NewDecoder(rec.Body).Decode(&task)
+		if expected := []string{"backend", "ops"}; !slices.Equal(tagsOf(task.ID), expected) {
+			t.Errorf("expected %q, got %q", expected, tagsOf(task.ID))
+		}
+		if err := s.AddItem(uuid.New(), "Untagged", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+
+		if rec := do(http.MethodPost, "/api/v1/tasks/"+task.ID.String()+"/tags", `{"Tag":"urgent"}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodDelete, "/api/v1/tasks/"+task.ID.String()+"/tags/backend", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if expected := []string{"ops", "urgent"}; !slices.Equal(tagsOf(task.ID), expected) {
+			t.Errorf("expected %q, got %q", expected, tagsOf(task.ID))
+		}
+		if rec := do(http.MethodPost, "/api/v1/tasks/"+task.ID.String()+"/tags", `{"Tag":"two words"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an invalid tag, got %d", http.StatusBadRequest, rec.Code)
+		}
+
+		var tasks []store.Task
+		json.NewDecoder(do(http.MethodGet, "/api/v1/tasks?tag=urgent", "").Body).Decode(&tasks)
+		if len(tasks) != 1 || tasks[0].ID != task.ID {
+			t.Errorf("expected only the tagged task, got %+v", tasks)
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		form := url.Values{"ID": {task.ID.String()}, "title": {"Deploy"}, "priority": {"High"}, "tags": {"ops, Home"}}
+		if rec := post("/update", form); rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		if expected := []string{"home", "ops"}; !slices.Equal(tagsOf(task.ID), expected) {
+			t.Errorf("expected %q, got %q", expected, tagsOf(task.ID))
+		}
+
+		form.Set("tags", "ops no/slashes")
+		rec := post("/update", form)
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `id="tags-error-`) {
+			t.Errorf("expected an error on the tags field, got %d: %s", rec.Code, rec.Body)
+		}
+
+		rec = do(http.MethodGet, "/?tag=home", "")
+		body := rec.Body.String()
+		if !strings.Contains(body, "Deploy") || strings.Contains(body, "Untagged") {
+			t.Errorf("expected only the tagged task, got %s", body)
+		}
+		if !strings.Contains(body, `<a href="/" class="tag" aria-label="Stop filtering by tag home">`) {
+			t.Errorf("expected a chip removing the tag filter, got %s", body)
+		}
+	})
+}
+
+func TestLists(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	listOf := func(id uuid.UUID) uuid.UUID {
+		task, _ := findTask(s, id)
+		return task.List
+	}
+
+	var work store.List
+	var task store.Task
+	t.Run("api", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1/lists", `{"Name":" Work "}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		}
+		json.NewDecoder(rec.Body).Decode(&work)
+		if work.Name != "Work" || work.ID == uuid.Nil {
+			t.Errorf("expected the new list, got %+v", work)
+		}
+		if rec := do(http.MethodPost, "/api/v1/lists", `{"Name":"work"}`); rec.Code != http.StatusConflict {
+			t.Errorf("expected status %d for a duplicate name, got %d", http.StatusConflict, rec.Code)
+		}
+		if rec := do(http.MethodPost, "/api/v1/lists", `{"Name":"Inbox"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for a reserved name, got %d", http.StatusBadRequest, rec.Code)
+		}
+
+		rec = do(http.MethodPost, "/api/v1/lists/work/tasks", `{"Title":"Deploy","Priority":"High"}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		}
+		json.NewDecoder(rec.Body).Decode(&task)
+		if listOf(task.ID) != work.ID {
+			t.Errorf("expected the task in %s, got %s", work.ID, listOf(task.ID))
+		}
+		if err := s.AddItem(uuid.New(), "Groceries", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+
+		var tasks []store.Task
+		json.NewDecoder(do(http.MethodGet, "/api/v1/lists/"+work.ID.String()+"/tasks", "").Body).Decode(&tasks)
+		if len(tasks) != 1 || tasks[0].ID != task.ID {
+			t.Errorf("expected only the task of the list, got %+v", tasks)
+		}
+		json.NewDecoder(do(http.MethodGet, "/api/v1/lists/inbox/tasks", "").Body).Decode(&tasks)
+		if len(tasks) != 1 || tasks[0].Title != "Groceries" {
+			t.Errorf("expected only the task in the inbox, got %+v", tasks)
+		}
+		if rec := do(http.MethodGet, "/api/v1/lists/home/tasks", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for an unknown list, got %d", http.StatusNotFound, rec.Code)
+		}
+
+		if rec := do(http.MethodPatch, "/api/v1/lists/work", `{"Name":"Job","Archived":true}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		var lists []store.List
+		json.NewDecoder(do(http.MethodGet, "/api/v1/lists", "").Body).Decode(&lists)
+		if expected := []store.List{{ID: work.ID, Name: "Job", Archived: true}}; !slices.Equal(lists, expected) {
+			t.Errorf("expected %v, got %v", expected, lists)
+		}
+		if rec := do(http.MethodPatch, "/api/v1/lists/job", `{"Archived":false}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+
+		if rec := do(http.MethodPost, "/api/v1/tasks/"+task.ID.String()+"/list", `{"List":"`+uuid.NewString()+`"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for an unknown list, got %d", http.StatusNotFound, rec.Code)
+		}
+		if rec := do(http.MethodPost, "/api/v1/tasks/"+task.ID.String()+"/list", `{}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if listOf(task.ID) != uuid.Nil {
+			t.Errorf("expected the task in the inbox, got %s", listOf(task.ID))
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		rec := post("/lists/add", url.Values{"name": {"Home"}})
+		lists, _ := s.GetLists()
+		home, _ := store.FindList(lists, "Home")
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/?list="+home.ID.String() {
+			t.Fatalf("expected a redirect to the new list, got %d %q", rec.Code, rec.Header().Get("Location"))
+		}
+
+		form := url.Values{"ID": {task.ID.String()}, "title": {"Deploy"}, "priority": {"High"}, "list": {home.ID.String()}}
+		if rec := post("/update", form); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+		if listOf(task.ID) != home.ID {
+			t.Errorf("expected the task in %s, got %s", home.ID, listOf(task.ID))
+		}
+		if rec := post("/add?list="+home.ID.String(), url.Values{"title": {"Water plants"}, "priority": {"Low"}, "list": {home.ID.String()}}); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+
+		body := do(http.MethodGet, "/?list="+home.ID.String(), "").Body.String()
+		if !strings.Contains(body, "Deploy") || !strings.Contains(body, "Water plants") || strings.Contains(body, "Groceries") {
+			t.Errorf("expected only the tasks of the list, got %s", body)
+		}
+		if !strings.Contains(body, `aria-current="page">Home</a>`) || !strings.Contains(body, `id="list-heading">Home`) {
+			t.Errorf("expected the list to be shown as current, got %s", body)
+		}
+
+		if rec := post("/lists/archive?list="+home.ID.String(), url.Values{"ID": {home.ID.String()}}); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+		body = do(http.MethodGet, "/", "").Body.String()
+		if strings.Contains(body, "Deploy") || !strings.Contains(body, "Groceries") {
+			t.Errorf("expected the tasks of the archived list to be hidden, got %s", body)
+		}
+
+		rec = post("/lists/delete?list="+home.ID.String(), url.Values{"ID": {home.ID.String()}})
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+			t.Fatalf("expected a redirect to all tasks, got %d %q", rec.Code, rec.Header().Get("Location"))
+		}
+		if listOf(task.ID) != uuid.Nil {
+			t.Errorf("expected the task back in the inbox, got %s", listOf(task.ID))
+		}
+		req := httptest.NewRequest(http.MethodPost, "/lists/rename", strings.NewReader(url.Values{"ID": {home.ID.String()}, "name": {"Garden"}, csrfField: {csrfToken("secret")}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "list")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d renaming a deleted list, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+}
+
+func TestSubtasks(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path, fragment string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if fragment != "" {
+			req.Header.Set(fragmentHeader, fragment)
+		}
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(id uuid.UUID) store.Task {
+		task, _ := findTask(s, id)
+		return task
+	}
+
+	var parent, child store.Task
+	t.Run("api", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1/tasks", `{"Title":"Release","Priority":"High"}`)
+		json.NewDecoder(rec.Body).Decode(&parent)
+		rec = do(http.MethodPost, "/api/v1/tasks", `{"Title":"Tag","Priority":"Low","Parent":"`+parent.ID.String()+`"}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		}
+		json.NewDecoder(rec.Body).Decode(&child)
+		if get(child.ID).Parent != parent.ID {
+			t.Errorf("expected the task under %s, got %+v", parent.ID, get(child.ID))
+		}
+
+		if rec := do(http.MethodPost, "/api/v1/tasks/"+parent.ID.String()+"/parent", `{"Parent":"`+child.ID.String()+`"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d nesting a task under its subtask, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if rec := do(http.MethodPatch, "/api/v1/tasks/"+parent.ID.String(), `{"AutoComplete":true}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodPost, "/api/v1/tasks/"+child.ID.String()+"/toggle", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if !get(parent.ID).Done {
+			t.Error("expected the parent completed with its only subtask")
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		if rec := post("/add", "", url.Values{"title": {"Announce"}, "priority": {"Low"}, "parent": {parent.ID.String()}}); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+		if get(parent.ID).Done {
+			t.Error("expected the parent reopened by a new open subtask")
+		}
+		body := do(http.MethodGet, "/", "").Body.String()
+		if !strings.Contains(body, "1/2 subtasks done") || !strings.Contains(body, `data-depth="1"`) {
+			t.Errorf("expected the subtasks nested with the progress of the parent, got %s", body)
+		}
+
+		if rec := post("/nest", "", url.Values{"ID": {child.ID.String()}}); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+		if get(child.ID).Parent != uuid.Nil {
+			t.Errorf("expected the task at the top level, got %s", get(child.ID).Parent)
+		}
+		if rec := post("/nest", "list", url.Values{"ID": {parent.ID.String()}, "parent": {parent.ID.String()}}); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d nesting a task under itself, got %d", http.StatusBadRequest, rec.Code)
+		}
+
+		if rec := post("/delete", "", url.Values{"ID": {parent.ID.String()}, "subtasks": {"delete"}}); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+		if tasks, _ := s.GetAllItems(); len(tasks) != 1 || tasks[0].ID != child.ID {
+			t.Errorf("expected only the task moved out left, got %+v", tasks)
+		}
+	})
+}
+
+func TestDependencies(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "row")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	migration, deploy := uuid.New(), uuid.New()
+	for id, title := range map[uuid.UUID]string{migration: "Write migration", deploy: "Deploy"} {
+		if err := s.AddItem(id, title, store.High); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	tasksPath := "/api/v1/tasks/"
+
+	t.Run("api", func(t *testing.T) {
+		rec := do(http.MethodPost, tasksPath+deploy.String()+"/blockers", `{"Blocker":"`+migration.String()+`"}`)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodPost, tasksPath+migration.String()+"/blockers", `{"Blocker":"`+deploy.String()+`"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for a cycle, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if rec := do(http.MethodPost, tasksPath+deploy.String()+"/toggle", ""); rec.Code != http.StatusConflict {
+			t.Errorf("expected status %d completing a blocked task, got %d", http.StatusConflict, rec.Code)
+		}
+
+		var tasks []store.Task
+		json.NewDecoder(do(http.MethodGet, "/api/v1/tasks?status=ready", "").Body).Decode(&tasks)
+		if len(tasks) != 1 || tasks[0].ID != migration {
+			t.Errorf("expected only the migration to be ready, got %+v", tasks)
+		}
+		json.NewDecoder(do(http.MethodGet, "/api/v1/tasks?status=blocked", "").Body).Decode(&tasks)
+		if len(tasks) != 1 || !slices.Equal(tasks[0].BlockedBy, []uuid.UUID{migration}) {
+			t.Errorf("expected deploy blocked by the migration, got %+v", tasks)
+		}
+
+		if rec := do(http.MethodDelete, tasksPath+deploy.String()+"/blockers/"+migration.String(), ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if task, _ := findTask(s, deploy); len(task.BlockedBy) != 0 {
+			t.Errorf("expected no blockers, got %v", task.BlockedBy)
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		rec := post("/block", url.Values{"ID": {deploy.String()}, "blocker": {migration.String()}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "Blocked</span>") || !strings.Contains(body, `aria-label="Stop Deploy waiting on Write migration"`) {
+			t.Errorf("expected the row shown as blocked by the migration, got %s", body)
+		}
+		rec = post("/toggle", url.Values{"ID": {deploy.String()}})
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "finish them first") {
+			t.Errorf("expected the toggle to be refused, got %d %s", rec.Code, rec.Body)
+		}
+
+		if rec := post("/block", url.Values{"ID": {deploy.String()}, "blocker": {migration.String()}, "blocked": {"false"}}); rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		if rec := post("/toggle", url.Values{"ID": {deploy.String()}}); rec.Code != http.StatusOK {
+			t.Errorf("expected status %d once unblocked, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestRecurrence(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "row")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	plants, report := uuid.New(), uuid.New()
+	for id, title := range map[uuid.UUID]string{plants: "Water plants", report: "Send report"} {
+		if err := s.AddItem(id, title, store.Medium); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	tasksPath := "/api/v1/tasks/"
+
+	t.Run("api", func(t *testing.T) {
+		if rec := do(http.MethodPatch, tasksPath+plants.String(), `{"Repeat":"whenever"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an invalid rule, got %d", http.StatusBadRequest, rec.Code)
+		}
+		rec := do(http.MethodPatch, tasksPath+plants.String(), `{"Repeat":"every 2 days","Due":"2026-01-10T00:00:00Z"}`)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if task, _ := findTask(s, plants); task.Repeat != "FREQ=DAILY;INTERVAL=2" {
+			t.Errorf("expected the rule stored as an RRULE, got %q", task.Repeat)
+		}
+		if rec := do(http.MethodPost, tasksPath+plants.String()+"/toggle", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+
+		next, err := findTask(s, store.NextOccurrenceID(plants))
+		if err != nil {
+			t.Fatalf("expected the next occurrence, got %s", err)
+		}
+		if next.Done || next.Series != plants || next.Title != "Water plants" || !next.Due.After(time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("expected an open occurrence due later, got %+v", next)
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		rec := post("/update", url.Values{"ID": {report.String()}, "title": {"Send report"}, "priority": {"Medium"}, "repeat": {"sometimes"}})
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `id="repeat-error-`+report.String()+`"`) {
+			t.Errorf("expected the repeat field flagged, got %d %s", rec.Code, rec.Body)
+		}
+		rec = post("/update", url.Values{"ID": {report.String()}, "title": {"Send report"}, "priority": {"Medium"}, "repeat": {"RRULE:FREQ=MONTHLY;BYMONTHDAY=-1"}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "Repeats monthly on the last day") || !strings.Contains(body, `data-fragment="list"`) {
+			t.Errorf("expected the row shown as repeating, got %s", body)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/toggle", strings.NewReader(url.Values{"ID": {report.String()}, csrfField: {csrfToken("secret")}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "list")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "task-"+store.NextOccurrenceID(report).String()) {
+			t.Errorf("expected the list with the next occurrence, got %d %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestTrash(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	trash := func() []store.Task {
+		var tasks []store.Task
+		json.NewDecoder(do(http.MethodGet, "/api/v1/trash", "").Body).Decode(&tasks)
+		return tasks
+	}
+	taxes, dentist, garage := uuid.New(), uuid.New(), uuid.New()
+	for id, title := range map[uuid.UUID]string{taxes: "File taxes", dentist: "Call dentist", garage: "Clean garage"} {
+		if err := s.AddItem(id, title, store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	tasksPath := "/api/v1/tasks/"
+
+	t.Run("api", func(t *testing.T) {
+		if rec := do(http.MethodDelete, tasksPath+taxes.String(), ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodGet, tasksPath+taxes.String(), ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected a trashed task not found, got %d", rec.Code)
+		}
+		if tasks := trash(); len(tasks) != 1 || tasks[0].ID != taxes || tasks[0].Deleted.IsZero() {
+			t.Fatalf("expected the task in the trash, got %+v", tasks)
+		}
+
+		if rec := do(http.MethodPost, "/api/v1/trash/"+taxes.String()+"/restore", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if task, err := findTask(s, taxes); err != nil || !task.Deleted.IsZero() {
+			t.Errorf("expected the task restored, got %+v %v", task, err)
+		}
+		if rec := do(http.MethodPost, "/api/v1/trash/"+taxes.String()+"/restore", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d restoring a live task, got %d", http.StatusNotFound, rec.Code)
+		}
+
+		do(http.MethodDelete, tasksPath+taxes.String(), "")
+		if rec := do(http.MethodDelete, "/api/v1/trash/"+taxes.String(), ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if tasks := trash(); len(tasks) != 0 {
+			t.Errorf("expected the trash empty, got %+v", tasks)
+		}
+
+		do(http.MethodDelete, tasksPath+dentist.String(), "")
+		if rec := do(http.MethodDelete, "/api/v1/trash?before=yesterday", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an invalid time, got %d", http.StatusBadRequest, rec.Code)
+		}
+		do(http.MethodDelete, "/api/v1/trash?before="+url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339)), "")
+		if tasks := trash(); len(tasks) != 1 {
+			t.Errorf("expected a recent task kept, got %+v", tasks)
+		}
+		if rec := do(http.MethodDelete, "/api/v1/trash", ""); rec.Code != http.StatusNoContent || len(trash()) != 0 {
+			t.Errorf("expected the trash emptied, got %d %+v", rec.Code, trash())
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		if rec := post("/delete", url.Values{"ID": {garage.String()}}); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trash", nil))
+		if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, `aria-label="Restore Clean garage"`) {
+			t.Fatalf("expected the task on the trash page, got %d %s", rec.Code, body)
+		}
+
+		rec = post("/trash/restore", url.Values{"ID": {garage.String()}})
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/trash" {
+			t.Fatalf("expected a redirect to the trash, got %d %s", rec.Code, rec.Header().Get("Location"))
+		}
+		if _, err := findTask(s, garage); err != nil {
+			t.Errorf("expected the task restored, got %s", err)
+		}
+
+		post("/delete", url.Values{"ID": {garage.String()}})
+		post("/trash/purge", url.Values{"ID": {garage.String()}})
+		post("/trash/empty", url.Values{})
+		if tasks := trash(); len(tasks) != 0 {
+			t.Errorf("expected the trash empty, got %+v", tasks)
+		}
+	})
+}
+
+func TestUndo(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path, fragment string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, fragment)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	report := uuid.New()
+	if err := s.AddItem(report, "Write report", store.Medium); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	tasksPath := "/api/v1/tasks/"
+
+	t.Run("api", func(t *testing.T) {
+		if rec := do(http.MethodPatch, tasksPath+report.String(), `{"Title":"Write the report"}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodPost, "/api/v1/undo", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if task, _ := findTask(s, report); task.Title != "Write report" {
+			t.Errorf("expected the title reverted, got %q", task.Title)
+		}
+		if rec := do(http.MethodPost, "/api/v1/redo", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodPost, "/api/v1/redo", ""); rec.Code != http.StatusConflict {
+			t.Errorf("expected status %d with nothing to redo, got %d", http.StatusConflict, rec.Code)
+		}
+
+		do(http.MethodDelete, tasksPath+report.String(), "")
+		do(http.MethodDelete, "/api/v1/trash/"+report.String(), "")
+		rec := do(http.MethodPost, "/api/v1/undo", "")
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "changed since") {
+			t.Errorf("expected a conflict undoing the delete of a purged task, got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		draft := uuid.New()
+		if err := s.AddItem(draft, "Draft", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+		rec := post("/toggle", "row", url.Values{"ID": {draft.String()}})
+		if rec.Code != http.StatusOK || rec.Header().Get(flashHeader) == "" || rec.Header().Get(flashActionHeader) != "undo" {
+			t.Fatalf("expected the row with an offer to undo the change, got %d %v", rec.Code, rec.Header())
+		}
+
+		rec = post("/undo", "", url.Values{})
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+		if task, _ := findTask(s, draft); task.Done {
+			t.Error("expected the task open again")
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range rec.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		page := httptest.NewRecorder()
+		h.ServeHTTP(page, req)
+		if body := page.Body.String(); !strings.Contains(body, "Change undone") || !strings.Contains(body, `action="/redo"`) {
+			t.Errorf("expected the flash to offer redoing the change, got %s", body)
+		}
+
+		// A change that changed nothing isn't recorded, nor offered.
+		blocker := uuid.New()
+		if err := s.AddItem(blocker, "Review", store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+		if err := s.AddBlocker(draft, blocker); err != nil {
+			t.Fatalf("Error adding blocker: %s", err)
+		}
+		rec = post("/block", "row", url.Values{"ID": {draft.String()}, "blocker": {blocker.String()}})
+		if rec.Code != http.StatusOK || rec.Header().Get(flashHeader) == "" || rec.Header().Get(flashActionHeader) != "" {
+			t.Fatalf("expected the row without an offer to undo, got %d %v", rec.Code, rec.Header())
+		}
+		if err := s.Undo(); err != nil {
+			t.Fatalf("Error undoing: %s", err)
+		}
+		if tasks, _ := s.GetAllItems(); slices.ContainsFunc(tasks, func(t store.Task) bool { return t.ID == draft && len(t.BlockedBy) != 0 }) {
+			t.Error("expected undo to revert the earlier blocker, not the repeated one")
+		}
+	})
+}
+
+func TestArchive(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	titles := func(path string) []string {
+		var tasks []store.Task
+		json.NewDecoder(do(http.MethodGet, path, "").Body).Decode(&tasks)
+		var titles []string
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+	taxes, dentist, garage := uuid.New(), uuid.New(), uuid.New()
+	for _, task := range []store.Task{{ID: taxes, Title: "File taxes"}, {ID: dentist, Title: "Call dentist"}, {ID: garage, Title: "Clean garage"}} {
+		if err := s.AddItem(task.ID, task.Title, store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+		if err := s.ToggleDone(task.ID); err != nil {
+			t.Fatalf("Error completing task: %s", err)
+		}
+	}
+	tasksPath := "/api/v1/tasks/"
+
+	t.Run("api", func(t *testing.T) {
+		if rec := do(http.MethodPatch, tasksPath+taxes.String(), `{"Archived": true}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if got := titles("/api/v1/tasks"); slices.Contains(got, "File taxes") {
+			t.Errorf("expected the archived task left out, got %q", got)
+		}
+		if got := titles("/api/v1/tasks?q=taxes"); !slices.Equal(got, []string{"File taxes"}) {
+			t.Errorf("expected the archived task found by search, got %q", got)
+		}
+
+		if rec := do(http.MethodPost, "/api/v1/archive?before=yesterday", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an invalid time, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if rec := do(http.MethodPost, "/api/v1/archive", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if got := titles("/api/v1/tasks?status=archived"); len(got) != 3 {
+			t.Fatalf("expected every done task archived, got %q", got)
+		}
+
+		if rec := do(http.MethodPost, "/api/v1/unarchive?q=garage", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if got := titles("/api/v1/tasks"); !slices.Equal(got, []string{"Clean garage"}) {
+			t.Errorf("expected only the matching task unarchived, got %q", got)
+		}
+		do(http.MethodPost, "/api/v1/unarchive", "")
+		if got := titles("/api/v1/tasks?status=archived"); len(got) != 0 {
+			t.Errorf("expected every task unarchived, got %q", got)
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if !strings.Contains(rec.Body.String(), `aria-label="Archive File taxes"`) {
+			t.Fatalf("expected done tasks offered for archiving, got %s", rec.Body)
+		}
+		for _, id := range []uuid.UUID{taxes, dentist} {
+			if rec := post("/archive", url.Values{"ID": {id.String()}}); rec.Code != http.StatusSeeOther {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+			}
+		}
+		if got := titles("/api/v1/tasks?status=archived"); len(got) != 2 {
+			t.Fatalf("expected the tasks archived, got %q", got)
+		}
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?status=archived", nil))
+		if body := rec.Body.String(); !strings.Contains(body, `aria-label="Unarchive Call dentist"`) || !strings.Contains(body, "Unarchive all 2") {
+			t.Fatalf("expected the archived tasks listed, got %s", body)
+		}
+
+		rec = post("/unarchive?status=archived", url.Values{})
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/?status=archived" {
+			t.Fatalf("expected a redirect back to the archive, got %d %s", rec.Code, rec.Header().Get("Location"))
+		}
+		if got := titles("/api/v1/tasks?status=archived"); len(got) != 0 {
+			t.Errorf("expected the tasks unarchived, got %q", got)
+		}
+		if err := s.Undo(); err != nil {
+			t.Fatalf("expected unarchiving them undone at once, got %s", err)
+		}
+		if got := titles("/api/v1/tasks?status=archived"); len(got) != 2 {
+			t.Errorf("expected the tasks archived again, got %q", got)
+		}
+
+		if err := s.ToggleDone(garage); err != nil {
+			t.Fatalf("Error reopening task: %s", err)
+		}
+		form := url.Values{"ID": {garage.String()}, csrfField: {csrfToken("secret")}}
+		req := httptest.NewRequest(http.MethodPost, "/archive", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "list")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "Only done tasks can be archived") {
+			t.Errorf("expected an open task refused, got %d %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestTimeTracking(t *testing.T) {
+	s, _ := store.NewInMemoryStore(store.Config{LoadFromFile: false})
+	h := NewTaskServer(s).Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(path string, form url.Values, fragment string) *httptest.ResponseRecorder {
+		form.Set(csrfField, csrfToken("secret"))
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, fragment)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "secret"})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	report, invoice := uuid.New(), uuid.New()
+	for _, task := range []store.Task{{ID: report, Title: "Write report"}, {ID: invoice, Title: "Send invoice"}} {
+		if err := s.AddItem(task.ID, task.Title, store.Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	if err := s.AddTag(invoice, "billing"); err != nil {
+		t.Fatalf("Error tagging task: %s", err)
+	}
+
+	t.Run("api", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1/tasks/"+report.String()+"/timer", "")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		}
+		var running store.TimeEntry
+		json.NewDecoder(rec.Body).Decode(&running)
+		if !running.Running() || running.Task != report {
+			t.Fatalf("expected a running timer on the report, got %+v", running)
+		}
+		if rec := do(http.MethodPost, "/api/v1/tasks/"+invoice.String()+"/timer", ""); rec.Code != http.StatusConflict {
+			t.Errorf("expected status %d for a second timer, got %d", http.StatusConflict, rec.Code)
+		}
+		if rec := do(http.MethodDelete, "/api/v1/timer", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodDelete, "/api/v1/timer", ""); rec.Code != http.StatusConflict {
+			t.Errorf("expected status %d without a timer, got %d", http.StatusConflict, rec.Code)
+		}
+
+		start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+		body := fmt.Sprintf(`{"Task": %q, "Start": %q, "End": %q}`, invoice, start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339))
+		rec = do(http.MethodPost, "/api/v1/time", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		}
+		var added store.TimeEntry
+		json.NewDecoder(rec.Body).Decode(&added)
+		end := fmt.Sprintf(`{"End": %q}`, start.Add(-time.Hour).Format(time.RFC3339))
+		if rec := do(http.MethodPatch, "/api/v1/time/"+added.ID.String(), end); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an entry ending before it starts, got %d", http.StatusBadRequest, rec.Code)
+		}
+		end = fmt.Sprintf(`{"End": %q}`, start.Add(2*time.Hour).Format(time.RFC3339))
+		if rec := do(http.MethodPatch, "/api/v1/time/"+added.ID.String(), end); rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		if rec := do(http.MethodPatch, "/api/v1/time/"+uuid.NewString(), end); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for an unknown entry, got %d", http.StatusNotFound, rec.Code)
+		}
+
+		var entries []store.TimeEntry
+		json.NewDecoder(do(http.MethodGet, "/api/v1/time?task="+invoice.String(), "").Body).Decode(&entries)
+		if len(entries) != 1 || entries[0].Duration(time.Now()) != 2*time.Hour {
+			t.Fatalf("expected the invoice's edited entry, got %+v", entries)
+		}
+		var rows []store.ReportRow
+		json.NewDecoder(do(http.MethodGet, "/api/v1/report?by=tag", "").Body).Decode(&rows)
+		if len(rows) != 2 || rows[0].Key != "billing" || rows[0].Total != 2*time.Hour {
+			t.Errorf("expected the time reported by tag, got %+v", rows)
+		}
+		if rec := do(http.MethodGet, "/api/v1/report?by=week", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an unknown report, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if rec := do(http.MethodDelete, "/api/v1/time/"+running.ID.String(), ""); rec.Code != http.StatusNoContent {
+			t.Errorf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("web", func(t *testing.T) {
+		rec := post("/timer/start", url.Values{"ID": {report.String()}}, "row")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `aria-label="Stop the timer on Write report"`) {
+			t.Fatalf("expected the row with its timer running, got %d %s", rec.Code, rec.Body)
+		}
+		if rec.Header().Get(flashActionHeader) != "" {
+			t.Error("expected starting a timer not offered for undoing")
+		}
+		rec = post("/timer/start", url.Values{"ID": {invoice.String()}}, "row")
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "A timer is already running") {
+			t.Errorf("expected a second timer refused, got %d %s", rec.Code, rec.Body)
+		}
+		if rec := post("/timer/stop", url.Values{"ID": {report.String()}}, ""); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body)
+		}
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if !strings.Contains(rec.Body.String(), `<span class="time-spent">2h00m</span>`) {
+			t.Errorf("expected the time spent shown, got %s", rec.Body)
+		}
+
+		form := url.Values{"task": {report.String()}, "start": {"2026-03-02T09:00"}, "end": {"2026-03-02T10:30"}}
+		if rec := post("/time/add", form, ""); rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/time" {
+			t.Fatalf("expected a redirect back to the time page, got %d %s", rec.Code, rec.Header().Get("Location"))
+		}
+		entries, _ := s.GetTimeEntries()
+		i := slices.IndexFunc(entries, func(e store.TimeEntry) bool { return e.Start.Day() == 2 })
+		if i < 0 || entries[i].Duration(time.Now()) != 90*time.Minute {
+			t.Fatalf("expected the entry added, got %+v", entries)
+		}
+		form = url.Values{"ID": {entries[i].ID.String()}, "start": {"2026-03-02T09:00"}, "end": {"2026-03-02T08:00"}}
+		rec = post("/time/edit", form, "")
+		req := httptest.NewRequest(http.MethodGet, "/time", nil)
+		for _, c := range rec.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		page := httptest.NewRecorder()
+		h.ServeHTTP(page, req)
+		if body := page.Body.String(); !strings.Contains(body, "A time entry must end after it starts") {
+			t.Errorf("expected an entry ending before it starts refused, got %s", body)
+		}
+		if rec := post("/time/delete", url.Values{"ID": {entries[i].ID.String()}}, ""); rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d", http.StatusSeeOther, rec.Code)
+		}
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/time?by=day&from=2026-03-01", nil))
+		if body := rec.Body.String(); rec.Code != http.StatusOK || strings.Contains(body, "2026-03-02") || !strings.Contains(body, "Send invoice") {
+			t.Errorf("expected the time page with the entries left, got %d %s", rec.Code, body)
+		}
+	})
+}
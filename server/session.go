@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"todoapp/store"
+)
+
+const (
+	sessionCookie   = "session"
+	sessionLifetime = 7 * 24 * time.Hour
+)
+
+type webUserKey struct{}
+
+// webUser is the logged-in user of a web request.
+type webUser struct {
+	session store.Session
+	store   store.Store
+}
+
+// storeFor returns the store of the user logged in for r, or the server's
+// store when it doesn't have accounts.
+func (s *TaskServer) storeFor(r *http.Request) store.Store {
+	if u, ok := r.Context().Value(webUserKey{}).(*webUser); ok {
+		return u.store
+	}
+	return s.store
+}
+
+func userName(r *http.Request) string {
+	if u, ok := r.Context().Value(webUserKey{}).(*webUser); ok {
+		return u.session.User
+	}
+	return ""
+}
+
+// loggedIn serves h to users with a session cookie when the server has
+// accounts, sending everyone else to the login page.
+func (s *TaskServer) loggedIn(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.accounts == nil {
+			h(w, r)
+			return
+		}
+		var session store.Session
+		c, err := r.Cookie(sessionCookie)
+		if err == nil {
+			session, err = s.accounts.GetSession(c.Value)
+		}
+		if err != nil {
+			if r.Header.Get(fragmentHeader) != "" || r.URL.Path == "/events" {
+				http.Error(w, "Not logged in", http.StatusUnauthorized)
+				return
+			}
+			next := "/" + query(r)
+			if r.Method == http.MethodGet {
+				next = r.URL.RequestURI()
+			}
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(next), http.StatusSeeOther)
+			return
+		}
+		st, err := s.stores.ForUser(session.User)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Error loading tasks", http.StatusInternalServerError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), webUserKey{}, &webUser{session: session, store: st})
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// accountPage is the data of the login and registration pages.
+type accountPage struct {
+	Register bool
+	Name     string
+	Next     string
+	Error    string
+	Flash    *flash
+	CSRF     string
+}
+
+func (s *TaskServer) renderAccountPage(w http.ResponseWriter, r *http.Request, status int, p accountPage) {
+	tmpl, err := s.assets.template()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+	p.CSRF = csrfToken(csrfSecret(w, r))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.ExecuteTemplate(w, "account.html", p); err != nil {
+		log.Println(err)
+	}
+}
+
+// safeNext returns where to go after logging in, allowing only paths on this
+// server.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func (s *TaskServer) loginPage(w http.ResponseWriter, r *http.Request) {
+	s.renderAccountPage(w, r, http.StatusOK, accountPage{Next: safeNext(r.URL.Query().Get("next")), Flash: takeFlash(w, r)})
+}
+
+func (s *TaskServer) registerPage(w http.ResponseWriter, r *http.Request) {
+	s.renderAccountPage(w, r, http.StatusOK, accountPage{Register: true, Next: safeNext(r.URL.Query().Get("next"))})
+}
+
+func (s *TaskServer) webLogin(w http.ResponseWriter, r *http.Request) {
+	p := accountPage{Name: r.PostFormValue("name"), Next: safeNext(r.PostFormValue("next"))}
+	session, err := s.login(p.Name, r.PostFormValue("password"), sessionLifetime)
+	if errors.Is(err, errInvalidCredentials) {
+		p.Error = "Invalid user name or password"
+		s.renderAccountPage(w, r, http.StatusUnauthorized, p)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+	s.startSession(w, r, session, p.Next)
+}
+
+func (s *TaskServer) webRegister(w http.ResponseWriter, r *http.Request) {
+	p := accountPage{Register: true, Name: r.PostFormValue("name"), Next: safeNext(r.PostFormValue("next"))}
+	password := r.PostFormValue("password")
+	if password != r.PostFormValue("confirm") {
+		p.Error = "Passwords don't match"
+		s.renderAccountPage(w, r, http.StatusBadRequest, p)
+		return
+	}
+	if err := s.register(p.Name, password); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, store.ErrUserExists) {
+			status = http.StatusConflict
+		}
+		p.Error = err.Error()
+		s.renderAccountPage(w, r, status, p)
+		return
+	}
+	session, err := s.login(p.Name, password, sessionLifetime)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+	s.startSession(w, r, session, p.Next)
+}
+
+func (s *TaskServer) startSession(w http.ResponseWriter, r *http.Request, session store.Session, next string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (s *TaskServer) webLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if err := s.accounts.DeleteSession(c.Value); err != nil {
+			log.Println(err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	setFlash(w, flash{Kind: "success", Message: "Logged out"})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
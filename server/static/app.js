@@ -0,0 +1,352 @@
+// Submits forms marked with data-fragment in the background and swaps the
+// returned HTML into the page, reloads the list when another tab or user
+// changes a task, lets tasks be reordered by dragging them and adds keyboard
+// shortcuts. Without JavaScript the forms post normally.
+(function () {
+    "use strict";
+
+    const status = document.getElementById("status");
+    document.documentElement.classList.add("js");
+
+    function showError(err) {
+        status.textContent = err.message;
+        status.className = "flash error";
+    }
+
+    // showFlash shows the message a change came back with, and a button to
+    // undo or redo it.
+    function showFlash(response) {
+        const message = response.headers.get("X-Flash");
+        if (!message) {
+            return;
+        }
+        status.textContent = message;
+        status.className = "flash success";
+        const action = response.headers.get("X-Flash-Action");
+        if (action) {
+            const form = document.getElementById("flash-action").content.firstElementChild.cloneNode(true);
+            form.action = "/" + action + location.search;
+            form.querySelector("button").textContent = action === "undo" ? "Undo" : "Redo";
+            status.append(" ", form);
+        }
+    }
+
+    function parse(html) {
+        const template = document.createElement("template");
+        template.innerHTML = html.trim();
+        return template.content;
+    }
+
+    function focusKey(element) {
+        const form = element && element.form;
+        if (!form) {
+            return null;
+        }
+        return {action: form.getAttribute("action"), name: element.name, tag: element.tagName};
+    }
+
+    function restoreFocus(container, key) {
+        if (!key) {
+            return;
+        }
+        for (const form of container.querySelectorAll("form")) {
+            if (form.getAttribute("action") !== key.action) {
+                continue;
+            }
+            const element = key.name ? form.elements.namedItem(key.name) : form.querySelector(key.tag);
+            if (element) {
+                element.focus();
+            }
+            return;
+        }
+    }
+
+    async function submit(form) {
+        const fragment = form.dataset.fragment;
+        const response = await fetch(form.action, {
+            method: "POST",
+            body: new URLSearchParams(new FormData(form)),
+            headers: {"X-Fragment": fragment},
+        });
+        const row = form.closest("tr");
+        if (response.status === 422 && fragment === "row") {
+            const replacement = parse(await response.text()).firstElementChild;
+            row.replaceWith(replacement);
+            const invalid = replacement.querySelector("[aria-invalid=true]");
+            if (invalid) {
+                invalid.focus();
+            }
+            throw new Error("Task not saved, check the highlighted fields");
+        }
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || response.statusText);
+        }
+        showFlash(response);
+
+        const key = focusKey(document.activeElement);
+        const rowFocused = row && document.activeElement === row;
+        if (fragment === "list") {
+            const index = rows().indexOf(row);
+            const label = document.activeElement && document.activeElement.getAttribute("aria-label");
+            document.getElementById("tasks").replaceWith(parse(await response.text()));
+            form.reset();
+            if (row) {
+                focusAfterListChange(row.id, index, label);
+            } else {
+                document.getElementById("new-title").focus();
+            }
+            return;
+        }
+        if (response.status === 204) {
+            const next = row.nextElementSibling || row.previousElementSibling;
+            row.remove();
+            (next && next.id ? next : document.getElementById("tasks")).focus();
+            return;
+        }
+        const replacement = parse(await response.text()).firstElementChild;
+        row.replaceWith(replacement);
+        if (rowFocused) {
+            replacement.focus();
+        } else {
+            restoreFocus(replacement, key);
+        }
+    }
+
+    function rows() {
+        return Array.from(document.querySelectorAll("#tasks tbody tr[id]"));
+    }
+
+    // focusAfterListChange keeps the focus on the move button just used, or
+    // on the other one once the task has reached the top or bottom. Once the
+    // task is gone, the task that took its place gets the focus.
+    function focusAfterListChange(rowID, index, label) {
+        const row = document.getElementById(rowID);
+        if (!row) {
+            const remaining = rows();
+            const next = remaining[Math.min(index, remaining.length - 1)];
+            (next || document.getElementById("tasks")).focus();
+            return;
+        }
+        const button = (label && row.querySelector(`.move button[aria-label="${CSS.escape(label)}"]:not([disabled])`)) ||
+            row.querySelector(".move button[aria-label]:not([disabled])");
+        (button || row).focus();
+    }
+
+    async function move(row, target, place) {
+        const form = row.querySelector(".move form");
+        const body = new URLSearchParams({
+            ID: form.elements.namedItem("ID").value,
+            csrf: form.elements.namedItem("csrf").value,
+            [place]: target.id.replace(/^task-/, ""),
+        });
+        const response = await fetch(form.action, {method: "POST", body: body, headers: {"X-Fragment": "list"}});
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || response.statusText);
+        }
+        showFlash(response);
+        document.getElementById("tasks").replaceWith(parse(await response.text()));
+        const moved = document.getElementById(row.id);
+        if (moved) {
+            moved.focus();
+        }
+    }
+
+    // refreshList reloads the task list, keeping the focus and any text
+    // being typed in a row that still exists.
+    async function refreshList() {
+        const response = await fetch(location.pathname + location.search, {headers: {"X-Fragment": "list"}});
+        if (!response.ok) {
+            return;
+        }
+        const list = parse(await response.text()).firstElementChild;
+
+        const active = document.activeElement;
+        const row = active && active.closest("#tasks tr");
+        const key = focusKey(active);
+        document.getElementById("tasks").replaceWith(list);
+        if (!row || !row.id) {
+            return;
+        }
+        const replacement = document.getElementById(row.id);
+        if (!replacement) {
+            return;
+        }
+        if (active === row) {
+            replacement.focus();
+            return;
+        }
+        restoreFocus(replacement, key);
+        const focused = document.activeElement;
+        if (focused !== active && focused.name === active.name && "value" in active) {
+            focused.value = active.value;
+        }
+    }
+
+    let pending = null;
+    let stale = false;
+
+    function scheduleRefresh() {
+        if (pending) {
+            stale = true;
+            return;
+        }
+        pending = setTimeout(function () {
+            refreshList().finally(function () {
+                pending = null;
+                if (stale) {
+                    stale = false;
+                    scheduleRefresh();
+                }
+            });
+        }, 100);
+    }
+
+    if (window.EventSource) {
+        const events = new EventSource("/events");
+        events.addEventListener("change", scheduleRefresh);
+        events.addEventListener("reset", scheduleRefresh);
+    }
+
+    document.addEventListener("submit", function (event) {
+        const form = event.target;
+        if (!form.dataset.fragment) {
+            return;
+        }
+        event.preventDefault();
+        status.textContent = "";
+        status.className = "";
+        submit(form).catch(showError);
+    });
+
+    // Keyboard shortcuts, ignored while typing in a field. The current task
+    // is the row holding the focus.
+    const shortcuts = {
+        j: function (row) {
+            focusRow(row ? row.nextElementSibling : rows()[0]);
+        },
+        k: function (row) {
+            focusRow(row ? row.previousElementSibling : rows().at(-1));
+        },
+        x: function (row) {
+            const toggle = row && row.querySelector('form[action^="/toggle"]');
+            if (toggle) {
+                toggle.requestSubmit();
+            }
+        },
+        e: function (row) {
+            const title = row && row.querySelector('input[name="title"]');
+            if (title) {
+                title.focus();
+                title.select();
+            }
+        },
+        "/": function () {
+            document.getElementById("search").focus();
+        },
+        n: function () {
+            document.getElementById("new-title").focus();
+        },
+    };
+
+    function focusRow(row) {
+        if (row && row.id) {
+            row.focus();
+            row.scrollIntoView({block: "nearest"});
+        }
+    }
+
+    function typing(element) {
+        return element && (element.isContentEditable || ["INPUT", "SELECT", "TEXTAREA"].includes(element.tagName));
+    }
+
+    document.addEventListener("keydown", function (event) {
+        const active = document.activeElement;
+        const row = active && active.closest("#tasks tbody tr[id]");
+        if (event.key === "Escape" && row && typing(active)) {
+            row.focus();
+            return;
+        }
+        const shortcut = shortcuts[event.key];
+        if (!shortcut || typing(active) || event.ctrlKey || event.metaKey || event.altKey) {
+            return;
+        }
+        event.preventDefault();
+        shortcut(row);
+    });
+
+    // Rows only become draggable while the drag handle is held, so that
+    // text in their fields can still be selected.
+    let dragged = null;
+
+    function dropTarget(event) {
+        const row = event.target.closest && event.target.closest("#tasks tr[data-movable]");
+        // Dragging only reorders subtasks of the same parent.
+        if (!dragged || !row || row === dragged || row.dataset.parent !== dragged.dataset.parent) {
+            return null;
+        }
+        const box = row.getBoundingClientRect();
+        return {row: row, place: event.clientY < box.top + box.height / 2 ? "before" : "after"};
+    }
+
+    function clearDropMarks() {
+        for (const row of document.querySelectorAll(".drop-before, .drop-after")) {
+            row.classList.remove("drop-before", "drop-after");
+        }
+    }
+
+    document.addEventListener("mousedown", function (event) {
+        const handle = event.target.closest(".drag-handle");
+        if (handle) {
+            handle.closest("tr").draggable = true;
+        }
+    });
+
+    document.addEventListener("mouseup", function () {
+        if (!dragged) {
+            for (const row of document.querySelectorAll("#tasks tr[draggable=true]")) {
+                row.draggable = false;
+            }
+        }
+    });
+
+    document.addEventListener("dragstart", function (event) {
+        const row = event.target.closest && event.target.closest("#tasks tr[data-movable]");
+        if (!row || !row.draggable) {
+            return;
+        }
+        dragged = row;
+        row.classList.add("dragging");
+        event.dataTransfer.effectAllowed = "move";
+        event.dataTransfer.setData("text/plain", row.id);
+    });
+
+    document.addEventListener("dragover", function (event) {
+        const target = dropTarget(event);
+        if (!target) {
+            return;
+        }
+        event.preventDefault();
+        clearDropMarks();
+        target.row.classList.add("drop-" + target.place);
+    });
+
+    document.addEventListener("drop", function (event) {
+        const target = dropTarget(event);
+        if (!target) {
+            return;
+        }
+        event.preventDefault();
+        status.textContent = "";
+        status.className = "";
+        move(dragged, target.row, target.place).catch(showError);
+    });
+
+    document.addEventListener("dragend", function () {
+        clearDropMarks();
+        if (dragged) {
+            dragged.classList.remove("dragging");
+            dragged.draggable = false;
+            dragged = null;
+        }
+    });
+})();
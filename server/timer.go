@@ -0,0 +1,406 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+// entryLayout is the format of datetime-local inputs.
+const entryLayout = "2006-01-02T15:04"
+
+// apiTimeEntries lists the time entries, most recent first, only those of
+// the task given as "task" if there is one.
+func (s *TaskServer) apiTimeEntries(w http.ResponseWriter, r *http.Request, st store.Store) {
+	entries, err := st.GetTimeEntries()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if v := r.URL.Query().Get("task"); v != "" {
+		task, err := uuid.Parse(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid task ID"})
+			return
+		}
+		entries = slices.DeleteFunc(entries, func(e store.TimeEntry) bool { return e.Task != task })
+	}
+	if entries == nil {
+		entries = []store.TimeEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *TaskServer) apiAddTimeEntry(w http.ResponseWriter, r *http.Request, st store.Store) {
+	var e store.TimeEntry
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if err := st.AddTimeEntry(e); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, e)
+}
+
+// apiEditTimeEntry changes the Task, Start or End of a time entry.
+func (s *TaskServer) apiEditTimeEntry(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := entryID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Task  *uuid.UUID `json:"Task"`
+		Start *time.Time `json:"Start"`
+		End   *time.Time `json:"End"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	e, err := findEntry(st, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Task != nil {
+		e.Task = *body.Task
+	}
+	if body.Start != nil {
+		e.Start = *body.Start
+	}
+	if body.End != nil {
+		e.End = *body.End
+	}
+	if err := st.EditTimeEntry(e); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+func (s *TaskServer) apiDeleteTimeEntry(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := entryID(w, r)
+	if !ok {
+		return
+	}
+	if err := st.DeleteTimeEntry(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiStartTimer starts a timer on a task, as the entry given as ID if
+// there is one.
+func (s *TaskServer) apiStartTimer(w http.ResponseWriter, r *http.Request, st store.Store) {
+	task, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		ID uuid.UUID `json:"ID"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+			return
+		}
+	}
+	if body.ID == uuid.Nil {
+		body.ID = uuid.New()
+	}
+	if err := st.StartTimer(body.ID, task); err != nil {
+		writeError(w, err)
+		return
+	}
+	e, err := findEntry(st, body.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, e)
+}
+
+func (s *TaskServer) apiStopTimer(w http.ResponseWriter, r *http.Request, st store.Store) {
+	if err := st.StopTimer(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiReport totals the time tracked "by" task, tag or day, from and to the
+// RFC 3339 times given, if any.
+func (s *TaskServer) apiReport(w http.ResponseWriter, r *http.Request, st store.Store) {
+	values := r.URL.Query()
+	by := cmp.Or(values.Get("by"), "task")
+	if !slices.Contains(store.ReportKinds, by) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "by must be task, tag or day"})
+		return
+	}
+	var bounds [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		if v := values.Get(name); v != "" {
+			var err error
+			if bounds[i], err = time.Parse(time.RFC3339, v); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: name + " must be an RFC 3339 time"})
+				return
+			}
+		}
+	}
+	rows, err := timeReport(st, by, bounds[0], bounds[1])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if rows == nil {
+		rows = []store.ReportRow{}
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+// timeReport totals the time tracked in st, counting the tasks in the trash.
+// Days are those of the server's time zone.
+func timeReport(st store.Store, by string, from, to time.Time) ([]store.ReportRow, error) {
+	entries, err := st.GetTimeEntries()
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := st.GetAllItems()
+	if err != nil {
+		return nil, err
+	}
+	trash, err := st.GetTrash()
+	if err != nil {
+		return nil, err
+	}
+	return store.TimeReport(slices.Concat(tasks, trash), entries, by, from, to, time.Now(), time.Local), nil
+}
+
+func entryID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid time entry ID"})
+		return uuid.UUID{}, false
+	}
+	return id, true
+}
+
+func findEntry(st store.Store, id uuid.UUID) (store.TimeEntry, error) {
+	entries, err := st.GetTimeEntries()
+	if err != nil {
+		return store.TimeEntry{}, err
+	}
+	if i := slices.IndexFunc(entries, func(e store.TimeEntry) bool { return e.ID == id }); i >= 0 {
+		return entries[i], nil
+	}
+	return store.TimeEntry{}, store.ErrEntryNotFound
+}
+
+// timer starts a timer on a task from the web page or, with stop set, stops
+// the running one.
+func (s *TaskServer) timer(stop bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			s.failed(w, r, http.StatusBadRequest, "Error parsing form")
+			return
+		}
+
+		taskID, err := ParseID(r)
+		if err != nil {
+			s.failed(w, r, http.StatusBadRequest, "Invalid task ID")
+			return
+		}
+
+		message := "Timer started"
+		if stop {
+			err = s.storeFor(r).StopTimer()
+			message = "Timer stopped"
+		} else {
+			err = s.storeFor(r).StartTimer(uuid.New(), taskID)
+		}
+		if err != nil {
+			status, message := storeStatus(err)
+			s.failed(w, r, status, cmp.Or(message, "Error changing the timer"))
+			return
+		}
+		// Time tracked isn't undone with the changes to the tasks.
+		s.flashed(w, r, taskID, flash{Kind: "success", Message: message})
+	}
+}
+
+// timePage is the data of the time page: the time entries with the title
+// of their task, the tasks time can be added to, and the report by By
+// from From to To, dates given in the page's query.
+type timePage struct {
+	Entries []entryRow
+	Tasks   []store.Task
+	Report  []store.ReportRow
+	Total   time.Duration
+	By      string
+	From    string
+	To      string
+	Flash   *flash
+	User    string
+	CSRF    string
+}
+
+type entryRow struct {
+	store.TimeEntry
+	Title string
+	Spent time.Duration
+}
+
+func (s *TaskServer) timePage(w http.ResponseWriter, r *http.Request) {
+	st := s.storeFor(r)
+	values := r.URL.Query()
+	p := timePage{
+		By:   values.Get("by"),
+		From: values.Get("from"),
+		To:   values.Get("to"),
+		User: userName(r),
+	}
+	if !slices.Contains(store.ReportKinds, p.By) {
+		p.By = "task"
+	}
+	// The report runs from the start of From to the end of To.
+	from, _ := time.ParseInLocation(time.DateOnly, p.From, time.Local)
+	to, err := time.ParseInLocation(time.DateOnly, p.To, time.Local)
+	if err == nil {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	entries, err := st.GetTimeEntries()
+	if err == nil {
+		p.Tasks, err = st.GetAllItems()
+	}
+	var trash []store.Task
+	if err == nil {
+		trash, err = st.GetTrash()
+	}
+	if err != nil {
+		log.Println("Error loading time entries")
+		http.Error(w, "Error loading time entries", http.StatusInternalServerError)
+		return
+	}
+	all, now := slices.Concat(p.Tasks, trash), time.Now()
+	for _, e := range entries {
+		rw := entryRow{TimeEntry: e, Spent: e.Duration(now)}
+		if i := slices.IndexFunc(all, func(t store.Task) bool { return t.ID == e.Task }); i >= 0 {
+			rw.Title = all[i].Title
+		}
+		p.Entries = append(p.Entries, rw)
+	}
+	p.Report = store.TimeReport(all, entries, p.By, from, to, now, time.Local)
+	// Tasks with several tags count towards each of them, so tags have no
+	// total.
+	if p.By != "tag" {
+		for _, row := range p.Report {
+			p.Total += row.Total
+		}
+	}
+
+	tmpl, err := s.assets.template()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+	p.Flash = takeFlash(w, r)
+	p.CSRF = csrfToken(csrfSecret(w, r))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(w, "time.html", p); err != nil {
+		log.Println(err)
+	}
+}
+
+// backToTime shows the time page again with a flash message.
+func backToTime(w http.ResponseWriter, r *http.Request, f flash) {
+	setFlash(w, f)
+	http.Redirect(w, r, "/time", http.StatusSeeOther)
+}
+
+// entryAction adds, edits or deletes a time entry from the time page.
+func (s *TaskServer) entryAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			backToTime(w, r, flash{Kind: "error", Message: "Error parsing form"})
+			return
+		}
+
+		st := s.storeFor(r)
+		var message string
+		var err error
+		switch action {
+		case "add":
+			var e store.TimeEntry
+			if e.Task, err = uuid.Parse(r.PostFormValue("task")); err != nil {
+				backToTime(w, r, flash{Kind: "error", Message: "Choose a task"})
+				return
+			}
+			if e.Start, e.End, err = entryTimes(r); err != nil {
+				backToTime(w, r, flash{Kind: "error", Message: "Start and end must be dates and times"})
+				return
+			}
+			e.ID, message = uuid.New(), "Time entry added"
+			err = st.AddTimeEntry(e)
+		case "edit":
+			var e store.TimeEntry
+			if e, err = formEntry(st, r); err != nil {
+				break
+			}
+			if e.Start, e.End, err = entryTimes(r); err != nil {
+				backToTime(w, r, flash{Kind: "error", Message: "Start and end must be dates and times"})
+				return
+			}
+			message = "Time entry saved"
+			err = st.EditTimeEntry(e)
+		case "delete":
+			var id uuid.UUID
+			if id, err = ParseID(r); err != nil {
+				err = store.ErrEntryNotFound
+				break
+			}
+			message = "Time entry deleted"
+			err = st.DeleteTimeEntry(id)
+		}
+		if err != nil {
+			_, m := storeStatus(err)
+			backToTime(w, r, flash{Kind: "error", Message: cmp.Or(m, "Error changing the time entries")})
+			return
+		}
+		backToTime(w, r, flash{Kind: "success", Message: message})
+	}
+}
+
+// formEntry returns the time entry whose ID was posted.
+func formEntry(st store.Store, r *http.Request) (store.TimeEntry, error) {
+	id, err := ParseID(r)
+	if err != nil {
+		return store.TimeEntry{}, store.ErrEntryNotFound
+	}
+	return findEntry(st, id)
+}
+
+// entryTimes parses the start and end posted from datetime-local inputs,
+// in the server's time zone. An empty end leaves the entry running.
+func entryTimes(r *http.Request) (start, end time.Time, err error) {
+	if start, err = time.ParseInLocation(entryLayout, r.PostFormValue("start"), time.Local); err != nil {
+		return start, end, err
+	}
+	if v := r.PostFormValue("end"); v != "" {
+		end, err = time.ParseInLocation(entryLayout, v, time.Local)
+	}
+	return start, end, err
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"cmp"
+	"log"
+	"net/http"
+	"time"
+	"todoapp/store"
+)
+
+func (s *TaskServer) apiTrash(w http.ResponseWriter, r *http.Request, st store.Store) {
+	trash, err := st.GetTrash()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if trash == nil {
+		trash = []store.Task{}
+	}
+	writeJSON(w, http.StatusOK, trash)
+}
+
+func (s *TaskServer) apiRestoreTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := st.RestoreTask(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TaskServer) apiPurgeTask(w http.ResponseWriter, r *http.Request, st store.Store) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := st.PurgeTask(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiEmptyTrash deletes the trashed tasks for good, only those deleted before
+// the "before" time if given.
+func (s *TaskServer) apiEmptyTrash(w http.ResponseWriter, r *http.Request, st store.Store) {
+	var before time.Time
+	if v := r.URL.Query().Get("before"); v != "" {
+		var err error
+		if before, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "before must be an RFC 3339 time"})
+			return
+		}
+	}
+	if err := st.EmptyTrash(before); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// trashPage is the data of the trash page.
+type trashPage struct {
+	Tasks []store.Task
+	Flash *flash
+	User  string
+	CSRF  string
+}
+
+func (s *TaskServer) trash(w http.ResponseWriter, r *http.Request) {
+	trash, err := s.storeFor(r).GetTrash()
+	if err != nil {
+		log.Println("Error loading the trash")
+		http.Error(w, "Error loading the trash", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := s.assets.template()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+
+	p := trashPage{
+		Tasks: trash,
+		Flash: takeFlash(w, r),
+		User:  userName(r),
+		CSRF:  csrfToken(csrfSecret(w, r)),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(w, "trash.html", p); err != nil {
+		log.Println(err)
+	}
+}
+
+// backToTrash shows the trash page again with a flash message.
+func backToTrash(w http.ResponseWriter, r *http.Request, f flash) {
+	setFlash(w, f)
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// trashAction restores a trashed task or, with purge set, deletes it for good.
+func (s *TaskServer) trashAction(purge bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			backToTrash(w, r, flash{Kind: "error", Message: "Error parsing form"})
+			return
+		}
+
+		id, err := ParseID(r)
+		if err != nil {
+			backToTrash(w, r, flash{Kind: "error", Message: "Invalid task ID"})
+			return
+		}
+
+		st := s.storeFor(r)
+		message := "Task restored"
+		if purge {
+			err = st.PurgeTask(id)
+			message = "Task deleted for good"
+		} else {
+			err = st.RestoreTask(id)
+		}
+		if err != nil {
+			_, m := storeStatus(err)
+			backToTrash(w, r, flash{Kind: "error", Message: cmp.Or(m, "Error changing the trash")})
+			return
+		}
+		backToTrash(w, r, flash{Kind: "success", Message: message})
+	}
+}
+
+func (s *TaskServer) emptyTrash(w http.ResponseWriter, r *http.Request) {
+	if err := s.storeFor(r).EmptyTrash(time.Time{}); err != nil {
+		_, m := storeStatus(err)
+		backToTrash(w, r, flash{Kind: "error", Message: cmp.Or(m, "Error emptying the trash")})
+		return
+	}
+	backToTrash(w, r, flash{Kind: "success", Message: "Trash emptied"})
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"todoapp/store"
+
+	"github.com/google/uuid"
+)
+
+func (s *TaskServer) apiUndo(w http.ResponseWriter, r *http.Request, st store.Store) {
+	if err := st.Undo(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TaskServer) apiRedo(w http.ResponseWriter, r *http.Request, st store.Store) {
+	if err := st.Redo(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// undo reverts the last change from the web page, or with redo set makes
+// the last one undone again, offering to go back the other way.
+func (s *TaskServer) undo(redo bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := s.storeFor(r)
+		f := flash{Kind: "success", Message: "Change undone", Action: "redo"}
+		var err error
+		if redo {
+			err = st.Redo()
+			f.Message, f.Action = "Change redone", "undo"
+		} else {
+			err = st.Undo()
+		}
+		if err != nil {
+			status, message := storeStatus(err)
+			s.failed(w, r, status, cmp.Or(message, "Error undoing the change"))
+			return
+		}
+		s.flashed(w, r, uuid.Nil, f)
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"todoapp/store"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 2000
+)
+
+// fieldErrors maps the name of an invalid task field to what is wrong with
+// it.
+type fieldErrors map[string]string
+
+func (e fieldErrors) Error() string {
+	for _, field := range []string{"Title", "Priority", "Description", "Due", "Tags", "Repeat"} {
+		if msg, ok := e[field]; ok {
+			return msg
+		}
+	}
+	return "invalid task"
+}
+
+func validateTask(t store.Task) fieldErrors {
+	errs := fieldErrors{}
+	switch n := utf8.RuneCountInString(t.Title); {
+	case n == 0:
+		errs["Title"] = "Title is required"
+	case n > maxTitleLength:
+		errs["Title"] = fmt.Sprintf("Title must be at most %d characters", maxTitleLength)
+	}
+	if !t.Priority.Valid() {
+		errs["Priority"] = "Priority must be Low, Medium or High"
+	}
+	if utf8.RuneCountInString(t.Description) > maxDescriptionLength {
+		errs["Description"] = fmt.Sprintf("Description must be at most %d characters", maxDescriptionLength)
+	}
+	for _, tag := range t.Tags {
+		if normalized, ok := store.NormalizeTag(tag); !ok || normalized != tag {
+			errs["Tags"] = fmt.Sprintf("Tag %q may only contain letters, digits, '_', '.' and '-'", tag)
+			break
+		}
+	}
+	if _, ok := store.NormalizeRecurrence(t.Repeat); !ok {
+		errs["Repeat"] = "Repeat must be a rule like daily, weekly on mon,thu, every 2 weeks or monthly on day 15"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// normalizeTags normalizes tags, sorted and without duplicates. Invalid tags
+// are kept as they are for validateTask to report.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		if n, ok := store.NormalizeTag(tag); ok {
+			tag = n
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// splitTags reads tags separated by spaces or commas.
+func splitTags(s string) []string {
+	return normalizeTags(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}))
+}
+
+// tagOperations returns the operations changing the tags of a task from
+// before to after.
+func tagOperations(id uuid.UUID, before, after []string) []store.TaskOperation {
+	var ops []store.TaskOperation
+	for _, tag := range after {
+		if !slices.Contains(before, tag) {
+			ops = append(ops, store.TaskOperation{Type: "AddTag", ID: id, Tag: tag})
+		}
+	}
+	for _, tag := range before {
+		if !slices.Contains(after, tag) {
+			ops = append(ops, store.TaskOperation{Type: "RemoveTag", ID: id, Tag: tag})
+		}
+	}
+	return ops
+}
+
+// addOperations returns the operations adding task with all its fields,
+// just the Add when it only has the fields AddItem takes.
+func addOperations(task store.Task) []store.TaskOperation {
+	ops := []store.TaskOperation{{Type: "Add", ID: task.ID, Title: task.Title, Priority: task.Priority}}
+	if task.Description != "" || !task.Due.IsZero() {
+		ops = append(ops, store.UpdateOperation(task))
+	}
+	if task.List != uuid.Nil {
+		ops = append(ops, store.TaskOperation{Type: "SetList", ID: task.ID, List: task.List})
+	}
+	if task.Parent != uuid.Nil {
+		ops = append(ops, store.TaskOperation{Type: "SetParent", ID: task.ID, Parent: task.Parent})
+	}
+	if task.AutoComplete {
+		ops = append(ops, store.TaskOperation{Type: "SetAutoComplete", ID: task.ID, AutoComplete: true})
+	}
+	if task.Repeat != "" {
+		ops = append(ops, store.TaskOperation{Type: "SetRepeat", ID: task.ID, Repeat: task.Repeat})
+	}
+	return append(ops, tagOperations(task.ID, nil, task.Tags)...)
+}
+
+// dateOnly drops the time of day from a due date.
+func dateOnly(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
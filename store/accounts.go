@@ -0,0 +1,33 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUserExists      = errors.New("user already exists")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+type Accounts interface {
+	AddUser(user User) error
+	GetUser(name string) (User, error)
+	// Users returns the names of all users, sorted.
+	Users() ([]string, error)
+	AddSession(session Session) error
+	GetSession(token string) (Session, error)
+	DeleteSession(token string) error
+}
+
+type User struct {
+	Name         string
+	PasswordHash string
+}
+
+type Session struct {
+	Token   string
+	User    string
+	Expires time.Time
+}
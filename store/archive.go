@@ -0,0 +1,73 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotDone = errors.New("only done tasks can be archived")
+
+// WithDone returns a copy of t done or open again. Done tasks remember when
+// they were completed; open ones can't stay archived.
+func (t Task) WithDone(done bool) Task {
+	t.Done = done
+	t.Completed, t.Archived = time.Time{}, time.Time{}
+	if done {
+		t.Completed = time.Now()
+	}
+	return t
+}
+
+// ArchiveTask archives the done task id at now, or unarchives it. Archiving
+// a task twice keeps when it was first archived.
+func ArchiveTask(tasks []Task, id uuid.UUID, archived bool, now time.Time) error {
+	i := indexOf(tasks, id)
+	switch {
+	case i < 0:
+		return ErrTaskNotFound
+	case !archived:
+		tasks[i].Archived = time.Time{}
+	case !tasks[i].Done:
+		return ErrNotDone
+	case tasks[i].Archived.IsZero():
+		tasks[i].Archived = now
+	}
+	return nil
+}
+
+// ArchiveDone archives at now the tasks completed before before and returns
+// the indexes of the tasks it changed.
+func ArchiveDone(tasks []Task, before, now time.Time) []int {
+	var changed []int
+	for i, t := range tasks {
+		if t.Done && t.Archived.IsZero() && !t.Completed.IsZero() && t.Completed.Before(before) {
+			tasks[i].Archived = now
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// AutoArchive archives the tasks of s completed before before as ArchiveDone
+// does, for the archive policy. The user didn't make that change, so stores
+// that can leave it out of their undo history.
+func AutoArchive(s Store, before time.Time) error {
+	op := TaskOperation{Type: "ArchiveDone", CompletedBefore: before}
+	if u, ok := s.(interface{ unrecorded(TaskOperation) error }); ok {
+		return u.unrecorded(op)
+	}
+	return Apply(s, op)
+}
+
+// backfillCompleted gives the done tasks saved before completion times were
+// kept now as their completion time, so that ArchiveDone archives them once
+// they are old enough.
+func backfillCompleted(tasks []Task, now time.Time) {
+	for i, t := range tasks {
+		if t.Done && t.Completed.IsZero() {
+			tasks[i].Completed = now
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testArchive(t *testing.T, s Store) {
+	t.Helper()
+	find := func(id uuid.UUID) Task {
+		tasks, err := s.GetAllItems()
+		if err != nil {
+			t.Fatalf("Error getting tasks: %s", err)
+		}
+		if i := indexOf(tasks, id); i >= 0 {
+			return tasks[i]
+		}
+		t.Fatalf("expected task %s kept", id)
+		return Task{}
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+
+	report, invoice := uuid.New(), uuid.New()
+	must(s.AddItem(report, "Write report", Medium))
+	must(s.AddItem(invoice, "Send invoice", Low))
+	if err := s.ArchiveTask(report, true); !errors.Is(err, ErrNotDone) {
+		t.Errorf("expected %v archiving an open task, got %v", ErrNotDone, err)
+	}
+	if err := s.ArchiveTask(uuid.New(), true); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+	}
+
+	must(s.ToggleDone(report))
+	if task := find(report); task.Completed.IsZero() {
+		t.Error("expected the completion time kept")
+	}
+	must(s.ArchiveTask(report, true))
+	task := find(report)
+	if task.Archived.IsZero() || !task.Done {
+		t.Fatalf("expected the task archived and still done, got %+v", task)
+	}
+	tasks, _ := s.GetAllItems()
+	if matched, _ := (Query{}).Run(tasks); indexOf(matched, report) >= 0 {
+		t.Error("expected archived tasks left out by default")
+	}
+	if matched, _ := (Query{Search: "report"}).Run(tasks); indexOf(matched, report) < 0 {
+		t.Error("expected archived tasks found by search")
+	}
+	must(s.ToggleDone(report))
+	if task := find(report); !task.Archived.IsZero() || !task.Completed.IsZero() {
+		t.Errorf("expected a reopened task no longer archived, got %+v", task)
+	}
+
+	must(s.ToggleDone(invoice))
+	must(s.ArchiveDone(time.Now().Add(-time.Hour)))
+	if task := find(invoice); !task.Archived.IsZero() {
+		t.Error("expected a task done just now left alone")
+	}
+	must(s.ArchiveDone(time.Now().Add(time.Second)))
+	if task := find(invoice); task.Archived.IsZero() {
+		t.Error("expected the task done before archived")
+	}
+	must(s.Undo())
+	if task := find(invoice); !task.Archived.IsZero() {
+		t.Error("expected archiving undone")
+	}
+
+	// The archive policy leaves what the user can undo and redo alone.
+	must(s.ToggleDone(report))
+	must(s.Undo())
+	must(AutoArchive(s, time.Now().Add(time.Second)))
+	if task := find(invoice); task.Archived.IsZero() {
+		t.Error("expected the policy to archive the task")
+	}
+	must(s.Redo())
+	if task := find(report); !task.Done {
+		t.Error("expected the undone change redone")
+	}
+	must(s.Undo())
+	if task := find(report); task.Done {
+		t.Error("expected the user's change undone")
+	}
+	if task := find(invoice); task.Archived.IsZero() {
+		t.Error("expected the policy's archiving not undone")
+	}
+}
+
+func TestInMemoryArchiveBackfill(t *testing.T) {
+	t.Chdir(t.TempDir())
+	id := uuid.New()
+	// A done task saved before completion times were kept.
+	file := `{"tasks": [{"ID": "` + id.String() + `", "Title": "Old report", "Priority": "Low", "Done": true}]}`
+	if err := os.WriteFile("tasks.json", []byte(file), 0o644); err != nil {
+		t.Fatalf("Error writing tasks file: %s", err)
+	}
+	s, _ := NewInMemoryStore(Config{LoadFromFile: true})
+	if err := s.ArchiveDone(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	tasks, _ := s.GetAllItems()
+	if len(tasks) != 1 || tasks[0].Completed.IsZero() || tasks[0].Archived.IsZero() {
+		t.Errorf("expected the old done task given a completion time and archived, got %+v", tasks)
+	}
+}
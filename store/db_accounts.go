@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PostgresAccounts struct {
+	Db *sql.DB
+}
+
+func NewPostgresAccounts(db *sql.DB) (*PostgresAccounts, error) {
+	a := &PostgresAccounts{Db: db}
+	if err := a.initSchema(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *PostgresAccounts) AddUser(user User) error {
+	_, err := a.Db.Exec("INSERT INTO users (name, password_hash) VALUES ($1, $2)", user.Name, user.PasswordHash)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrUserExists
+	}
+	return err
+}
+
+func (a *PostgresAccounts) GetUser(name string) (User, error) {
+	user := User{Name: name}
+	err := a.Db.QueryRow("SELECT password_hash FROM users WHERE name = $1", name).Scan(&user.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (a *PostgresAccounts) Users() ([]string, error) {
+	rows, err := a.Db.Query("SELECT name FROM users ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		users = append(users, name)
+	}
+	return users, rows.Err()
+}
+
+func (a *PostgresAccounts) AddSession(session Session) error {
+	_, err := a.Db.Exec("INSERT INTO sessions (token, username, expires) VALUES ($1, $2, $3)", session.Token, session.User, session.Expires)
+	return err
+}
+
+func (a *PostgresAccounts) GetSession(token string) (Session, error) {
+	session := Session{Token: token}
+	err := a.Db.QueryRow("SELECT username, expires FROM sessions WHERE token = $1 AND expires > $2", token, time.Now()).
+		Scan(&session.User, &session.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrSessionNotFound
+	}
+	return session, err
+}
+
+func (a *PostgresAccounts) DeleteSession(token string) error {
+	_, err := a.Db.Exec("DELETE FROM sessions WHERE token = $1", token)
+	return err
+}
+
+func (a *PostgresAccounts) initSchema() error {
+
+	query := `
+	CREATE TABLE IF NOT EXISTS users (
+		name TEXT PRIMARY KEY,
+		password_hash TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS sessions (
+		token TEXT PRIMARY KEY,
+		username TEXT NOT NULL REFERENCES users (name) ON DELETE CASCADE,
+		expires TIMESTAMPTZ NOT NULL
+	)`
+	_, err := a.Db.Exec(query)
+	return err
+}
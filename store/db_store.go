@@ -2,11 +2,15 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
@@ -18,8 +22,10 @@ const (
 
 type PostgresStore struct {
 	Db          *sql.DB
+	owner       string
 	taskChannel chan TaskOperation
 	stopChannel chan struct{}
+	events      *Broker
 }
 
 func NewPostgresStore(config Config) (*PostgresStore, error) {
@@ -41,8 +47,10 @@ func NewPostgresStore(config Config) (*PostgresStore, error) {
 
 	store := &PostgresStore{
 		Db:          db,
+		owner:       config.User,
 		taskChannel: make(chan TaskOperation),
 		stopChannel: make(chan struct{}),
+		events:      NewBroker(),
 	}
 
 	if config.LoadFromFile {
@@ -52,46 +60,164 @@ func NewPostgresStore(config Config) (*PostgresStore, error) {
 			return nil, err
 		}
 	}
+	store.start()
+	return store, nil
+}
+
+// ForUser returns a store over the same database holding only the tasks
+// owned by user.
+func (s *PostgresStore) ForUser(user string) *PostgresStore {
+	store := &PostgresStore{
+		Db:          s.Db,
+		owner:       user,
+		taskChannel: make(chan TaskOperation),
+		stopChannel: make(chan struct{}),
+		events:      NewBroker(),
+	}
+	store.start()
+	return store
+}
+
+func (s *PostgresStore) start() {
 	go func() {
-		err := store.processTasks()
+		err := s.processTasks()
 		if err != nil {
 			log.Fatal(err)
 		}
 	}()
-	return store, nil
+}
+
+func (s *PostgresStore) Events() *Broker {
+	return s.events
 }
 
 func (s *PostgresStore) GetAllItems() ([]Task, error) {
+	return s.loadTasks(s.Db, "deleted_at IS NULL ORDER BY position, id")
+}
+
+func (s *PostgresStore) GetTrash() ([]Task, error) {
+	return s.loadTasks(s.Db, "deleted_at IS NOT NULL ORDER BY deleted_at DESC, position, id")
+}
+
+// loadTasks loads the tasks matching where, with their tags and blockers.
+// The owner is $1 in where, args follow.
+func (s *PostgresStore) loadTasks(db execer, where string, args ...any) ([]Task, error) {
 	if s.Db == nil {
 		log.Println("s.db == nil")
 		return nil, fmt.Errorf("database connection is not initialized")
 	}
-	rows, err := s.Db.Query("SELECT * FROM tasks")
+	rows, err := db.Query("SELECT id, title, priority, done, description, due, position, list, parent, auto_complete, repeat, series, deleted_at, completed_at, archived_at FROM tasks WHERE owner = $1 AND "+where, append([]any{s.owner}, args...)...)
 	if err != nil {
-		log.Fatal(err)
 		return nil, err
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}(rows)
+	defer rows.Close()
 
 	var tasks []Task
 	for rows.Next() {
 		var task Task
-		if err := rows.Scan(&task.ID, &task.Title, &task.Priority, &task.Done); err != nil {
+		var due, deleted, completed, archived sql.NullTime
+		var list, parent, series uuid.NullUUID
+		if err := rows.Scan(&task.ID, &task.Title, &task.Priority, &task.Done, &task.Description, &due, &task.Position, &list, &parent, &task.AutoComplete, &task.Repeat, &series, &deleted, &completed, &archived); err != nil {
+			return nil, err
 		}
+		task.Due = due.Time
+		task.Deleted = deleted.Time
+		task.Completed = completed.Time
+		task.Archived = archived.Time
+		task.List = list.UUID
+		task.Parent = parent.UUID
+		task.Series = series.UUID
 		tasks = append(tasks, task)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	if err := s.loadTags(db, tasks); err != nil {
+		return nil, err
+	}
+	if err := s.loadBlockers(db, tasks); err != nil {
+		return nil, err
+	}
 	return tasks, err
 }
 
+func (s *PostgresStore) GetTimeEntries() ([]TimeEntry, error) {
+	return s.loadEntries(s.Db)
+}
+
+// loadEntries loads the time entries of s, most recently started first.
+func (s *PostgresStore) loadEntries(db execer) ([]TimeEntry, error) {
+	rows, err := db.Query("SELECT id, task_id, started_at, ended_at FROM time_entries WHERE owner = $1 ORDER BY started_at DESC", s.owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []TimeEntry
+	for rows.Next() {
+		var e TimeEntry
+		var end sql.NullTime
+		if err := rows.Scan(&e.ID, &e.Task, &e.Start, &end); err != nil {
+			return nil, err
+		}
+		e.End = end.Time
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *PostgresStore) GetLists() ([]List, error) {
+	return s.loadLists(s.Db, " ORDER BY lower(name)")
+}
+
+// loadLists loads the lists of s, filtered and ordered by rest. The owner is
+// $1 in rest, args follow.
+func (s *PostgresStore) loadLists(db execer, rest string, args ...any) ([]List, error) {
+	rows, err := db.Query("SELECT id, name, archived FROM lists WHERE owner = $1"+rest, append([]any{s.owner}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lists []List
+	for rows.Next() {
+		var l List
+		if err := rows.Scan(&l.ID, &l.Name, &l.Archived); err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	return lists, rows.Err()
+}
+
+// loadTags fills in the tags of tasks.
+func (s *PostgresStore) loadTags(db execer, tasks []Task) error {
+	rows, err := db.Query(`SELECT task_tags.task_id, tags.name FROM task_tags
+		JOIN tags ON tags.id = task_tags.tag_id
+		WHERE tags.owner = $1 AND task_tags.task_id = ANY($2::uuid[]) ORDER BY tags.name`, s.owner, taskIDs(tasks))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := make(map[uuid.UUID]int, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = i
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			tasks[i].Tags = append(tasks[i].Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
 func (s *PostgresStore) processTasks() error {
 	for {
 		select {
@@ -101,46 +227,33 @@ func (s *PostgresStore) processTasks() error {
 				return nil
 			}
 
+			var err error
+			var recorded bool
 			switch op.Type {
-
-			case "Add":
-				_, err := s.Db.Exec("INSERT INTO tasks (id, title, priority, done) VALUES ($1, $2, $3, $4)", op.ID, op.Title, op.Priority, false)
-				if err != nil {
-					log.Printf("Error | Failed to add empty task : [%v]\n", op.Title)
-				} else {
-					log.Printf("Added task [%s]: %v", op.ID, op.Title)
-				}
-
-				op.Result <- err
-
-			case "Delete":
-				_, err := s.Db.Exec("DELETE FROM tasks WHERE id = $1", op.ID)
-				if err != nil {
-					log.Printf("Error deleting task: %v", err)
+			case "Undo", "Redo":
+				err = s.withUndo(func(tx *sql.Tx, u *UndoStack) error {
+					var err error
+					op.Batch, err = u.step(op.Type == "Undo", s.snapshotter(tx), func(ops []TaskOperation) error { return s.applyBatch(tx, ops) })
+					return err
+				})
+			case "Batch":
+				recorded, err = s.record(op.Batch, func(tx *sql.Tx) error { return s.applyBatch(tx, op.Batch) })
+			default:
+				if op.Unrecorded {
+					err = s.locked(func(tx *sql.Tx) error { return s.apply(tx, op) })
 				} else {
-					log.Printf("Deleted task [%s]: %v", op.ID, op.Title)
+					recorded, err = s.record([]TaskOperation{op}, func(tx *sql.Tx) error { return s.apply(tx, op) })
 				}
-				op.Result <- err
-
-			case "Edit":
-				_, err := s.Db.Exec("UPDATE tasks SET title = $1 WHERE id = $2", op.Title, op.ID)
-				if err != nil {
-					log.Printf("Error editing task: %v", err)
-				} else {
-					log.Printf("Edited task [%s]: %v", op.ID, op.Title)
-				}
-				op.Result <- err
-
-			case "ToggleDone":
-				_, err := s.Db.Exec("UPDATE tasks SET done = NOT done WHERE id = $1", op.ID)
-				if err != nil {
-					log.Printf("Error toggling task done status: %v", err)
-				}
-				op.Result <- err
-
 			}
 
+			if err == nil {
+				s.events.Publish(op)
+			}
+			if op.Recorded != nil && err == nil {
+				op.Recorded <- recorded
+			}
 			if op.Result != nil {
+				op.Result <- err
 				close(op.Result)
 			}
 
@@ -155,57 +268,1291 @@ func (s *PostgresStore) processTasks() error {
 	}
 }
 
-func (s *PostgresStore) AddItem(id uuid.UUID, t string, p Priority) error {
-	result := make(chan error)
-	s.taskChannel <- TaskOperation{
-		Type:     "Add",
-		ID:       id,
-		Title:    t,
-		Priority: p,
-		Result:   result,
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (s *PostgresStore) apply(db execer, op TaskOperation) error {
+	switch op.Type {
+
+	case "Add":
+		// IDs are unique across owners, so another user's task takes the ID
+		// too.
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1)", op.ID).Scan(&exists)
+		if err == nil && exists {
+			return ErrTaskExists
+		}
+		if err == nil {
+			_, err = db.Exec(`INSERT INTO tasks (id, title, priority, done, owner, position)
+			VALUES ($1, $2, $3, $4, $5, (SELECT COALESCE(MAX(position), 0) + 1 FROM tasks WHERE owner = $5))`,
+				op.ID, op.Title, op.Priority, false, s.owner)
+		}
+		if err != nil {
+			log.Printf("Error | Failed to add empty task : [%v]\n", op.Title)
+		} else {
+			log.Printf("Added task [%s]: %v", op.ID, op.Title)
+		}
+
+		return err
+
+	case "Delete", "DeleteTree":
+		// Deleted tasks go to the trash, keeping their parent, tags and
+		// blockers for a restore. The tasks they block keep the edges too,
+		// loadBlockers skips them until then.
+		var parent uuid.NullUUID
+		err := db.QueryRow("SELECT parent FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NULL", op.ID, s.owner).Scan(&parent)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrTaskNotFound
+		}
+		if err == nil && op.Type == "Delete" {
+			_, err = db.Exec("UPDATE tasks SET parent = $1 WHERE parent = $2 AND owner = $3 AND deleted_at IS NULL", parent, op.ID, s.owner)
+		}
+		if err == nil {
+			_, err = db.Exec(`WITH RECURSIVE tree AS (
+				SELECT id FROM tasks WHERE id = $1 AND owner = $2
+				UNION SELECT tasks.id FROM tasks JOIN tree ON tasks.parent = tree.id WHERE tasks.deleted_at IS NULL
+			) UPDATE tasks SET deleted_at = $3 WHERE id IN (SELECT id FROM tree)`, op.ID, s.owner, time.Now())
+		}
+		if err == nil {
+			err = s.rollUp(db, parent.UUID)
+		}
+		if err != nil {
+			log.Printf("Error deleting task: %v", err)
+		} else {
+			log.Printf("Moved task [%s] to the trash", op.ID)
+		}
+		return err
+
+	case "Restore":
+		err := s.restore(db, op.ID)
+		if err != nil {
+			log.Printf("Error restoring task: %v", err)
+		} else {
+			log.Printf("Restored task [%s]", op.ID)
+		}
+		return err
+
+	case "Purge", "EmptyTrash":
+		var err error
+		if op.Type == "Purge" {
+			err = taskAffected(db.Exec(`WITH RECURSIVE tree AS (
+				SELECT id FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NOT NULL
+				UNION SELECT tasks.id FROM tasks JOIN tree ON tasks.parent = tree.id WHERE tasks.deleted_at IS NOT NULL
+			) DELETE FROM tasks WHERE id IN (SELECT id FROM tree)`, op.ID, s.owner))
+		} else {
+			before := sql.NullTime{Time: op.DeletedBefore, Valid: !op.DeletedBefore.IsZero()}
+			_, err = db.Exec("DELETE FROM tasks WHERE owner = $1 AND deleted_at < COALESCE($2::timestamptz, 'infinity')", s.owner, before)
+		}
+		if err == nil {
+			_, err = db.Exec(unusedTagsQuery, s.owner)
+		}
+		if err != nil {
+			log.Printf("Error purging the trash: %v", err)
+		}
+		return err
+
+	case "Archive":
+		var done bool
+		err := db.QueryRow("SELECT done FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NULL", op.ID, s.owner).Scan(&done)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			err = ErrTaskNotFound
+		case err != nil:
+		case !op.Archived:
+			_, err = db.Exec("UPDATE tasks SET archived_at = NULL WHERE id = $1 AND owner = $2", op.ID, s.owner)
+		case !done:
+			err = ErrNotDone
+		default:
+			_, err = db.Exec("UPDATE tasks SET archived_at = COALESCE(archived_at, $1) WHERE id = $2 AND owner = $3", time.Now(), op.ID, s.owner)
+		}
+		if err != nil {
+			log.Printf("Error archiving task: %v", err)
+		} else {
+			log.Printf("Set task [%s] archived: %t", op.ID, op.Archived)
+		}
+		return err
+
+	case "ArchiveDone":
+		_, err := db.Exec(`UPDATE tasks SET archived_at = $1 WHERE owner = $2 AND done AND archived_at IS NULL
+			AND completed_at < $3 AND deleted_at IS NULL`, time.Now(), s.owner, op.CompletedBefore)
+		if err != nil {
+			log.Printf("Error archiving done tasks: %v", err)
+		}
+		return err
+
+	case "StartTimer", "AddTimeEntry", "EditTimeEntry":
+		e := TimeEntry{ID: op.ID, Task: op.Task, Start: op.Start, End: op.End}
+		if op.Type == "StartTimer" {
+			e.Start, e.End = time.Now(), time.Time{}
+		}
+		end := sql.NullTime{Time: e.End, Valid: !e.End.IsZero()}
+		err := s.checkEntry(db, e, op.Type == "EditTimeEntry")
+		if err == nil && op.Type == "EditTimeEntry" {
+			err = entryAffected(db.Exec("UPDATE time_entries SET task_id = $1, started_at = $2, ended_at = $3 WHERE id = $4 AND owner = $5",
+				e.Task, e.Start, end, e.ID, s.owner))
+		} else if err == nil {
+			_, err = db.Exec("INSERT INTO time_entries (id, owner, task_id, started_at, ended_at) VALUES ($1, $2, $3, $4, $5)",
+				e.ID, s.owner, e.Task, e.Start, end)
+		}
+		if err != nil {
+			log.Printf("Error saving time entry: %v", err)
+		} else {
+			log.Printf("Saved time entry [%s] of task [%s]", e.ID, e.Task)
+		}
+		return err
+
+	case "StopTimer":
+		err := taskAffected(db.Exec("UPDATE time_entries SET ended_at = $1 WHERE owner = $2 AND ended_at IS NULL", time.Now(), s.owner))
+		if errors.Is(err, ErrTaskNotFound) {
+			err = ErrNoTimer
+		}
+		if err != nil {
+			log.Printf("Error stopping timer: %v", err)
+		}
+		return err
+
+	case "DeleteTimeEntry":
+		err := entryAffected(db.Exec("DELETE FROM time_entries WHERE id = $1 AND owner = $2", op.ID, s.owner))
+		if err != nil {
+			log.Printf("Error deleting time entry: %v", err)
+		} else {
+			log.Printf("Deleted time entry [%s]", op.ID)
+		}
+		return err
+
+	case "Edit":
+		err := taskAffected(db.Exec("UPDATE tasks SET title = $1 WHERE id = $2 AND owner = $3 AND deleted_at IS NULL", op.Title, op.ID, s.owner))
+		if err != nil {
+			log.Printf("Error editing task: %v", err)
+		} else {
+			log.Printf("Edited task [%s]: %v", op.ID, op.Title)
+		}
+		return err
+
+	case "ToggleDone":
+		tasks, err := s.loadTree(db)
+		if err == nil {
+			err = s.loadBlockers(db, tasks)
+		}
+		if err == nil {
+			err = CheckDone(tasks, op.ID)
+		}
+		if err != nil {
+			log.Printf("Error toggling task done status: %v", err)
+			return err
+		}
+		var parent uuid.NullUUID
+		// Reopened tasks are no longer archived.
+		err = db.QueryRow(`UPDATE tasks SET done = NOT done, completed_at = CASE WHEN done THEN NULL ELSE $3::timestamptz END, archived_at = NULL
+			WHERE id = $1 AND owner = $2 AND deleted_at IS NULL RETURNING parent`, op.ID, s.owner, time.Now()).Scan(&parent)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrTaskNotFound
+		}
+		if err == nil {
+			err = s.recur(db, op.ID)
+		}
+		if err == nil {
+			err = s.rollUp(db, parent.UUID)
+		}
+		if err != nil {
+			log.Printf("Error toggling task done status: %v", err)
+		}
+		return err
+
+	case "SetPriority":
+		err := taskAffected(db.Exec("UPDATE tasks SET priority = $1 WHERE id = $2 AND owner = $3 AND deleted_at IS NULL", op.Priority, op.ID, s.owner))
+		if err != nil {
+			log.Printf("Error setting task priority: %v", err)
+		} else {
+			log.Printf("Set priority of task [%s]: %v", op.ID, op.Priority)
+		}
+		return err
+
+	case "Move":
+		err := s.move(db, op.ID, op.Before)
+		if err != nil {
+			log.Printf("Error moving task: %v", err)
+		} else {
+			log.Printf("Moved task [%s] before [%s]", op.ID, op.Before)
+		}
+		return err
+
+	case "AddTag", "RemoveTag":
+		err := s.tag(db, op)
+		if err != nil {
+			log.Printf("Error tagging task: %v", err)
+		} else {
+			log.Printf("Tagged task [%s]: %s %s", op.ID, op.Type, op.Tag)
+		}
+		return err
+
+	case "CreateList", "RenameList":
+		name, ok := NormalizeListName(op.Title)
+		if !ok {
+			return ErrInvalidList
+		}
+		var taken bool
+		err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM lists WHERE owner = $1
+			AND (lower(name) = lower($2) AND id <> $3 OR id = $3 AND $4))`,
+			s.owner, name, op.ID, op.Type == "CreateList").Scan(&taken)
+		switch {
+		case err != nil:
+		case taken:
+			err = ErrListExists
+		case op.Type == "CreateList":
+			_, err = db.Exec("INSERT INTO lists (id, owner, name) VALUES ($1, $2, $3)", op.ID, s.owner, name)
+		default:
+			err = listAffected(db.Exec("UPDATE lists SET name = $1 WHERE id = $2 AND owner = $3", name, op.ID, s.owner))
+		}
+		if err != nil {
+			log.Printf("Error naming list: %v", err)
+		} else {
+			log.Printf("Named list [%s]: %v", op.ID, name)
+		}
+		return err
+
+	case "ArchiveList":
+		err := listAffected(db.Exec("UPDATE lists SET archived = $1 WHERE id = $2 AND owner = $3", op.Archived, op.ID, s.owner))
+		if err != nil {
+			log.Printf("Error archiving list: %v", err)
+		} else {
+			log.Printf("Archived list [%s]: %t", op.ID, op.Archived)
+		}
+		return err
+
+	case "DeleteList":
+		// The tasks of the list go back to the inbox, see the foreign key.
+		err := listAffected(db.Exec("DELETE FROM lists WHERE id = $1 AND owner = $2", op.ID, s.owner))
+		if err != nil {
+			log.Printf("Error deleting list: %v", err)
+		} else {
+			log.Printf("Deleted list [%s]", op.ID)
+		}
+		return err
+
+	case "SetList":
+		list := uuid.NullUUID{UUID: op.List, Valid: op.List != uuid.Nil}
+		var exists bool
+		err := db.QueryRow("SELECT NOT $1 OR EXISTS (SELECT 1 FROM lists WHERE id = $2 AND owner = $3)", list.Valid, op.List, s.owner).Scan(&exists)
+		switch {
+		case err != nil:
+		case !exists:
+			err = ErrListNotFound
+		default:
+			err = taskAffected(db.Exec("UPDATE tasks SET list = $1 WHERE id = $2 AND owner = $3 AND deleted_at IS NULL", list, op.ID, s.owner))
+		}
+		if err != nil {
+			log.Printf("Error moving task to list: %v", err)
+		} else {
+			log.Printf("Moved task [%s] to list [%s]", op.ID, op.List)
+		}
+		return err
+
+	case "SetParent":
+		tasks, err := s.loadTree(db)
+		if err == nil {
+			err = CheckParent(tasks, op.ID, op.Parent)
+		}
+		if err == nil {
+			previous := tasks[indexOf(tasks, op.ID)].Parent
+			parent := uuid.NullUUID{UUID: op.Parent, Valid: op.Parent != uuid.Nil}
+			if _, err = db.Exec("UPDATE tasks SET parent = $1 WHERE id = $2 AND owner = $3", parent, op.ID, s.owner); err == nil {
+				err = s.rollUp(db, previous, op.Parent)
+			}
+		}
+		if err != nil {
+			log.Printf("Error nesting task: %v", err)
+		} else {
+			log.Printf("Nested task [%s] under [%s]", op.ID, op.Parent)
+		}
+		return err
+
+	case "SetAutoComplete":
+		err := taskAffected(db.Exec("UPDATE tasks SET auto_complete = $1 WHERE id = $2 AND owner = $3 AND deleted_at IS NULL", op.AutoComplete, op.ID, s.owner))
+		if err == nil {
+			err = s.rollUp(db, op.ID)
+		}
+		if err != nil {
+			log.Printf("Error setting auto-completion: %v", err)
+		}
+		return err
+
+	case "AddBlocker":
+		tasks, err := s.loadTree(db)
+		if err == nil {
+			err = s.loadBlockers(db, tasks)
+		}
+		if err == nil {
+			err = CheckBlocker(tasks, op.ID, op.Blocker)
+		}
+		if err == nil {
+			_, err = db.Exec("INSERT INTO task_blockers (task_id, blocker_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", op.ID, op.Blocker)
+		}
+		if err != nil {
+			log.Printf("Error adding blocker: %v", err)
+		} else {
+			log.Printf("Task [%s] blocked by [%s]", op.ID, op.Blocker)
+		}
+		return err
+
+	case "RemoveBlocker":
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NULL)", op.ID, s.owner).Scan(&exists)
+		switch {
+		case err != nil:
+		case !exists:
+			err = ErrTaskNotFound
+		default:
+			_, err = db.Exec("DELETE FROM task_blockers WHERE task_id = $1 AND blocker_id = $2", op.ID, op.Blocker)
+		}
+		if err != nil {
+			log.Printf("Error removing blocker: %v", err)
+		} else {
+			log.Printf("Task [%s] no longer blocked by [%s]", op.ID, op.Blocker)
+		}
+		return err
+
+	case "SetRepeat":
+		rule, ok := NormalizeRecurrence(op.Repeat)
+		if !ok {
+			return ErrInvalidRecurrence
+		}
+		err := taskAffected(db.Exec("UPDATE tasks SET repeat = $1 WHERE id = $2 AND owner = $3 AND deleted_at IS NULL", rule, op.ID, s.owner))
+		if err != nil {
+			log.Printf("Error setting recurrence: %v", err)
+		} else {
+			log.Printf("Task [%s] repeats %q", op.ID, rule)
+		}
+		return err
+
+	case "Update":
+		due := sql.NullTime{Time: op.Due, Valid: !op.Due.IsZero()}
+		err := taskAffected(db.Exec("UPDATE tasks SET title = $1, priority = $2, description = $3, due = $4 WHERE id = $5 AND owner = $6 AND deleted_at IS NULL",
+			op.Title, op.Priority, op.Description, due, op.ID, s.owner))
+		if err != nil {
+			log.Printf("Error updating task: %v", err)
+		} else {
+			log.Printf("Updated task [%s]: %v", op.ID, op.Title)
+		}
+		return err
+
+	default:
+		return fmt.Errorf("unknown operation %q", op.Type)
 	}
-	return <-result
 }
 
-func (s *PostgresStore) DeleteItem(id uuid.UUID) error {
-	result := make(chan error)
-	s.taskChannel <- TaskOperation{
-		Type:   "Delete",
-		ID:     id,
-		Result: result,
+// tag adds op.Tag to the task op.ID or removes it, deleting tags no task
+// uses anymore.
+func (s *PostgresStore) tag(db execer, op TaskOperation) error {
+	tag, ok := NormalizeTag(op.Tag)
+	if !ok {
+		return ErrInvalidTag
 	}
-	return <-result
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NULL)", op.ID, s.owner).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrTaskNotFound
+	}
+
+	if op.Type == "AddTag" {
+		if _, err := db.Exec("INSERT INTO tags (owner, name) VALUES ($1, $2) ON CONFLICT DO NOTHING", s.owner, tag); err != nil {
+			return err
+		}
+		_, err := db.Exec(`INSERT INTO task_tags (task_id, tag_id)
+			SELECT $1, id FROM tags WHERE owner = $2 AND name = $3 ON CONFLICT DO NOTHING`, op.ID, s.owner, tag)
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM task_tags WHERE task_id = $1
+		AND tag_id IN (SELECT id FROM tags WHERE owner = $2 AND name = $3)`, op.ID, s.owner, tag)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(unusedTagsQuery, s.owner)
+	return err
 }
 
-func (s *PostgresStore) EditTask(id uuid.UUID, t string) error {
-	result := make(chan error)
-	s.taskChannel <- TaskOperation{
-		Type:   "Edit",
-		ID:     id,
-		Title:  t,
-		Result: result,
+// unusedTagsQuery deletes the tags of an owner that no task has anymore.
+const unusedTagsQuery = `DELETE FROM tags WHERE owner = $1
+	AND NOT EXISTS (SELECT 1 FROM task_tags WHERE tag_id = tags.id)`
+
+// move gives the task id a position between the task before and the one
+// preceding it, renumbering the user's tasks when there's no room left.
+func (s *PostgresStore) move(db execer, id, before uuid.UUID) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NULL)", id, s.owner).Scan(&exists); err != nil {
+		return err
 	}
-	return <-result
+	if !exists {
+		return ErrTaskNotFound
+	}
+	if before == id {
+		return nil
+	}
+
+	var position float64
+	if before == uuid.Nil {
+		err := db.QueryRow("SELECT COALESCE(MAX(position), 0) + 1 FROM tasks WHERE owner = $1 AND id <> $2", s.owner, id).Scan(&position)
+		if err != nil {
+			return err
+		}
+	} else {
+		var next float64
+		err := db.QueryRow("SELECT position FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NULL", before, s.owner).Scan(&next)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTaskNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var prev sql.NullFloat64
+		err = db.QueryRow("SELECT MAX(position) FROM tasks WHERE owner = $1 AND position < $2 AND id <> $3", s.owner, next, id).Scan(&prev)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !prev.Valid:
+			position = next - 1
+		case next-prev.Float64 < minPositionGap:
+			if _, err := db.Exec(`UPDATE tasks SET position = r.n FROM
+				(SELECT id, row_number() OVER (ORDER BY position, id) AS n FROM tasks WHERE owner = $1) r
+				WHERE tasks.id = r.id`, s.owner); err != nil {
+				return err
+			}
+			return s.move(db, id, before)
+		default:
+			position = prev.Float64 + (next-prev.Float64)/2
+		}
+	}
+	_, err := db.Exec("UPDATE tasks SET position = $1 WHERE id = $2 AND owner = $3", position, id, s.owner)
+	return err
 }
 
-func (s *PostgresStore) ToggleDone(id uuid.UUID) error {
-	result := make(chan error)
-	s.taskChannel <- TaskOperation{
-		Type:   "ToggleDone",
-		ID:     id,
-		Result: result,
+// applyBatch performs ops in tx, all of them or none.
+func (s *PostgresStore) applyBatch(tx *sql.Tx, ops []TaskOperation) error {
+	if _, err := tx.Exec("SAVEPOINT batch"); err != nil {
+		return err
 	}
-	return <-result
+	for i, op := range ops {
+		if err := s.apply(tx, op); err != nil {
+			// Undoing a change that no longer applies drops it, so tx
+			// goes on to save the undo stack.
+			if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT batch"); rbErr != nil {
+				log.Printf("Error rolling back batch: %v", rbErr)
+			}
+			return fmt.Errorf("operation %d (%s): %w", i+1, op.Type, err)
+		}
+	}
+	_, err := tx.Exec("RELEASE SAVEPOINT batch")
+	return err
 }
 
-func (s *PostgresStore) initSchema() error {
+// record performs ops through apply and adds them to the changes that can be
+// undone, working out how from the state apply starts from in the same
+// transaction. It reports whether they were recorded.
+func (s *PostgresStore) record(ops []TaskOperation, apply func(tx *sql.Tx) error) (bool, error) {
+	var recorded bool
+	err := s.withUndo(func(tx *sql.Tx, u *UndoStack) error {
+		before, err := s.touched(tx, ops)
+		if err != nil {
+			return err
+		}
+		if err := apply(tx); err != nil {
+			return err
+		}
+		recorded, err = u.record(before, ops, s.snapshotter(tx))
+		return err
+	})
+	return recorded, err
+}
 
-	query := `
-	CREATE TABLE IF NOT EXISTS tasks (
-		id UUID PRIMARY KEY,
-		title TEXT NOT NULL,
-		priority TEXT NOT NULL CHECK (priority IN ('Low', 'Medium', 'High')),
-		done BOOLEAN NOT NULL
+// withUndo runs f in a transaction with the undo stack of the owner, kept
+// in the database so that every store of the owner shares it. The stack is
+// saved unless f fails, changes dropped with ErrUndoConflict aside.
+func (s *PostgresStore) withUndo(f func(tx *sql.Tx, u *UndoStack) error) error {
+	var conflict error
+	err := s.locked(func(tx *sql.Tx) error {
+		var u UndoStack
+		var b []byte
+		err := tx.QueryRow("SELECT changes FROM undo_stacks WHERE owner = $1", s.owner).Scan(&b)
+		if err == nil {
+			err = json.Unmarshal(b, &u)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := f(tx, &u); errors.Is(err, ErrUndoConflict) {
+			conflict = err
+		} else if err != nil {
+			return err
+		}
+		if b, err = json.Marshal(u); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO undo_stacks (owner, changes) VALUES ($1, $2)
+			ON CONFLICT (owner) DO UPDATE SET changes = excluded.changes`, s.owner, string(b))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return conflict
+}
+
+// locked runs f in a transaction holding the lock of the owner. Stores of
+// the owner, in this process or another, take turns so that changes are
+// recorded against the state they started from.
+func (s *PostgresStore) locked(f func(tx *sql.Tx) error) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", s.owner); err != nil {
+			return err
+		}
+		return f(tx)
+	})
+}
+
+// unrecorded performs op, leaving it out of the undo history.
+func (s *PostgresStore) unrecorded(op TaskOperation) error {
+	result := make(chan error)
+	op.Unrecorded, op.Result = true, result
+	s.taskChannel <- op
+	return <-result
+}
+
+// touched loads what ops work on into an InMemoryStore, where the changes
+// to undo can be worked out: the tasks and lists they name, with the
+// subtasks, parents and siblings of those tasks, the task after each one
+// moved, the tasks of the lists and those due for archiving.
+func (s *PostgresStore) touched(db execer, ops []TaskOperation) (*InMemoryStore, error) {
+	var ids, lists, moved []uuid.UUID
+	var archiving sql.NullTime
+	for _, op := range ops {
+		for _, id := range []uuid.UUID{op.ID, op.Before, op.Parent, op.Blocker} {
+			if id != uuid.Nil {
+				ids = append(ids, id)
+			}
+		}
+		if op.List != uuid.Nil {
+			lists = append(lists, op.List)
+		}
+		switch op.Type {
+		case "ToggleDone":
+			ids = append(ids, NextOccurrenceID(op.ID))
+		case "Move":
+			moved = append(moved, op.ID)
+		case "ArchiveDone":
+			if !archiving.Valid || op.CompletedBefore.After(archiving.Time) {
+				archiving = sql.NullTime{Time: op.CompletedBefore, Valid: true}
+			}
+		case "CreateList", "RenameList", "ArchiveList", "DeleteList":
+			lists = append(lists, op.ID)
+		}
+	}
+	tasks, err := s.loadTasks(db, `id IN (WITH RECURSIVE
+		down AS (SELECT id FROM tasks WHERE id = ANY($2::uuid[])
+			UNION SELECT tasks.id FROM tasks JOIN down ON tasks.parent = down.id),
+		up AS (SELECT parent AS id FROM tasks WHERE id = ANY($2::uuid[]) AND parent IS NOT NULL
+			UNION SELECT tasks.parent FROM tasks JOIN up ON tasks.id = up.id WHERE tasks.parent IS NOT NULL)
+		SELECT id FROM down
+		UNION SELECT id FROM up
+		UNION SELECT id FROM tasks WHERE parent IN (SELECT id FROM up)
+		UNION SELECT id FROM tasks WHERE list = ANY($3::uuid[])
+		UNION SELECT id FROM tasks WHERE done AND archived_at IS NULL AND completed_at < $4
+		UNION SELECT (SELECT following.id FROM tasks AS following
+			WHERE following.owner = moved.owner AND following.deleted_at IS NULL AND (following.position, following.id) > (moved.position, moved.id)
+			ORDER BY following.position, following.id LIMIT 1)
+			FROM tasks AS moved WHERE moved.id = ANY($5::uuid[])
+	) ORDER BY position, id`, uuidArray(ids), uuidArray(lists), archiving, uuidArray(moved))
+	if err != nil {
+		return nil, err
+	}
+	state := &InMemoryStore{}
+	for _, t := range tasks {
+		if t.Deleted.IsZero() {
+			state.tasks = append(state.tasks, t)
+		} else {
+			state.trash = append(state.trash, t)
+		}
+	}
+	if state.lists, err = s.loadLists(db, " AND id = ANY($2::uuid[])", uuidArray(lists)); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// snapshotter returns the snapshot function of the undo stack, loading only
+// the tasks and lists asked for from db.
+func (s *PostgresStore) snapshotter(db execer) func(tasks, lists []uuid.UUID) (Snapshot, error) {
+	return func(tasks, lists []uuid.UUID) (Snapshot, error) {
+		snap := Snapshot{Tasks: map[uuid.UUID]*Task{}, Lists: map[uuid.UUID]*List{}}
+		for _, id := range tasks {
+			snap.Tasks[id] = nil
+		}
+		loaded, err := s.loadTasks(db, "id = ANY($2::uuid[])", uuidArray(tasks))
+		if err != nil {
+			return Snapshot{}, err
+		}
+		for _, t := range loaded {
+			snap.Tasks[t.ID] = &t
+		}
+		for _, id := range lists {
+			snap.Lists[id] = nil
+		}
+		found, err := s.loadLists(db, " AND id = ANY($2::uuid[])", uuidArray(lists))
+		if err != nil {
+			return Snapshot{}, err
+		}
+		for _, l := range found {
+			snap.Lists[l.ID] = &l
+		}
+		return snap, nil
+	}
+}
+
+// uuidArray passes ids to a query as a uuid[] parameter.
+func uuidArray(ids []uuid.UUID) any {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return pq.Array(strs)
+}
+
+// taskIDs passes the IDs of tasks to a query as a uuid[] parameter.
+func taskIDs(tasks []Task) any {
+	ids := make([]uuid.UUID, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	return uuidArray(ids)
+}
+
+// transaction runs f in a transaction, committed if f succeeds.
+func (s *PostgresStore) transaction(f func(tx *sql.Tx) error) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadTree loads what the subtask operations work on: the parent, state
+// and auto-completion of every task.
+func (s *PostgresStore) loadTree(db execer) ([]Task, error) {
+	rows, err := db.Query("SELECT id, parent, done, auto_complete FROM tasks WHERE owner = $1 AND deleted_at IS NULL", s.owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		var t Task
+		var parent uuid.NullUUID
+		if err := rows.Scan(&t.ID, &parent, &t.Done, &t.AutoComplete); err != nil {
+			return nil, err
+		}
+		t.Parent = parent.UUID
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// recur adds the next occurrence of the task id once it is done, with its
+// tags, as Recur does.
+func (s *PostgresStore) recur(db execer, id uuid.UUID) error {
+	var t Task
+	var due sql.NullTime
+	var list, parent, series uuid.NullUUID
+	err := db.QueryRow(`SELECT id, title, priority, done, description, due, position, list, parent, repeat, series
+		FROM tasks WHERE id = $1 AND owner = $2`, id, s.owner).
+		Scan(&t.ID, &t.Title, &t.Priority, &t.Done, &t.Description, &due, &t.Position, &list, &parent, &t.Repeat, &series)
+	if err != nil {
+		return err
+	}
+	t.Due, t.List, t.Parent, t.Series = due.Time, list.UUID, parent.UUID, series.UUID
+	next, ok := NextOccurrence(t)
+	if !t.Done || !ok {
+		return nil
+	}
+
+	var following sql.NullFloat64
+	if err := db.QueryRow("SELECT MIN(position) FROM tasks WHERE owner = $1 AND position > $2", s.owner, t.Position).Scan(&following); err != nil {
+		return err
+	}
+	position := t.Position + 1
+	switch {
+	case !following.Valid:
+	case following.Float64-t.Position < 2*minPositionGap:
+		if err := db.QueryRow("SELECT MAX(position) + 1 FROM tasks WHERE owner = $1", s.owner).Scan(&position); err != nil {
+			return err
+		}
+	default:
+		position = t.Position + (following.Float64-t.Position)/2
+	}
+
+	res, err := db.Exec(`INSERT INTO tasks (id, title, priority, done, owner, description, due, position, list, parent, repeat, series)
+		VALUES ($1, $2, $3, false, $4, $5, $6, $7, $8, $9, $10, $11) ON CONFLICT (id) DO NOTHING`,
+		next.ID, next.Title, next.Priority, s.owner, next.Description, sql.NullTime{Time: next.Due, Valid: true}, position,
+		list, parent, next.Repeat, next.Series)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// The next occurrence was added when the task was first completed.
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO task_tags (task_id, tag_id) SELECT $1, tag_id FROM task_tags WHERE task_id = $2`, next.ID, t.ID)
+	return err
+}
+
+// loadBlockers fills in the blockers of tasks that aren't in the trash.
+// Purging a task deletes its edges, see the foreign keys.
+func (s *PostgresStore) loadBlockers(db execer, tasks []Task) error {
+	rows, err := db.Query(`SELECT task_blockers.task_id, task_blockers.blocker_id FROM task_blockers
+		JOIN tasks ON tasks.id = task_blockers.task_id
+		JOIN tasks AS blockers ON blockers.id = task_blockers.blocker_id
+		WHERE tasks.owner = $1 AND task_blockers.task_id = ANY($2::uuid[]) AND blockers.deleted_at IS NULL
+		ORDER BY task_blockers.blocker_id`, s.owner, taskIDs(tasks))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := make(map[uuid.UUID]int, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = i
+	}
+	for rows.Next() {
+		var id, blocker uuid.UUID
+		if err := rows.Scan(&id, &blocker); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			tasks[i].BlockedBy = append(tasks[i].BlockedBy, blocker)
+		}
+	}
+	return rows.Err()
+}
+
+// restore brings the task id back from the trash with the subtasks trashed
+// under it, as RestoreTask does.
+func (s *PostgresStore) restore(db execer, id uuid.UUID) error {
+	err := taskAffected(db.Exec(`WITH RECURSIVE tree AS (
+		SELECT id FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NOT NULL
+		UNION SELECT tasks.id FROM tasks JOIN tree ON tasks.parent = tree.id WHERE tasks.deleted_at IS NOT NULL
+	) UPDATE tasks SET deleted_at = NULL WHERE id IN (SELECT id FROM tree)`, id, s.owner))
+	if err != nil {
+		return err
+	}
+	var parent uuid.NullUUID
+	err = db.QueryRow(`UPDATE tasks SET parent = NULL WHERE id = $1
+		AND parent IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL) RETURNING parent`, id).Scan(&parent)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = db.QueryRow("SELECT parent FROM tasks WHERE id = $1", id).Scan(&parent)
+	}
+	if err != nil {
+		return err
+	}
+	return s.rollUp(db, parent.UUID)
+}
+
+// rollUp applies RollUp from each of ids to the database.
+func (s *PostgresStore) rollUp(db execer, ids ...uuid.UUID) error {
+	tasks, err := s.loadTree(db)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		for _, i := range RollUp(tasks, id) {
+			completed := sql.NullTime{Time: tasks[i].Completed, Valid: tasks[i].Done}
+			if _, err := db.Exec("UPDATE tasks SET done = $1, completed_at = $2, archived_at = NULL WHERE id = $3 AND owner = $4", tasks[i].Done, completed, tasks[i].ID, s.owner); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func taskAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+func listAffected(res sql.Result, err error) error {
+	if err := taskAffected(res, err); errors.Is(err, ErrTaskNotFound) {
+		return ErrListNotFound
+	} else {
+		return err
+	}
+}
+
+func entryAffected(res sql.Result, err error) error {
+	if err := taskAffected(res, err); errors.Is(err, ErrTaskNotFound) {
+		return ErrEntryNotFound
+	} else {
+		return err
+	}
+}
+
+// checkEntry reports whether e can be saved, as an edit of an existing entry
+// if edit is set.
+func (s *PostgresStore) checkEntry(db execer, e TimeEntry, edit bool) error {
+	entries, err := s.loadEntries(db)
+	if err != nil {
+		return err
+	}
+	if edit && !slices.ContainsFunc(entries, func(other TimeEntry) bool { return other.ID == e.ID }) {
+		return ErrEntryNotFound
+	}
+	var exists bool
+	if !edit {
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM time_entries WHERE id = $1)", e.ID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrEntryExists
+		}
+	}
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND owner = $2 AND deleted_at IS NULL)", e.Task, s.owner).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrTaskNotFound
+	}
+	return CheckEntry(entries, e)
+}
+
+func (s *PostgresStore) AddItem(id uuid.UUID, t string, p Priority) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:     "Add",
+		ID:       id,
+		Title:    t,
+		Priority: p,
+		Result:   result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) DeleteItem(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Delete",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) EditTask(id uuid.UUID, t string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Edit",
+		ID:     id,
+		Title:  t,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) ToggleDone(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "ToggleDone",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+// Record performs ops as Batch does, a single one on its own, and reports
+// whether they were recorded for Undo.
+func (s *PostgresStore) Record(ops ...TaskOperation) (bool, error) {
+	op := TaskOperation{Type: "Batch", Batch: ops}
+	if len(ops) == 1 {
+		op = ops[0]
+	}
+	result, recorded := make(chan error), make(chan bool, 1)
+	op.Result, op.Recorded = result, recorded
+	s.taskChannel <- op
+	if err := <-result; err != nil {
+		return false, err
+	}
+	return <-recorded, nil
+}
+
+func (s *PostgresStore) Batch(ops []TaskOperation) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Batch",
+		Batch:  ops,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) SetPriority(id uuid.UUID, p Priority) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:     "SetPriority",
+		ID:       id,
+		Priority: p,
+		Result:   result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) MoveTask(id, before uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Move",
+		ID:     id,
+		Before: before,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) AddTag(id uuid.UUID, tag string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "AddTag",
+		ID:     id,
+		Tag:    tag,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) RemoveTag(id uuid.UUID, tag string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "RemoveTag",
+		ID:     id,
+		Tag:    tag,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) CreateList(id uuid.UUID, name string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "CreateList",
+		ID:     id,
+		Title:  name,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) RenameList(id uuid.UUID, name string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "RenameList",
+		ID:     id,
+		Title:  name,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) ArchiveList(id uuid.UUID, archived bool) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:     "ArchiveList",
+		ID:       id,
+		Archived: archived,
+		Result:   result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) DeleteList(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "DeleteList",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) SetList(taskID, listID uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "SetList",
+		ID:     taskID,
+		List:   listID,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) SetParent(id, parent uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "SetParent",
+		ID:     id,
+		Parent: parent,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) SetAutoComplete(id uuid.UUID, auto bool) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:         "SetAutoComplete",
+		ID:           id,
+		AutoComplete: auto,
+		Result:       result,
+	}
+	return <-result
+}
+
+// DeleteTree deletes a task together with its subtasks, where DeleteItem
+// moves them up to its parent.
+func (s *PostgresStore) DeleteTree(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "DeleteTree",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) AddBlocker(id, blocker uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:    "AddBlocker",
+		ID:      id,
+		Blocker: blocker,
+		Result:  result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) RemoveBlocker(id, blocker uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:    "RemoveBlocker",
+		ID:      id,
+		Blocker: blocker,
+		Result:  result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) SetRepeat(id uuid.UUID, rule string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "SetRepeat",
+		ID:     id,
+		Repeat: rule,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) RestoreTask(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Restore",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) PurgeTask(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Purge",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) EmptyTrash(before time.Time) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:          "EmptyTrash",
+		DeletedBefore: before,
+		Result:        result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) ArchiveTask(id uuid.UUID, archived bool) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:     "Archive",
+		ID:       id,
+		Archived: archived,
+		Result:   result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) ArchiveDone(before time.Time) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:            "ArchiveDone",
+		CompletedBefore: before,
+		Result:          result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) StartTimer(id, task uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "StartTimer",
+		ID:     id,
+		Task:   task,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) StopTimer() error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{Type: "StopTimer", Result: result}
+	return <-result
+}
+
+func (s *PostgresStore) AddTimeEntry(e TimeEntry) error {
+	result := make(chan error)
+	op := EntryOperation("AddTimeEntry", e)
+	op.Result = result
+	s.taskChannel <- op
+	return <-result
+}
+
+func (s *PostgresStore) EditTimeEntry(e TimeEntry) error {
+	result := make(chan error)
+	op := EntryOperation("EditTimeEntry", e)
+	op.Result = result
+	s.taskChannel <- op
+	return <-result
+}
+
+func (s *PostgresStore) DeleteTimeEntry(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "DeleteTimeEntry",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *PostgresStore) Undo() error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{Type: "Undo", Result: result}
+	return <-result
+}
+
+func (s *PostgresStore) Redo() error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{Type: "Redo", Result: result}
+	return <-result
+}
+
+func (s *PostgresStore) UpdateTask(t Task) error {
+	result := make(chan error)
+	op := UpdateOperation(t)
+	op.Result = result
+	s.taskChannel <- op
+	return <-result
+}
+
+func (s *PostgresStore) initSchema() error {
+
+	query := `
+	CREATE TABLE IF NOT EXISTS tasks (
+		id UUID PRIMARY KEY,
+		title TEXT NOT NULL,
+		priority TEXT NOT NULL CHECK (priority IN ('Low', 'Medium', 'High')),
+		done BOOLEAN NOT NULL,
+		owner TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		due DATE,
+		position DOUBLE PRECISION NOT NULL DEFAULT 0
+	);
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due DATE;
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS position DOUBLE PRECISION NOT NULL DEFAULT 0;
+	UPDATE tasks SET position = r.n FROM
+		(SELECT id, row_number() OVER (PARTITION BY owner ORDER BY id) AS n FROM tasks) r
+		WHERE tasks.id = r.id AND NOT EXISTS (SELECT 1 FROM tasks WHERE position <> 0);
+	CREATE TABLE IF NOT EXISTS lists (
+		id UUID PRIMARY KEY,
+		owner TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		archived BOOLEAN NOT NULL DEFAULT false
+	);
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS list UUID REFERENCES lists (id) ON DELETE SET NULL;
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent UUID REFERENCES tasks (id) ON DELETE SET NULL;
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS auto_complete BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS repeat TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS series UUID;
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS completed_at TIMESTAMPTZ;
+	UPDATE tasks SET completed_at = now() WHERE done AND completed_at IS NULL;
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+	CREATE TABLE IF NOT EXISTS tags (
+		id SERIAL PRIMARY KEY,
+		owner TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		UNIQUE (owner, name)
+	);
+	CREATE TABLE IF NOT EXISTS task_tags (
+		task_id UUID NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+		PRIMARY KEY (task_id, tag_id)
+	);
+	CREATE TABLE IF NOT EXISTS task_blockers (
+		task_id UUID NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+		blocker_id UUID NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+		PRIMARY KEY (task_id, blocker_id)
+	);
+	CREATE TABLE IF NOT EXISTS time_entries (
+		id UUID PRIMARY KEY,
+		owner TEXT NOT NULL DEFAULT '',
+		task_id UUID NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS time_entries_running ON time_entries (owner) WHERE ended_at IS NULL;
+	CREATE TABLE IF NOT EXISTS undo_stacks (
+		owner TEXT PRIMARY KEY,
+		changes JSONB NOT NULL
 	)`
 	_, err := s.Db.Exec(query)
 	return err
@@ -1,10 +1,13 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"slices"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestPostgresStore(t *testing.T) {
@@ -39,6 +42,9 @@ func TestPostgresStore(t *testing.T) {
 		if tasks[0].Priority != taskPriority {
 			t.Errorf("expected task priority '%s', got '%s'", taskPriority, tasks[0].Priority)
 		}
+		if err := store.AddItem(taskID, "Other Task", Low); !errors.Is(err, ErrTaskExists) {
+			t.Errorf("expected %v adding a task twice, got %v", ErrTaskExists, err)
+		}
 
 	})
 
@@ -111,10 +117,189 @@ func TestPostgresStore(t *testing.T) {
 		}
 
 	})
+	t.Run("set priority", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+
+		taskID := uuid.New()
+		err := store.AddItem(taskID, "Test Task", Low)
+		if err != nil {
+			t.Errorf("Error adding task: %s", err)
+		}
+
+		if err := store.SetPriority(taskID, High); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		tasks, _ := store.GetAllItems()
+		if len(tasks) != 1 {
+			t.Errorf("expected 1 task, got %d", len(tasks))
+		}
+		if tasks[0].Priority != High {
+			t.Errorf("expected task priority '%s', got '%s'", High, tasks[0].Priority)
+		}
+
+		if err := store.SetPriority(uuid.New(), High); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+	})
+	t.Run("update task", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+
+		taskID := uuid.New()
+		err := store.AddItem(taskID, "Test Task", Low)
+		if err != nil {
+			t.Errorf("Error adding task: %s", err)
+		}
+
+		due := time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC)
+		update := Task{ID: taskID, Title: "Updated Task", Priority: High, Description: "Details", Due: due}
+		if err := store.UpdateTask(update); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		tasks, _ := store.GetAllItems()
+		if len(tasks) != 1 {
+			t.Fatalf("expected 1 task, got %d", len(tasks))
+		}
+		got := tasks[0]
+		if got.Title != update.Title || got.Priority != High || got.Description != "Details" || !got.Due.Equal(due) {
+			t.Errorf("expected %+v, got %+v", update, got)
+		}
+
+		if err := store.UpdateTask(Task{ID: uuid.New(), Title: "Missing", Priority: Low}); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+	})
+	t.Run("move task", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+
+		first, second, third := uuid.New(), uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{first, second, third} {
+			if err := store.AddItem(id, "Test Task", Low); err != nil {
+				t.Fatalf("Error adding task: %s", err)
+			}
+		}
+		order := func() []uuid.UUID {
+			tasks, _ := store.GetAllItems()
+			var ids []uuid.UUID
+			for _, task := range tasks {
+				ids = append(ids, task.ID)
+			}
+			return ids
+		}
+
+		moves := []struct {
+			id, before uuid.UUID
+			want       []uuid.UUID
+		}{
+			{third, first, []uuid.UUID{third, first, second}},
+			{third, uuid.Nil, []uuid.UUID{first, second, third}},
+			{first, third, []uuid.UUID{second, first, third}},
+			{second, second, []uuid.UUID{second, first, third}},
+		}
+		for _, m := range moves {
+			if err := store.MoveTask(m.id, m.before); err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if got := order(); !slices.Equal(got, m.want) {
+				t.Errorf("after moving %s before %s: expected %v, got %v", m.id, m.before, m.want, got)
+			}
+		}
+
+		if err := store.MoveTask(uuid.New(), first); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+		if err := store.MoveTask(first, uuid.New()); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+	})
+	t.Run("tags", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testTags(t, store)
+	})
+	t.Run("lists", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testLists(t, store)
+	})
+	t.Run("subtasks", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testSubtasks(t, store)
+	})
+	t.Run("dependencies", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testDependencies(t, store)
+	})
+	t.Run("recurrence", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testRecurrence(t, store)
+	})
+	t.Run("trash", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testTrash(t, store)
+	})
+	t.Run("archive", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testArchive(t, store)
+	})
+	t.Run("timer", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testTimer(t, store)
+	})
+	t.Run("undo", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+		testUndo(t, store)
+	})
+	t.Run("batch", func(t *testing.T) {
+		store, _ := NewPostgresStore(c)
+		defer clearDB(store)
+
+		keptID, addedID := uuid.New(), uuid.New()
+		err := store.AddItem(keptID, "Test Task", Low)
+		if err != nil {
+			t.Errorf("Error adding task: %s", err)
+		}
+
+		err = store.Batch([]TaskOperation{
+			{Type: "Add", ID: addedID, Title: "Batch Task", Priority: High},
+			{Type: "Edit", ID: keptID, Title: "Edited Task"},
+			{Type: "ToggleDone", ID: uuid.New()},
+		})
+		if !errors.Is(err, ErrTaskNotFound) {
+			t.Fatalf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+		tasks, _ := store.GetAllItems()
+		if len(tasks) != 1 || tasks[0].Title != "Test Task" {
+			t.Fatalf("expected the failed batch to change nothing, got %+v", tasks)
+		}
+
+		err = store.Batch([]TaskOperation{
+			{Type: "Add", ID: addedID, Title: "Batch Task", Priority: High},
+			{Type: "ToggleDone", ID: keptID},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		tasks, _ = store.GetAllItems()
+		if len(tasks) != 2 {
+			t.Errorf("expected 2 tasks, got %d", len(tasks))
+		}
+	})
 }
 
 func clearDB(store *PostgresStore) {
-	_, err := store.Db.Exec("TRUNCATE TABLE tasks RESTART IDENTITY CASCADE")
+	_, err := store.Db.Exec("TRUNCATE TABLE tasks, tags, lists, undo_stacks RESTART IDENTITY CASCADE")
 	if err != nil {
 		return
 	}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDependencyCycle = errors.New("a task can't be blocked by itself or by a task it blocks")
+	ErrBlocked         = errors.New("the task is blocked by open tasks")
+)
+
+// OpenBlockers returns the tasks blocking t that aren't done yet.
+func OpenBlockers(tasks []Task, t Task) []Task {
+	var open []Task
+	for _, blocker := range tasks {
+		if !blocker.Done && slices.Contains(t.BlockedBy, blocker.ID) {
+			open = append(open, blocker)
+		}
+	}
+	return open
+}
+
+// Blocked reports whether t is open and waits on open tasks.
+func Blocked(tasks []Task, t Task) bool {
+	return !t.Done && len(OpenBlockers(tasks, t)) > 0
+}
+
+// Blocking returns the IDs of the tasks blocked by the task id, of the tasks
+// they block and so on.
+func Blocking(tasks []Task, id uuid.UUID) []uuid.UUID {
+	var found []uuid.UUID
+	for queue := []uuid.UUID{id}; len(queue) > 0; queue = queue[1:] {
+		for _, t := range tasks {
+			if slices.Contains(t.BlockedBy, queue[0]) && !slices.Contains(found, t.ID) {
+				found = append(found, t.ID)
+				queue = append(queue, t.ID)
+			}
+		}
+	}
+	return found
+}
+
+// CheckBlocker reports whether the task id can be blocked by blocker without
+// the tasks waiting on each other.
+func CheckBlocker(tasks []Task, id, blocker uuid.UUID) error {
+	switch {
+	case indexOf(tasks, id) < 0 || indexOf(tasks, blocker) < 0:
+		return ErrTaskNotFound
+	case blocker == id || slices.Contains(Blocking(tasks, id), blocker):
+		return ErrDependencyCycle
+	}
+	return nil
+}
+
+// CheckDone reports whether the task id can be toggled: tasks can always be
+// reopened, but only completed once their blockers are done.
+func CheckDone(tasks []Task, id uuid.UUID) error {
+	i := indexOf(tasks, id)
+	switch {
+	case i < 0:
+		return ErrTaskNotFound
+	case Blocked(tasks, tasks[i]):
+		return ErrBlocked
+	}
+	return nil
+}
+
+func compareIDs(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// WithBlocker returns a copy of t also blocked by blocker, keeping the
+// blockers sorted the way the database does.
+func (t Task) WithBlocker(blocker uuid.UUID) Task {
+	if i, found := slices.BinarySearchFunc(t.BlockedBy, blocker, compareIDs); !found {
+		t.BlockedBy = slices.Insert(slices.Clone(t.BlockedBy), i, blocker)
+	}
+	return t
+}
+
+// WithoutBlocker returns a copy of t no longer blocked by blocker.
+func (t Task) WithoutBlocker(blocker uuid.UUID) Task {
+	t.BlockedBy = slices.DeleteFunc(slices.Clone(t.BlockedBy), func(id uuid.UUID) bool { return id == blocker })
+	if len(t.BlockedBy) == 0 {
+		t.BlockedBy = nil
+	}
+	return t
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReadyQuery(t *testing.T) {
+	migration, deploy, announce := uuid.New(), uuid.New(), uuid.New()
+	tasks := []Task{
+		{ID: migration, Title: "Write migration"},
+		{ID: deploy, Title: "Deploy", BlockedBy: []uuid.UUID{migration}},
+		{ID: announce, Title: "Announce", BlockedBy: []uuid.UUID{deploy}, Done: true},
+	}
+	titles := func(q Query) []string {
+		matched, _ := q.Run(tasks)
+		var titles []string
+		for _, t := range matched {
+			titles = append(titles, t.Title)
+		}
+		return titles
+	}
+	if got, expected := titles(Query{Status: "ready"}), []string{"Write migration"}; !slices.Equal(got, expected) {
+		t.Errorf("ready: expected %q, got %q", expected, got)
+	}
+	if got, expected := titles(Query{Status: "blocked"}), []string{"Deploy"}; !slices.Equal(got, expected) {
+		t.Errorf("blocked: expected %q, got %q", expected, got)
+	}
+
+	tasks[0].Done = true
+	if got, expected := titles(Query{Status: "ready"}), []string{"Deploy"}; !slices.Equal(got, expected) {
+		t.Errorf("ready once the blocker is done: expected %q, got %q", expected, got)
+	}
+}
+
+// testDependencies checks blocking tasks, the cycle check and completing
+// blocked tasks against an empty store.
+func testDependencies(t *testing.T, s Store) {
+	t.Helper()
+	migration, deploy, announce := uuid.New(), uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{migration, deploy, announce} {
+		if err := s.AddItem(id, "Test Task", Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	for task, blocker := range map[uuid.UUID]uuid.UUID{deploy: migration, announce: deploy} {
+		if err := s.AddBlocker(task, blocker); err != nil {
+			t.Fatalf("Error adding blocker: %s", err)
+		}
+	}
+	for _, blocker := range []uuid.UUID{announce, migration} {
+		if err := s.AddBlocker(migration, blocker); !errors.Is(err, ErrDependencyCycle) {
+			t.Errorf("expected %v, got %v", ErrDependencyCycle, err)
+		}
+	}
+	if err := s.AddBlocker(deploy, uuid.New()); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+	}
+	task := func(id uuid.UUID) Task {
+		tasks, _ := s.GetAllItems()
+		return tasks[indexOf(tasks, id)]
+	}
+	if blockers := task(deploy).BlockedBy; !slices.Equal(blockers, []uuid.UUID{migration}) {
+		t.Errorf("expected deploy blocked by the migration, got %v", blockers)
+	}
+
+	if err := s.ToggleDone(deploy); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected %v, got %v", ErrBlocked, err)
+	}
+	if err := s.ToggleDone(migration); err != nil {
+		t.Fatalf("Error toggling task: %s", err)
+	}
+	if err := s.ToggleDone(deploy); err != nil {
+		t.Errorf("expected deploy to be ready once the migration is done, got %v", err)
+	}
+
+	if err := s.RemoveBlocker(announce, deploy); err != nil {
+		t.Fatalf("Error removing blocker: %s", err)
+	}
+	if blockers := task(announce).BlockedBy; len(blockers) != 0 {
+		t.Errorf("expected no blockers, got %v", blockers)
+	}
+	if err := s.DeleteItem(migration); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+	if blockers := task(deploy).BlockedBy; len(blockers) != 0 {
+		t.Errorf("expected the deleted blocker to be dropped, got %v", blockers)
+	}
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// eventHistory is how many past events a Broker keeps for subscribers that
+// resume after a disconnect.
+const eventHistory = 256
+
+// Event describes a change applied to a store.
+type Event struct {
+	ID   uint64
+	Type string
+	IDs  []uuid.UUID
+}
+
+// Notifier is implemented by stores that publish an Event for every change.
+type Notifier interface {
+	Events() *Broker
+}
+
+// Broker fans out store events to subscribers and keeps a short history so
+// they can resume from the last event they saw.
+type Broker struct {
+	mu          sync.Mutex
+	last        uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[chan Event]struct{}{}}
+}
+
+// Publish records the change made by op and sends it to every subscriber.
+// Subscribers that fall behind are dropped; they can resume from history.
+func (b *Broker) Publish(op TaskOperation) {
+	event := Event{Type: op.Type}
+	if op.Type == "Batch" || len(op.Batch) > 0 {
+		for _, o := range op.Batch {
+			event.IDs = append(event.IDs, o.ID)
+		}
+	} else {
+		event.IDs = []uuid.UUID{op.ID}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.last++
+	event.ID = b.last
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistory {
+		b.history = b.history[len(b.history)-eventHistory:]
+	}
+	for c := range b.subscribers {
+		select {
+		case c <- event:
+		default:
+			delete(b.subscribers, c)
+			close(c)
+		}
+	}
+}
+
+// Subscription receives the events published after it was opened.
+type Subscription struct {
+	// Missed holds the events published after the requested ID.
+	Missed []Event
+	// Reset is set when some of those events are no longer in the history;
+	// subscribers should reload everything and resume from Last.
+	Reset bool
+	Last  uint64
+	C     <-chan Event
+
+	broker *Broker
+	c      chan Event
+}
+
+// Subscribe starts receiving events. A non-zero lastID resumes after the
+// event with that ID.
+func (b *Broker) Subscribe(lastID uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Event, 16)
+	b.subscribers[c] = struct{}{}
+	sub := &Subscription{Last: b.last, C: c, broker: b, c: c}
+
+	switch {
+	case lastID == 0 || lastID == b.last:
+	case lastID > b.last || len(b.history) == 0 || lastID < b.history[0].ID-1:
+		sub.Reset = true
+	default:
+		for _, e := range b.history {
+			if e.ID > lastID {
+				sub.Missed = append(sub.Missed, e)
+			}
+		}
+	}
+	return sub
+}
+
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	if _, ok := s.broker.subscribers[s.c]; ok {
+		delete(s.broker.subscribers, s.c)
+		close(s.c)
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type InMemoryAccounts struct {
+	mu       sync.Mutex
+	users    map[string]User
+	sessions map[string]Session
+	filePath string
+}
+
+type accountsFile struct {
+	Users    []User    `json:"users"`
+	Sessions []Session `json:"sessions"`
+}
+
+func NewInMemoryAccounts(config Config) (*InMemoryAccounts, error) {
+	a := &InMemoryAccounts{
+		users:    map[string]User{},
+		sessions: map[string]Session{},
+	}
+	if config.LoadFromFile {
+		a.filePath = "users.json"
+		if err := a.load(); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *InMemoryAccounts) AddUser(user User) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.users[user.Name]; ok {
+		return ErrUserExists
+	}
+	a.users[user.Name] = user
+	return a.save()
+}
+
+func (a *InMemoryAccounts) GetUser(name string) (User, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[name]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (a *InMemoryAccounts) Users() ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(a.users)), nil
+}
+
+func (a *InMemoryAccounts) AddSession(session Session) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sessions[session.Token] = session
+	return a.save()
+}
+
+func (a *InMemoryAccounts) GetSession(token string) (Session, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	session, ok := a.sessions[token]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	if time.Now().After(session.Expires) {
+		delete(a.sessions, token)
+		return Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (a *InMemoryAccounts) DeleteSession(token string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.sessions, token)
+	return a.save()
+}
+
+func (a *InMemoryAccounts) load() error {
+	b, err := os.ReadFile(a.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var f accountsFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	for _, user := range f.Users {
+		a.users[user.Name] = user
+	}
+	for _, session := range f.Sessions {
+		a.sessions[session.Token] = session
+	}
+	return nil
+}
+
+// save writes the accounts to the file; callers hold a.mu.
+func (a *InMemoryAccounts) save() error {
+	if a.filePath == "" {
+		return nil
+	}
+
+	var f accountsFile
+	for _, user := range a.users {
+		f.Users = append(f.Users, user)
+	}
+	now := time.Now()
+	for _, session := range a.sessions {
+		if session.Expires.After(now) {
+			f.Sessions = append(f.Sessions, session)
+		}
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(a.filePath, b, 0o600)
+}
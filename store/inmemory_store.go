@@ -2,28 +2,42 @@ package store
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type InMemoryStore struct {
 	tasks       []Task
+	trash       []Task
+	lists       []List
+	entries     []TimeEntry
+	undo        UndoStack
 	mu          sync.Mutex
 	taskChannel chan TaskOperation
 	stopChannel chan struct{}
 	filePath    string
+	events      *Broker
 }
 
 func NewInMemoryStore(config Config) (*InMemoryStore, error) {
+	filePath := config.FilePath
+	if filePath == "" {
+		filePath = "tasks.json"
+		if config.User != "" {
+			filePath = "tasks_" + config.User + ".json"
+		}
+	}
 	store := &InMemoryStore{
 		tasks:       []Task{},
 		taskChannel: make(chan TaskOperation),
 		stopChannel: make(chan struct{}),
-		filePath:    "tasks.json",
+		filePath:    filePath,
+		events:      NewBroker(),
 	}
 	if config.LoadFromFile {
 		store.loadTasksFromFile()
@@ -32,8 +46,35 @@ func NewInMemoryStore(config Config) (*InMemoryStore, error) {
 	return store, nil
 }
 
+func (s *InMemoryStore) Events() *Broker {
+	return s.events
+}
+
+// GetAllItems returns the tasks, none of them waiting on trashed tasks.
 func (s *InMemoryStore) GetAllItems() ([]Task, error) {
-	return s.tasks, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return unblocked(s.tasks, s.tasks), nil
+}
+
+func (s *InMemoryStore) GetTrash() ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trash := unblocked(s.trash, s.tasks)
+	sortTrash(trash)
+	return trash, nil
+}
+
+func (s *InMemoryStore) GetTimeEntries() ([]TimeEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.entries), nil
+}
+
+func (s *InMemoryStore) GetLists() ([]List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.lists), nil
 }
 
 func (s *InMemoryStore) processTasks() {
@@ -44,55 +85,30 @@ func (s *InMemoryStore) processTasks() {
 				fmt.Println("Task channel closed")
 				return
 			}
+
+			s.mu.Lock()
 			var err error
-			switch op.Type {
-
-			case "Add":
-				task := Task{
-					ID:       op.ID,
-					Title:    op.Title,
-					Priority: op.Priority,
-					Done:     false,
-				}
-				s.tasks = append(s.tasks, task)
-			case "Delete":
-				found := false
-				for i, task := range s.tasks {
-					if task.ID == op.ID {
-						s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
-						found = true
-						break
-					}
-				}
-				if !found {
-					err = errors.New("task not found")
-				}
-			case "Edit":
-				found := false
-				for i, task := range s.tasks {
-					if task.ID == op.ID {
-						s.tasks[i].Title = op.Title
-						found = true
-						break
-					}
-				}
-				if !found {
-					err = errors.New("task not found")
-				}
-			case "ToggleDone":
-				found := false
-				for i, task := range s.tasks {
-					if task.ID == op.ID {
-						s.tasks[i].Done = !s.tasks[i].Done
-						found = true
-						break
-					}
+			var recorded bool
+			switch before := s.clone(); op.Type {
+			case "Undo", "Redo":
+				op.Batch, err = s.undo.step(op.Type == "Undo", s.snapshot, s.applyBatch)
+			case "Batch":
+				if err = s.applyBatch(op.Batch); err == nil {
+					recorded, err = s.undo.record(before, op.Batch, s.snapshot)
 				}
-				if !found {
-					err = errors.New("task not found")
+			default:
+				if err = s.apply(op); err == nil && !op.Unrecorded {
+					recorded, err = s.undo.record(before, []TaskOperation{op}, s.snapshot)
 				}
 			}
+			s.mu.Unlock()
 
+			if err == nil {
+				s.events.Publish(op)
+			}
+			if op.Recorded != nil && err == nil {
+				op.Recorded <- recorded
+			}
 			if op.Result != nil {
 				op.Result <- err
 				close(op.Result)
@@ -105,6 +121,268 @@ func (s *InMemoryStore) processTasks() {
 	}
 }
 
+// apply performs a single operation; callers hold s.mu.
+func (s *InMemoryStore) apply(op TaskOperation) error {
+	var err error
+	switch op.Type {
+
+	case "Add":
+		if indexOf(s.tasks, op.ID) >= 0 || indexOf(s.trash, op.ID) >= 0 {
+			err = ErrTaskExists
+			break
+		}
+		task := Task{
+			ID:       op.ID,
+			Title:    op.Title,
+			Priority: op.Priority,
+			Done:     false,
+			Position: NextPosition(append(slices.Clone(s.tasks), s.trash...)),
+		}
+		s.tasks = append(s.tasks, task)
+	case "Delete", "DeleteTree":
+		var tasks, trash []Task
+		if tasks, trash, err = TrashTask(s.tasks, s.trash, op.ID, op.Type == "DeleteTree", time.Now()); err == nil {
+			s.tasks, s.trash = tasks, trash
+		}
+	case "Restore":
+		var tasks, trash []Task
+		if tasks, trash, err = RestoreTask(s.tasks, s.trash, op.ID); err == nil {
+			s.tasks, s.trash = tasks, trash
+		}
+	case "Purge":
+		var trash []Task
+		if trash, err = PurgeTask(s.trash, op.ID); err == nil {
+			s.trash = trash
+			s.dropPurged()
+		}
+	case "EmptyTrash":
+		s.trash = EmptyTrash(s.trash, op.DeletedBefore)
+		s.dropPurged()
+	case "StartTimer", "AddTimeEntry", "EditTimeEntry":
+		e := TimeEntry{ID: op.ID, Task: op.Task, Start: op.Start, End: op.End}
+		if op.Type == "StartTimer" {
+			e.Start, e.End = time.Now(), time.Time{}
+		}
+		i := slices.IndexFunc(s.entries, func(e TimeEntry) bool { return e.ID == op.ID })
+		switch {
+		case op.Type == "EditTimeEntry" && i < 0:
+			err = ErrEntryNotFound
+		case op.Type != "EditTimeEntry" && i >= 0:
+			err = ErrEntryExists
+		case indexOf(s.tasks, e.Task) < 0:
+			err = ErrTaskNotFound
+		default:
+			err = CheckEntry(s.entries, e)
+		}
+		if err == nil && i >= 0 {
+			s.entries[i] = e
+		} else if err == nil {
+			s.entries = append(s.entries, e)
+		}
+		sortEntries(s.entries)
+	case "StopTimer":
+		if i := RunningTimer(s.entries); i < 0 {
+			err = ErrNoTimer
+		} else {
+			s.entries[i].End = time.Now()
+		}
+	case "DeleteTimeEntry":
+		if i := slices.IndexFunc(s.entries, func(e TimeEntry) bool { return e.ID == op.ID }); i < 0 {
+			err = ErrEntryNotFound
+		} else {
+			s.entries = slices.Delete(s.entries, i, i+1)
+		}
+	case "Archive":
+		err = ArchiveTask(s.tasks, op.ID, op.Archived, time.Now())
+	case "ArchiveDone":
+		ArchiveDone(s.tasks, op.CompletedBefore, time.Now())
+	case "Edit":
+		found := false
+		for i, task := range s.tasks {
+			if task.ID == op.ID {
+				s.tasks[i].Title = op.Title
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = ErrTaskNotFound
+		}
+	case "ToggleDone":
+		if err = CheckDone(s.tasks, op.ID); err == nil {
+			i := indexOf(s.tasks, op.ID)
+			s.tasks[i] = s.tasks[i].WithDone(!s.tasks[i].Done)
+			// A next occurrence deleted since stays in the trash.
+			if indexOf(s.trash, NextOccurrenceID(op.ID)) < 0 {
+				s.tasks = Recur(s.tasks, op.ID)
+			}
+			RollUp(s.tasks, s.tasks[i].Parent)
+		}
+	case "SetPriority":
+		found := false
+		for i, task := range s.tasks {
+			if task.ID == op.ID {
+				s.tasks[i].Priority = op.Priority
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = ErrTaskNotFound
+		}
+	case "Move":
+		var tasks []Task
+		if tasks, err = Reorder(s.tasks, op.ID, op.Before); err == nil {
+			s.tasks = tasks
+		}
+	case "AddTag", "RemoveTag":
+		tag, ok := NormalizeTag(op.Tag)
+		i := slices.IndexFunc(s.tasks, func(t Task) bool { return t.ID == op.ID })
+		switch {
+		case !ok:
+			err = ErrInvalidTag
+		case i < 0:
+			err = ErrTaskNotFound
+		case op.Type == "AddTag":
+			s.tasks[i] = s.tasks[i].WithTag(tag)
+		default:
+			s.tasks[i] = s.tasks[i].WithoutTag(tag)
+		}
+	case "CreateList", "RenameList":
+		err = s.nameList(op.Type == "CreateList", op.ID, op.Title)
+	case "ArchiveList", "DeleteList":
+		i := slices.IndexFunc(s.lists, func(l List) bool { return l.ID == op.ID })
+		switch {
+		case i < 0:
+			err = ErrListNotFound
+		case op.Type == "ArchiveList":
+			s.lists[i].Archived = op.Archived
+		default:
+			// The tasks of a deleted list go back to the inbox.
+			s.lists = slices.Delete(s.lists, i, i+1)
+			for _, tasks := range [][]Task{s.tasks, s.trash} {
+				for i := range tasks {
+					if tasks[i].List == op.ID {
+						tasks[i].List = uuid.Nil
+					}
+				}
+			}
+		}
+	case "SetList":
+		i := slices.IndexFunc(s.tasks, func(t Task) bool { return t.ID == op.ID })
+		switch {
+		case i < 0:
+			err = ErrTaskNotFound
+		case op.List != uuid.Nil && !slices.ContainsFunc(s.lists, func(l List) bool { return l.ID == op.List }):
+			err = ErrListNotFound
+		default:
+			s.tasks[i].List = op.List
+		}
+	case "SetParent":
+		if err = CheckParent(s.tasks, op.ID, op.Parent); err == nil {
+			i := indexOf(s.tasks, op.ID)
+			previous := s.tasks[i].Parent
+			s.tasks[i].Parent = op.Parent
+			RollUp(s.tasks, previous)
+			RollUp(s.tasks, op.Parent)
+		}
+	case "SetAutoComplete":
+		if i := indexOf(s.tasks, op.ID); i < 0 {
+			err = ErrTaskNotFound
+		} else {
+			s.tasks[i].AutoComplete = op.AutoComplete
+			RollUp(s.tasks, op.ID)
+		}
+	case "AddBlocker":
+		if err = CheckBlocker(s.tasks, op.ID, op.Blocker); err == nil {
+			i := indexOf(s.tasks, op.ID)
+			s.tasks[i] = s.tasks[i].WithBlocker(op.Blocker)
+		}
+	case "RemoveBlocker":
+		if i := indexOf(s.tasks, op.ID); i < 0 {
+			err = ErrTaskNotFound
+		} else {
+			s.tasks[i] = s.tasks[i].WithoutBlocker(op.Blocker)
+		}
+	case "SetRepeat":
+		rule, ok := NormalizeRecurrence(op.Repeat)
+		i := indexOf(s.tasks, op.ID)
+		switch {
+		case !ok:
+			err = ErrInvalidRecurrence
+		case i < 0:
+			err = ErrTaskNotFound
+		default:
+			s.tasks[i].Repeat = rule
+		}
+	case "Update":
+		found := false
+		for i, task := range s.tasks {
+			if task.ID == op.ID {
+				s.tasks[i].Title = op.Title
+				s.tasks[i].Priority = op.Priority
+				s.tasks[i].Description = op.Description
+				s.tasks[i].Due = op.Due
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = ErrTaskNotFound
+		}
+	default:
+		err = fmt.Errorf("unknown operation %q", op.Type)
+	}
+	return err
+}
+
+// nameList creates the list id or renames it, keeping names unique.
+func (s *InMemoryStore) nameList(create bool, id uuid.UUID, name string) error {
+	name, ok := NormalizeListName(name)
+	if !ok {
+		return ErrInvalidList
+	}
+	if other, ok := FindList(s.lists, name); ok && other.ID != id {
+		return ErrListExists
+	}
+	i := slices.IndexFunc(s.lists, func(l List) bool { return l.ID == id })
+	switch {
+	case create && i >= 0:
+		return ErrListExists
+	case create:
+		s.lists = append(s.lists, List{ID: id, Name: name})
+	case i < 0:
+		return ErrListNotFound
+	default:
+		s.lists[i].Name = name
+	}
+	sortLists(s.lists)
+	return nil
+}
+
+// dropPurged forgets the time spent on tasks purged from the trash and what
+// they blocked; callers hold s.mu.
+func (s *InMemoryStore) dropPurged() {
+	all := append(slices.Clone(s.tasks), s.trash...)
+	s.tasks, s.trash = unblocked(s.tasks, all), unblocked(s.trash, all)
+	s.entries = slices.DeleteFunc(slices.Clone(s.entries), func(e TimeEntry) bool {
+		return indexOf(s.tasks, e.Task) < 0 && indexOf(s.trash, e.Task) < 0
+	})
+}
+
+// applyBatch performs ops in order, leaving the tasks untouched if any of
+// them fails; callers hold s.mu.
+func (s *InMemoryStore) applyBatch(ops []TaskOperation) error {
+	snapshot := s.clone()
+	for i, op := range ops {
+		if err := s.apply(op); err != nil {
+			s.tasks, s.trash, s.lists, s.entries = snapshot.tasks, snapshot.trash, snapshot.lists, snapshot.entries
+			return fmt.Errorf("operation %d (%s): %w", i+1, op.Type, err)
+		}
+	}
+	return nil
+}
+
 func (s *InMemoryStore) AddItem(id uuid.UUID, t string, p Priority) error {
 	result := make(chan error)
 	s.taskChannel <- TaskOperation{
@@ -148,8 +426,326 @@ func (s *InMemoryStore) ToggleDone(id uuid.UUID) error {
 	return <-result
 }
 
+// Record performs ops as Batch does, a single one on its own, and reports
+// whether they were recorded for Undo.
+func (s *InMemoryStore) Record(ops ...TaskOperation) (bool, error) {
+	op := TaskOperation{Type: "Batch", Batch: ops}
+	if len(ops) == 1 {
+		op = ops[0]
+	}
+	result, recorded := make(chan error), make(chan bool, 1)
+	op.Result, op.Recorded = result, recorded
+	s.taskChannel <- op
+	if err := <-result; err != nil {
+		return false, err
+	}
+	return <-recorded, nil
+}
+
+func (s *InMemoryStore) Batch(ops []TaskOperation) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Batch",
+		Batch:  ops,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) SetPriority(id uuid.UUID, p Priority) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:     "SetPriority",
+		ID:       id,
+		Priority: p,
+		Result:   result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) MoveTask(id, before uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Move",
+		ID:     id,
+		Before: before,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) AddTag(id uuid.UUID, tag string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "AddTag",
+		ID:     id,
+		Tag:    tag,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) RemoveTag(id uuid.UUID, tag string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "RemoveTag",
+		ID:     id,
+		Tag:    tag,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) CreateList(id uuid.UUID, name string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "CreateList",
+		ID:     id,
+		Title:  name,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) RenameList(id uuid.UUID, name string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "RenameList",
+		ID:     id,
+		Title:  name,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) ArchiveList(id uuid.UUID, archived bool) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:     "ArchiveList",
+		ID:       id,
+		Archived: archived,
+		Result:   result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) DeleteList(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "DeleteList",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) SetList(taskID, listID uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "SetList",
+		ID:     taskID,
+		List:   listID,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) SetParent(id, parent uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "SetParent",
+		ID:     id,
+		Parent: parent,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) SetAutoComplete(id uuid.UUID, auto bool) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:         "SetAutoComplete",
+		ID:           id,
+		AutoComplete: auto,
+		Result:       result,
+	}
+	return <-result
+}
+
+// DeleteTree deletes a task together with its subtasks, where DeleteItem
+// moves them up to its parent.
+func (s *InMemoryStore) DeleteTree(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "DeleteTree",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) AddBlocker(id, blocker uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:    "AddBlocker",
+		ID:      id,
+		Blocker: blocker,
+		Result:  result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) RemoveBlocker(id, blocker uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:    "RemoveBlocker",
+		ID:      id,
+		Blocker: blocker,
+		Result:  result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) SetRepeat(id uuid.UUID, rule string) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "SetRepeat",
+		ID:     id,
+		Repeat: rule,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) RestoreTask(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Restore",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) PurgeTask(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "Purge",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) EmptyTrash(before time.Time) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:          "EmptyTrash",
+		DeletedBefore: before,
+		Result:        result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) ArchiveTask(id uuid.UUID, archived bool) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:     "Archive",
+		ID:       id,
+		Archived: archived,
+		Result:   result,
+	}
+	return <-result
+}
+
+// unrecorded performs op, leaving it out of the undo history.
+func (s *InMemoryStore) unrecorded(op TaskOperation) error {
+	result := make(chan error)
+	op.Unrecorded, op.Result = true, result
+	s.taskChannel <- op
+	return <-result
+}
+
+func (s *InMemoryStore) ArchiveDone(before time.Time) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:            "ArchiveDone",
+		CompletedBefore: before,
+		Result:          result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) StartTimer(id, task uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "StartTimer",
+		ID:     id,
+		Task:   task,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) StopTimer() error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{Type: "StopTimer", Result: result}
+	return <-result
+}
+
+func (s *InMemoryStore) AddTimeEntry(e TimeEntry) error {
+	result := make(chan error)
+	op := EntryOperation("AddTimeEntry", e)
+	op.Result = result
+	s.taskChannel <- op
+	return <-result
+}
+
+func (s *InMemoryStore) EditTimeEntry(e TimeEntry) error {
+	result := make(chan error)
+	op := EntryOperation("EditTimeEntry", e)
+	op.Result = result
+	s.taskChannel <- op
+	return <-result
+}
+
+func (s *InMemoryStore) DeleteTimeEntry(id uuid.UUID) error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{
+		Type:   "DeleteTimeEntry",
+		ID:     id,
+		Result: result,
+	}
+	return <-result
+}
+
+func (s *InMemoryStore) Undo() error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{Type: "Undo", Result: result}
+	return <-result
+}
+
+func (s *InMemoryStore) Redo() error {
+	result := make(chan error)
+	s.taskChannel <- TaskOperation{Type: "Redo", Result: result}
+	return <-result
+}
+
+func (s *InMemoryStore) UpdateTask(t Task) error {
+	result := make(chan error)
+	op := UpdateOperation(t)
+	op.Result = result
+	s.taskChannel <- op
+	return <-result
+}
+
 type TaskFile struct {
 	Tasks []Task `json:"tasks"`
+	Lists []List `json:"lists,omitempty"`
+	Trash []Task `json:"trash,omitempty"`
+	// Undo keeps the changes that can be undone across runs of the CLI.
+	Undo    UndoStack   `json:"undo,omitzero"`
+	Entries []TimeEntry `json:"entries,omitempty"`
 }
 
 func (s *InMemoryStore) loadTasksFromFile() {
@@ -177,7 +773,15 @@ func (s *InMemoryStore) loadTasksFromFile() {
 		panic(err)
 	}
 
+	sortByPosition(taskFile.Tasks)
+	sortLists(taskFile.Lists)
+	backfillCompleted(taskFile.Tasks, time.Now())
+	backfillCompleted(taskFile.Trash, time.Now())
 	s.tasks = taskFile.Tasks
+	s.lists = taskFile.Lists
+	s.trash = taskFile.Trash
+	s.undo = taskFile.Undo
+	s.entries = taskFile.Entries
 }
 
 func (s *InMemoryStore) SaveTasksToFile() {
@@ -196,7 +800,11 @@ func (s *InMemoryStore) SaveTasksToFile() {
 	}(file)
 
 	taskFile := TaskFile{
-		Tasks: s.tasks,
+		Tasks:   s.tasks,
+		Lists:   s.lists,
+		Trash:   s.trash,
+		Undo:    s.undo,
+		Entries: s.entries,
 	}
 
 	encoder := json.NewEncoder(file)
@@ -1,9 +1,12 @@
 package store
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -38,6 +41,9 @@ func TestInMemoryStore(t *testing.T) {
 		if tasks[0].Priority != taskPriority {
 			t.Errorf("expected task priority '%s', got '%s'", taskPriority, tasks[0].Priority)
 		}
+		if err := store.AddItem(taskID, "Other Task", Low); !errors.Is(err, ErrTaskExists) {
+			t.Errorf("expected %v adding a task twice, got %v", ErrTaskExists, err)
+		}
 
 	})
 
@@ -104,6 +110,203 @@ func TestInMemoryStore(t *testing.T) {
 		}
 
 	})
+	t.Run("set priority", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		taskID := uuid.New()
+		err := store.AddItem(taskID, "Test Task", Low)
+		if err != nil {
+			t.Errorf("Error adding task: %s", err)
+		}
+
+		if err := store.SetPriority(taskID, High); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		tasks, _ := store.GetAllItems()
+		if len(tasks) != 1 {
+			t.Errorf("expected 1 task, got %d", len(tasks))
+		}
+		if tasks[0].Priority != High {
+			t.Errorf("expected task priority '%s', got '%s'", High, tasks[0].Priority)
+		}
+
+		if err := store.SetPriority(uuid.New(), High); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+	})
+	t.Run("update task", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		taskID := uuid.New()
+		err := store.AddItem(taskID, "Test Task", Low)
+		if err != nil {
+			t.Errorf("Error adding task: %s", err)
+		}
+
+		due := time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC)
+		update := Task{ID: taskID, Title: "Updated Task", Priority: High, Description: "Details", Due: due}
+		if err := store.UpdateTask(update); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		tasks, _ := store.GetAllItems()
+		if len(tasks) != 1 {
+			t.Fatalf("expected 1 task, got %d", len(tasks))
+		}
+		got := tasks[0]
+		if got.Title != update.Title || got.Priority != High || got.Description != "Details" || !got.Due.Equal(due) {
+			t.Errorf("expected %+v, got %+v", update, got)
+		}
+
+		if err := store.UpdateTask(Task{ID: uuid.New(), Title: "Missing", Priority: Low}); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+	})
+	t.Run("move task", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+
+		first, second, third := uuid.New(), uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{first, second, third} {
+			if err := store.AddItem(id, "Test Task", Low); err != nil {
+				t.Fatalf("Error adding task: %s", err)
+			}
+		}
+		order := func() []uuid.UUID {
+			tasks, _ := store.GetAllItems()
+			var ids []uuid.UUID
+			for _, task := range tasks {
+				ids = append(ids, task.ID)
+			}
+			return ids
+		}
+
+		moves := []struct {
+			id, before uuid.UUID
+			want       []uuid.UUID
+		}{
+			{third, first, []uuid.UUID{third, first, second}},
+			{third, uuid.Nil, []uuid.UUID{first, second, third}},
+			{first, third, []uuid.UUID{second, first, third}},
+			{second, second, []uuid.UUID{second, first, third}},
+		}
+		for _, m := range moves {
+			if err := store.MoveTask(m.id, m.before); err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if got := order(); !slices.Equal(got, m.want) {
+				t.Errorf("after moving %s before %s: expected %v, got %v", m.id, m.before, m.want, got)
+			}
+		}
+
+		if err := store.MoveTask(uuid.New(), first); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+		if err := store.MoveTask(first, uuid.New()); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+	})
+	t.Run("tags", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testTags(t, store)
+	})
+	t.Run("lists", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testLists(t, store)
+	})
+	t.Run("subtasks", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testSubtasks(t, store)
+	})
+	t.Run("dependencies", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testDependencies(t, store)
+	})
+	t.Run("recurrence", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testRecurrence(t, store)
+	})
+	t.Run("trash", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testTrash(t, store)
+	})
+	t.Run("archive", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testArchive(t, store)
+	})
+	t.Run("timer", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testTimer(t, store)
+	})
+	t.Run("undo", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		testUndo(t, store)
+	})
+	t.Run("batch", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		keptID, addedID := uuid.New(), uuid.New()
+		err := store.AddItem(keptID, "Test Task", Low)
+		if err != nil {
+			t.Errorf("Error adding task: %s", err)
+		}
+
+		err = store.Batch([]TaskOperation{
+			{Type: "Add", ID: addedID, Title: "Batch Task", Priority: High},
+			{Type: "Edit", ID: keptID, Title: "Edited Task"},
+			{Type: "ToggleDone", ID: uuid.New()},
+		})
+		if !errors.Is(err, ErrTaskNotFound) {
+			t.Fatalf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+		tasks, _ := store.GetAllItems()
+		if len(tasks) != 1 || tasks[0].Title != "Test Task" {
+			t.Fatalf("expected the failed batch to change nothing, got %+v", tasks)
+		}
+
+		err = store.Batch([]TaskOperation{
+			{Type: "Add", ID: addedID, Title: "Batch Task", Priority: High},
+			{Type: "ToggleDone", ID: keptID},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		tasks, _ = store.GetAllItems()
+		if len(tasks) != 2 {
+			t.Errorf("expected 2 tasks, got %d", len(tasks))
+		}
+	})
+	t.Run("events", func(t *testing.T) {
+		store, _ := NewInMemoryStore(c)
+		sub := store.Events().Subscribe(0)
+		defer sub.Close()
+
+		taskID := uuid.New()
+		if err := store.AddItem(taskID, "Test Task", Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+		if err := store.ToggleDone(uuid.New()); !errors.Is(err, ErrTaskNotFound) {
+			t.Fatalf("expected %v, got %v", ErrTaskNotFound, err)
+		}
+		if err := store.ToggleDone(taskID); err != nil {
+			t.Fatalf("Error toggling task: %s", err)
+		}
+
+		for _, expected := range []Event{{ID: 1, Type: "Add"}, {ID: 2, Type: "ToggleDone"}} {
+			e := <-sub.C
+			if e.ID != expected.ID || e.Type != expected.Type || len(e.IDs) != 1 || e.IDs[0] != taskID {
+				t.Errorf("expected %+v for %s, got %+v", expected, taskID, e)
+			}
+		}
+
+		resumed := store.Events().Subscribe(1)
+		defer resumed.Close()
+		if resumed.Reset || len(resumed.Missed) != 1 || resumed.Missed[0].ID != 2 {
+			t.Errorf("expected to resume with event 2, got %+v", resumed)
+		}
+		restarted := store.Events().Subscribe(10)
+		defer restarted.Close()
+		if !restarted.Reset || restarted.Last != 2 {
+			t.Errorf("expected a reset at event 2, got %+v", restarted)
+		}
+	})
 }
 
 func BenchmarkNewInMemoryStore(b *testing.B) {
@@ -210,3 +413,52 @@ func BenchmarkNewInMemoryStore(b *testing.B) {
 		})
 	})
 }
+
+func TestInMemoryAccounts(t *testing.T) {
+	c := Config{LoadFromFile: false}
+
+	t.Run("add user", func(t *testing.T) {
+		accounts, _ := NewInMemoryAccounts(c)
+		if err := accounts.AddUser(User{Name: "alice", PasswordHash: "hash"}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if err := accounts.AddUser(User{Name: "alice", PasswordHash: "other"}); !errors.Is(err, ErrUserExists) {
+			t.Errorf("expected %v, got %v", ErrUserExists, err)
+		}
+
+		user, err := accounts.GetUser("alice")
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if user.PasswordHash != "hash" {
+			t.Errorf("expected password hash 'hash', got '%s'", user.PasswordHash)
+		}
+		if _, err := accounts.GetUser("bob"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("expected %v, got %v", ErrUserNotFound, err)
+		}
+
+		_ = accounts.AddUser(User{Name: "aaron", PasswordHash: "hash"})
+		if users, _ := accounts.Users(); !slices.Equal(users, []string{"aaron", "alice"}) {
+			t.Errorf("expected aaron and alice, got %v", users)
+		}
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		accounts, _ := NewInMemoryAccounts(c)
+		_ = accounts.AddSession(Session{Token: "valid", User: "alice", Expires: time.Now().Add(time.Hour)})
+		_ = accounts.AddSession(Session{Token: "expired", User: "alice", Expires: time.Now().Add(-time.Hour)})
+
+		session, err := accounts.GetSession("valid")
+		if err != nil || session.User != "alice" {
+			t.Errorf("expected alice's session, got %+v, %v", session, err)
+		}
+		if _, err := accounts.GetSession("expired"); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected %v, got %v", ErrSessionNotFound, err)
+		}
+
+		_ = accounts.DeleteSession("valid")
+		if _, err := accounts.GetSession("valid"); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected %v, got %v", ErrSessionNotFound, err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrListNotFound = errors.New("list not found")
+	ErrListExists   = errors.New("a list with this name already exists")
+	ErrInvalidList  = errors.New("invalid list name")
+)
+
+const maxListNameLength = 50
+
+// Inbox is the List filter of a Query selecting the tasks in no list.
+const Inbox = "inbox"
+
+// List is a named group of tasks, such as a project. Tasks outside of any
+// list are in the inbox.
+type List struct {
+	ID       uuid.UUID `json:"ID"`
+	Name     string    `json:"Name"`
+	Archived bool      `json:"Archived"`
+}
+
+// ListKey is the List filter of a Query selecting the tasks in the list id.
+func ListKey(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return Inbox
+	}
+	return id.String()
+}
+
+// FindList returns the list in lists named name, ignoring case, or with the
+// ID name.
+func FindList(lists []List, name string) (List, bool) {
+	i := slices.IndexFunc(lists, func(l List) bool {
+		return strings.EqualFold(l.Name, name) || l.ID.String() == name
+	})
+	if i < 0 {
+		return List{}, false
+	}
+	return lists[i], true
+}
+
+// NormalizeListName trims name and reports whether it can name a list: it
+// may not be empty, too long or "inbox", which stands for no list.
+func NormalizeListName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	n := utf8.RuneCountInString(name)
+	return name, n > 0 && n <= maxListNameLength && !strings.EqualFold(name, Inbox)
+}
+
+func sortLists(lists []List) {
+	slices.SortStableFunc(lists, func(a, b List) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+}
+
+// WithoutArchived returns the tasks that aren't in an archived list.
+func WithoutArchived(tasks []Task, lists []List) []Task {
+	archived := map[uuid.UUID]bool{}
+	for _, l := range lists {
+		archived[l.ID] = l.Archived
+	}
+	return slices.DeleteFunc(slices.Clone(tasks), func(t Task) bool { return archived[t.List] })
+}
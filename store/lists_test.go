@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testLists checks managing lists and moving tasks between them against an
+// empty store.
+func testLists(t *testing.T, s Store) {
+	t.Helper()
+	work, home := uuid.New(), uuid.New()
+	if err := s.CreateList(work, "Work"); err != nil {
+		t.Fatalf("Error creating list: %s", err)
+	}
+	if err := s.CreateList(home, "Chores"); err != nil {
+		t.Fatalf("Error creating list: %s", err)
+	}
+	if err := s.CreateList(uuid.New(), "work"); !errors.Is(err, ErrListExists) {
+		t.Errorf("expected %v, got %v", ErrListExists, err)
+	}
+	for _, name := range []string{"", "  ", "Inbox"} {
+		if err := s.CreateList(uuid.New(), name); !errors.Is(err, ErrInvalidList) {
+			t.Errorf("create %q: expected %v, got %v", name, ErrInvalidList, err)
+		}
+	}
+	if err := s.RenameList(home, " Home "); err != nil {
+		t.Fatalf("Error renaming list: %s", err)
+	}
+	if err := s.RenameList(home, "WORK"); !errors.Is(err, ErrListExists) {
+		t.Errorf("expected %v, got %v", ErrListExists, err)
+	}
+	if err := s.RenameList(uuid.New(), "Other"); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected %v, got %v", ErrListNotFound, err)
+	}
+	if err := s.ArchiveList(home, true); err != nil {
+		t.Fatalf("Error archiving list: %s", err)
+	}
+
+	lists, err := s.GetLists()
+	if err != nil {
+		t.Fatalf("Error getting lists: %s", err)
+	}
+	expected := []List{{ID: home, Name: "Home", Archived: true}, {ID: work, Name: "Work"}}
+	if len(lists) != len(expected) || lists[0] != expected[0] || lists[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, lists)
+	}
+
+	taskID := uuid.New()
+	if err := s.AddItem(taskID, "Test Task", Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	list := func() uuid.UUID {
+		tasks, _ := s.GetAllItems()
+		return tasks[0].List
+	}
+	if err := s.SetList(taskID, work); err != nil {
+		t.Fatalf("Error moving task: %s", err)
+	}
+	if list() != work {
+		t.Errorf("expected the task in %s, got %s", work, list())
+	}
+	if err := s.SetList(taskID, uuid.New()); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected %v, got %v", ErrListNotFound, err)
+	}
+	if err := s.SetList(uuid.New(), work); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+	}
+
+	if err := s.DeleteList(work); err != nil {
+		t.Fatalf("Error deleting list: %s", err)
+	}
+	if list() != uuid.Nil {
+		t.Errorf("expected the task back in the inbox, got %s", list())
+	}
+	if err := s.DeleteList(work); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected %v, got %v", ErrListNotFound, err)
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// minPositionGap is the smallest gap left between two neighbouring positions
+// before a move renumbers every task.
+const minPositionGap = 1e-9
+
+// NextPosition returns a position after every task in tasks.
+func NextPosition(tasks []Task) float64 {
+	last := 0.0
+	for _, t := range tasks {
+		last = max(last, t.Position)
+	}
+	return last + 1
+}
+
+// sortByPosition orders tasks by position, renumbering them if some share a
+// position, as tasks saved before positions existed all do.
+func sortByPosition(tasks []Task) {
+	slices.SortStableFunc(tasks, func(a, b Task) int {
+		switch {
+		case a.Position < b.Position:
+			return -1
+		case a.Position > b.Position:
+			return 1
+		}
+		return 0
+	})
+	for i := 1; i < len(tasks); i++ {
+		if tasks[i].Position <= tasks[i-1].Position {
+			renumber(tasks)
+			return
+		}
+	}
+}
+
+func renumber(tasks []Task) {
+	for i := range tasks {
+		tasks[i].Position = float64(i + 1)
+	}
+}
+
+// Reorder returns a copy of tasks in position order with the task id moved
+// just before the task before, or to the end when before is uuid.Nil. Only
+// the moved task gets a new position, unless there's no room left between
+// its neighbours.
+func Reorder(tasks []Task, id, before uuid.UUID) ([]Task, error) {
+	tasks = slices.Clone(tasks)
+	sortByPosition(tasks)
+	index := func(id uuid.UUID) int {
+		return slices.IndexFunc(tasks, func(t Task) bool { return t.ID == id })
+	}
+
+	i := index(id)
+	if i < 0 || (before != uuid.Nil && index(before) < 0) {
+		return nil, ErrTaskNotFound
+	}
+	if before == id {
+		return tasks, nil
+	}
+	task := tasks[i]
+	tasks = slices.Delete(tasks, i, i+1)
+	j := len(tasks)
+	if before != uuid.Nil {
+		j = index(before)
+	}
+	tasks = slices.Insert(tasks, j, task)
+
+	switch {
+	case len(tasks) == 1:
+	case j == 0:
+		tasks[j].Position = tasks[1].Position - 1
+	case j == len(tasks)-1:
+		tasks[j].Position = tasks[j-1].Position + 1
+	default:
+		lo, hi := tasks[j-1].Position, tasks[j+1].Position
+		if hi-lo < minPositionGap {
+			renumber(tasks)
+		} else {
+			tasks[j].Position = lo + (hi-lo)/2
+		}
+	}
+	return tasks, nil
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Query selects, orders and pages tasks. Zero fields don't filter.
+type Query struct {
+	Search   string
+	Priority Priority
+	// Status is "open", "done" or empty for both. Open tasks are further
+	// "ready" once all their blockers are done, "blocked" until then.
+	// Archived tasks only match a search or the "archived" status.
+	Status string
+	// Tags selects the tasks having all of them.
+	Tags []string
+	// List selects the tasks of one list: a ListKey, or Inbox for the tasks
+	// in no list.
+	List string
+	// Sort is "title", "priority", "due" or "status", optionally prefixed
+	// with "-" for descending order. Tasks are otherwise kept in store order.
+	Sort     string
+	Page     int
+	PageSize int
+}
+
+var sortKeys = map[string]func(a, b Task) int{
+	"title": func(a, b Task) int {
+		return cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	},
+	"priority": func(a, b Task) int {
+		return cmp.Compare(a.Priority.rank(), b.Priority.rank())
+	},
+	"due": func(a, b Task) int {
+		// Tasks without a due date come last.
+		if a.Due.IsZero() || b.Due.IsZero() {
+			return cmp.Compare(boolRank(a.Due.IsZero()), boolRank(b.Due.IsZero()))
+		}
+		return a.Due.Compare(b.Due)
+	},
+	"status": func(a, b Task) int {
+		return cmp.Compare(boolRank(a.Done), boolRank(b.Done))
+	},
+}
+
+func (p Priority) rank() int {
+	return slices.Index([]Priority{Low, Medium, High}, p)
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// ValidSort reports whether sort names a sort key, with or without "-".
+func ValidSort(sort string) bool {
+	_, ok := sortKeys[strings.TrimPrefix(sort, "-")]
+	return sort == "" || ok
+}
+
+func (q Query) Match(t Task) bool {
+	if q.Search != "" && !containsFold(t.Title, q.Search) && !containsFold(t.Description, q.Search) {
+		return false
+	}
+	if q.Priority != "" && t.Priority != q.Priority {
+		return false
+	}
+	if q.List != "" && ListKey(t.List) != q.List {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !t.HasTag(tag) {
+			return false
+		}
+	}
+	if !t.Archived.IsZero() && q.Search == "" && q.Status != "archived" {
+		return false
+	}
+	switch q.Status {
+	case "archived":
+		return !t.Archived.IsZero()
+	case "open":
+		return !t.Done
+	case "done":
+		return t.Done
+	case "ready", "blocked":
+		return !t.Done
+	}
+	return true
+}
+
+// Run returns the tasks on the query's page and the number of matching
+// tasks across all pages. Pages are numbered from 1.
+func (q Query) Run(tasks []Task) ([]Task, int) {
+	var matched []Task
+	for _, t := range tasks {
+		if !q.Match(t) {
+			continue
+		}
+		if (q.Status == "ready" || q.Status == "blocked") && Blocked(tasks, t) != (q.Status == "blocked") {
+			continue
+		}
+		matched = append(matched, t)
+	}
+
+	key, desc := strings.CutPrefix(q.Sort, "-")
+	if compare, ok := sortKeys[key]; ok {
+		slices.SortStableFunc(matched, func(a, b Task) int {
+			if desc {
+				return compare(b, a)
+			}
+			return compare(a, b)
+		})
+	}
+
+	total := len(matched)
+	if q.PageSize > 0 {
+		start := min(max(q.Page-1, 0)*q.PageSize, total)
+		matched = matched[start:min(start+q.PageSize, total)]
+	}
+	return matched, total
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestQuery(t *testing.T) {
+	work := uuid.New()
+	tasks := []Task{
+		{ID: uuid.New(), Title: "Buy milk", Priority: Low, Tags: []string{"home", "shopping"}},
+		{ID: uuid.New(), Title: "buy bread", Priority: High, Done: true, Tags: []string{"shopping"}},
+		{ID: uuid.New(), Title: "Call Alex", Priority: Medium, Tags: []string{"home"}},
+		{ID: uuid.New(), Title: "Answer mail", Priority: High, List: work},
+		{ID: uuid.New(), Title: "Pay rent", Priority: High, Done: true, Archived: time.Now()},
+	}
+	titles := func(tasks []Task) []string {
+		var titles []string
+		for _, t := range tasks {
+			titles = append(titles, t.Title)
+		}
+		return titles
+	}
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+		total    int
+	}{
+		{"everything", Query{}, []string{"Buy milk", "buy bread", "Call Alex", "Answer mail"}, 4},
+		{"search ignores case", Query{Search: "BUY"}, []string{"Buy milk", "buy bread"}, 2},
+		{"priority", Query{Priority: High}, []string{"buy bread", "Answer mail"}, 2},
+		{"status", Query{Status: "open", Priority: High}, []string{"Answer mail"}, 1},
+		{"tags", Query{Tags: []string{"home", "shopping"}}, []string{"Buy milk"}, 1},
+		{"list", Query{List: work.String()}, []string{"Answer mail"}, 1},
+		{"inbox", Query{List: Inbox, Priority: High}, []string{"buy bread"}, 1},
+		{"archived found by search", Query{Search: "rent"}, []string{"Pay rent"}, 1},
+		{"archived", Query{Status: "archived"}, []string{"Pay rent"}, 1},
+		{"sort by title", Query{Sort: "title"}, []string{"Answer mail", "buy bread", "Buy milk", "Call Alex"}, 4},
+		{"sort by priority descending", Query{Sort: "-priority"}, []string{"buy bread", "Answer mail", "Call Alex", "Buy milk"}, 4},
+		{"page", Query{Sort: "title", Page: 2, PageSize: 3}, []string{"Call Alex"}, 4},
+		{"page past the end", Query{Page: 3, PageSize: 3}, nil, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := tt.query.Run(tasks)
+			if total != tt.total {
+				t.Errorf("expected %d matching tasks, got %d", tt.total, total)
+			}
+			if titles := titles(got); !slices.Equal(titles, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, titles)
+			}
+		})
+	}
+}
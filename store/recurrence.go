@@ -0,0 +1,375 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+// Recurrence is the subset of iCalendar RRULEs tasks can repeat by: FREQ,
+// INTERVAL, BYDAY for weekly rules, BYMONTHDAY for monthly ones and UNTIL.
+type Recurrence struct {
+	// Freq is "DAILY", "WEEKLY", "MONTHLY" or "YEARLY".
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	// MonthDay is the day of the month, -1 for the last one, or 0 for the
+	// day of the previous occurrence.
+	MonthDay int
+	Until    time.Time
+}
+
+var (
+	units    = map[string]string{"DAILY": "day", "WEEKLY": "week", "MONTHLY": "month", "YEARLY": "year"}
+	weekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+	dayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+)
+
+// frequency returns the FREQ of an adverb such as "weekly" or a unit such
+// as "week" or "weeks", "" for other words.
+func frequency(word string) string {
+	for freq, unit := range units {
+		if word == strings.ToLower(freq) || word == unit || word == unit+"s" {
+			return freq
+		}
+	}
+	return ""
+}
+
+// ParseRecurrence reads an RRULE such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+// with or without its "RRULE:" prefix, or the text Describe writes, such as
+// "every 2 weeks on mon,thu until 2026-12-31", "monthly on the last day" or
+// "weekdays".
+func ParseRecurrence(s string) (Recurrence, error) {
+	s = strings.TrimSpace(s)
+	var r Recurrence
+	var err error
+	if upper := strings.ToUpper(s); strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "FREQ=") {
+		r, err = parseRule(strings.TrimPrefix(upper, "RRULE:"))
+	} else {
+		r, err = parseText(strings.ToLower(s))
+	}
+	if err != nil {
+		return Recurrence{}, err
+	}
+	r.Interval = max(r.Interval, 1)
+	slices.Sort(r.ByDay)
+	r.ByDay = slices.Compact(r.ByDay)
+	if r.Interval > 999 || len(r.ByDay) > 0 && r.Freq != "WEEKLY" || r.MonthDay != 0 && r.Freq != "MONTHLY" {
+		return Recurrence{}, ErrInvalidRecurrence
+	}
+	return r, nil
+}
+
+func parseRule(rule string) (Recurrence, error) {
+	var r Recurrence
+	for _, part := range strings.Split(rule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		var err error
+		switch key {
+		case "FREQ":
+			if !slices.Contains([]string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}, value) {
+				return r, ErrInvalidRecurrence
+			}
+			r.Freq = value
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(value); err != nil || r.Interval < 1 {
+				return r, ErrInvalidRecurrence
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				i := slices.Index(weekdays, day)
+				if i < 0 {
+					return r, ErrInvalidRecurrence
+				}
+				r.ByDay = append(r.ByDay, time.Weekday(i))
+			}
+		case "BYMONTHDAY":
+			if r.MonthDay, err = parseMonthDay(value); err != nil {
+				return r, err
+			}
+		case "UNTIL":
+			// Only the date of UNTIL matters, the time of day is dropped.
+			if len(value) < 8 {
+				return r, ErrInvalidRecurrence
+			}
+			if r.Until, err = time.Parse("20060102", value[:8]); err != nil {
+				return r, ErrInvalidRecurrence
+			}
+		default:
+			return r, ErrInvalidRecurrence
+		}
+	}
+	if r.Freq == "" {
+		return r, ErrInvalidRecurrence
+	}
+	return r, nil
+}
+
+func parseMonthDay(s string) (int, error) {
+	day, err := strconv.Atoi(s)
+	if err != nil || day == 0 || day < -1 || day > 31 {
+		return 0, ErrInvalidRecurrence
+	}
+	return day, nil
+}
+
+func parseText(text string) (Recurrence, error) {
+	var r Recurrence
+	text, until, hasUntil := strings.Cut(text, " until ")
+	if hasUntil {
+		var err error
+		if r.Until, err = time.Parse(time.DateOnly, strings.TrimSpace(until)); err != nil {
+			return r, ErrInvalidRecurrence
+		}
+	}
+	text, on, hasOn := strings.Cut(text, " on ")
+	words := strings.Fields(text)
+	switch {
+	case len(words) == 1 && words[0] == "weekdays" && !hasOn:
+		r.Freq = "WEEKLY"
+		r.ByDay = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	case len(words) == 1 && strings.HasSuffix(words[0], "ly"):
+		r.Freq = frequency(words[0])
+	case len(words) == 2 && words[0] == "every":
+		r.Freq = frequency(words[1])
+	case len(words) == 3 && words[0] == "every":
+		n, err := strconv.Atoi(words[1])
+		if err != nil || n < 1 {
+			return r, ErrInvalidRecurrence
+		}
+		r.Interval = n
+		r.Freq = frequency(words[2])
+	}
+	if r.Freq == "" {
+		return r, ErrInvalidRecurrence
+	}
+	if !hasOn {
+		return r, nil
+	}
+
+	on = strings.TrimSpace(on)
+	switch {
+	case on == "the last day":
+		r.MonthDay = -1
+	case strings.HasPrefix(on, "day "):
+		var err error
+		if r.MonthDay, err = parseMonthDay(strings.TrimPrefix(on, "day ")); err != nil || r.MonthDay < 0 {
+			return r, ErrInvalidRecurrence
+		}
+	default:
+		for _, day := range strings.Split(on, ",") {
+			day = strings.TrimSpace(day)
+			i := slices.IndexFunc(dayNames, func(name string) bool { return len(day) >= 2 && strings.HasPrefix(name, day) })
+			if i < 0 {
+				return r, ErrInvalidRecurrence
+			}
+			r.ByDay = append(r.ByDay, time.Weekday(i))
+		}
+	}
+	return r, nil
+}
+
+// NormalizeRecurrence returns rule as stored: the RRULE of a rule
+// ParseRecurrence reads, "" for none. It reports false for other rules.
+func NormalizeRecurrence(rule string) (string, bool) {
+	if strings.TrimSpace(rule) == "" {
+		return "", true
+	}
+	r, err := ParseRecurrence(rule)
+	if err != nil {
+		return "", false
+	}
+	return r.String(), true
+}
+
+// String returns r as an RRULE, without the "RRULE:" prefix.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, d := range r.ByDay {
+			days = append(days, weekdays[d])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.MonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.MonthDay))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Describe returns r in words, as ParseRecurrence reads them back.
+func (r Recurrence) Describe() string {
+	text := strings.ToLower(r.Freq)
+	if r.Interval > 1 {
+		text = fmt.Sprintf("every %d %ss", r.Interval, units[r.Freq])
+	}
+	switch {
+	case len(r.ByDay) > 0:
+		var days []string
+		for _, d := range r.ByDay {
+			days = append(days, dayNames[d][:3])
+		}
+		text += " on " + strings.Join(days, ",")
+	case r.MonthDay == -1:
+		text += " on the last day"
+	case r.MonthDay > 0:
+		text += fmt.Sprintf(" on day %d", r.MonthDay)
+	}
+	if !r.Until.IsZero() {
+		text += " until " + r.Until.Format(time.DateOnly)
+	}
+	return text
+}
+
+// Next returns the first occurrence of r after the date after, and false
+// once the rule has ended.
+func (r Recurrence) Next(after time.Time) (time.Time, bool) {
+	interval := max(r.Interval, 1)
+	var next time.Time
+	switch r.Freq {
+	case "DAILY":
+		next = after.AddDate(0, 0, interval)
+	case "WEEKLY":
+		next = after.AddDate(0, 0, 7*interval)
+		if len(r.ByDay) > 0 {
+			next = r.nextWeekday(after, interval)
+		}
+	case "MONTHLY":
+		day := cmp.Or(r.MonthDay, after.Day())
+		next = monthDay(after.Year(), after.Month()+time.Month(interval), day)
+	case "YEARLY":
+		next = monthDay(after.Year()+interval, after.Month(), after.Day())
+	default:
+		return time.Time{}, false
+	}
+	if !r.Until.IsZero() && next.After(r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// nextWeekday returns the next of r.ByDay later in the week of after, weeks
+// starting on Monday, or the first of them interval weeks later.
+func (r Recurrence) nextWeekday(after time.Time, interval int) time.Time {
+	monday := after.AddDate(0, 0, -((int(after.Weekday()) + 6) % 7))
+	for week := 0; ; week += interval {
+		for _, day := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+			date := monday.AddDate(0, 0, 7*week+(int(day)+6)%7)
+			if slices.Contains(r.ByDay, day) && date.After(after) {
+				return date
+			}
+		}
+	}
+}
+
+// monthDay returns the day of a month, the last one for -1 or for days the
+// month is too short for.
+func monthDay(year int, month time.Month, day int) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	if day < 0 || day > last.Day() {
+		return last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// today is the date recurring tasks are rolled forward past.
+var today = func() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// NextOccurrenceID returns the ID of the occurrence following the task id.
+// It is derived from id so that completing a task twice doesn't repeat it
+// twice.
+func NextOccurrenceID(id uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(id, []byte("next occurrence"))
+}
+
+// NextOccurrence returns the task following the recurring task t once it is
+// done: a copy due at the first occurrence of its rule after both its due
+// date and today. It reports false for tasks that don't repeat or whose rule
+// has ended.
+func NextOccurrence(t Task) (Task, bool) {
+	r, err := ParseRecurrence(t.Repeat)
+	if t.Repeat == "" || err != nil {
+		return Task{}, false
+	}
+	now := today()
+	base := cmp.Or(t.Due, now)
+	if r.Freq == "MONTHLY" && r.MonthDay == 0 {
+		// Keep to the day of the month while rolling past shorter months.
+		r.MonthDay = base.Day()
+	}
+	due, ok := r.Next(base)
+	for ok && !due.After(now) {
+		due, ok = r.Next(due)
+	}
+	if !ok {
+		return Task{}, false
+	}
+	return Task{
+		ID:          NextOccurrenceID(t.ID),
+		Title:       t.Title,
+		Priority:    t.Priority,
+		Description: t.Description,
+		Due:         due,
+		Tags:        slices.Clone(t.Tags),
+		List:        t.List,
+		Parent:      t.Parent,
+		Repeat:      t.Repeat,
+		Series:      cmp.Or(t.Series, t.ID),
+	}, true
+}
+
+// Recur returns tasks, in position order, with the next occurrence of the
+// task id added just after it once it is done, unless it is already there.
+func Recur(tasks []Task, id uuid.UUID) []Task {
+	i := indexOf(tasks, id)
+	if i < 0 || !tasks[i].Done {
+		return tasks
+	}
+	next, ok := NextOccurrence(tasks[i])
+	if !ok || indexOf(tasks, next.ID) >= 0 {
+		return tasks
+	}
+	next.Position = tasks[i].Position + 1
+	if i+1 < len(tasks) {
+		gap := tasks[i+1].Position - tasks[i].Position
+		if gap < 2*minPositionGap {
+			next.Position = NextPosition(tasks)
+			return append(tasks, next)
+		}
+		next.Position = tasks[i].Position + gap/2
+	}
+	return slices.Insert(slices.Clone(tasks), i+1, next)
+}
+
+// History returns the completed occurrences of the series t belongs to,
+// most recently due first.
+func History(tasks []Task, t Task) []Task {
+	series := cmp.Or(t.Series, t.ID)
+	var done []Task
+	for _, other := range tasks {
+		if other.Done && other.ID != t.ID && cmp.Or(other.Series, other.ID) == series {
+			done = append(done, other)
+		}
+	}
+	slices.SortStableFunc(done, func(a, b Task) int { return b.Due.Compare(a.Due) })
+	return done
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		rule, expected, describe string
+	}{
+		{"daily", "FREQ=DAILY", "daily"},
+		{"Every 2 weeks", "FREQ=WEEKLY;INTERVAL=2", "every 2 weeks"},
+		{"every 3 days", "FREQ=DAILY;INTERVAL=3", "every 3 days"},
+		{"every month", "FREQ=MONTHLY", "monthly"},
+		{"weekdays", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "weekly on mon,tue,wed,thu,fri"},
+		{"weekly on thursday, mon", "FREQ=WEEKLY;BYDAY=MO,TH", "weekly on mon,thu"},
+		{"monthly on the last day until 2026-12-31", "FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20261231", "monthly on the last day until 2026-12-31"},
+		{"RRULE:FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=15", "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=15", "every 2 months on day 15"},
+		{"freq=yearly;until=20300101T000000Z", "FREQ=YEARLY;UNTIL=20300101", "yearly until 2030-01-01"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Errorf("%q: expected no error, got %v", tt.rule, err)
+			continue
+		}
+		if r.String() != tt.expected || r.Describe() != tt.describe {
+			t.Errorf("%q: expected %q, %q, got %q, %q", tt.rule, tt.expected, tt.describe, r.String(), r.Describe())
+		}
+		if back, _ := ParseRecurrence(r.Describe()); back.String() != r.String() {
+			t.Errorf("%q: described as %q, read back as %q", tt.rule, r.Describe(), back)
+		}
+	}
+
+	for _, rule := range []string{"sometimes", "every 0 days", "FREQ=HOURLY", "FREQ=DAILY;COUNT=3", "daily on mon", "weekly on day 3", "FREQ=MONTHLY;BYMONTHDAY=32"} {
+		if _, err := ParseRecurrence(rule); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("%q: expected %v, got %v", rule, ErrInvalidRecurrence, err)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+	tests := []struct {
+		rule, after, expected string
+	}{
+		{"every 3 days", "2026-02-27", "2026-03-02"},
+		{"weekly", "2026-10-19", "2026-10-26"},
+		{"weekly on mon,thu", "2026-10-19", "2026-10-22"},
+		{"weekly on mon,thu", "2026-10-22", "2026-10-26"},
+		{"every 2 weeks on mon", "2026-10-19", "2026-11-02"},
+		{"monthly", "2026-01-31", "2026-02-28"},
+		{"monthly on the last day", "2026-02-28", "2026-03-31"},
+		{"yearly", "2028-02-29", "2029-02-28"},
+	}
+	for _, tt := range tests {
+		r, _ := ParseRecurrence(tt.rule)
+		if next, ok := r.Next(date(tt.after)); !ok || !next.Equal(date(tt.expected)) {
+			t.Errorf("%s after %s: expected %s, got %s", tt.rule, tt.after, tt.expected, next.Format(time.DateOnly))
+		}
+	}
+
+	r, _ := ParseRecurrence("daily until 2026-10-20")
+	if _, ok := r.Next(date("2026-10-20")); ok {
+		t.Error("expected no occurrence after the end of the rule")
+	}
+}
+
+// testRecurrence checks completing recurring tasks against an empty store.
+func testRecurrence(t *testing.T, s Store) {
+	t.Helper()
+	id, other := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{id, other} {
+		if err := s.AddItem(id, "Rotate on-call", High); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	due := today().AddDate(0, 0, 2)
+	if err := s.UpdateTask(Task{ID: id, Title: "Rotate on-call", Priority: High, Due: due}); err != nil {
+		t.Fatalf("Error updating task: %s", err)
+	}
+	if err := s.AddTag(id, "ops"); err != nil {
+		t.Fatalf("Error tagging task: %s", err)
+	}
+	if err := s.SetRepeat(id, "sometimes"); !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("expected %v, got %v", ErrInvalidRecurrence, err)
+	}
+	if err := s.SetRepeat(id, "weekly"); err != nil {
+		t.Fatalf("Error setting recurrence: %s", err)
+	}
+
+	for range 2 {
+		// Completing the task again after reopening it doesn't repeat it
+		// twice.
+		for range 2 {
+			if err := s.ToggleDone(id); err != nil {
+				t.Fatalf("Error toggling task: %s", err)
+			}
+		}
+	}
+	if err := s.ToggleDone(id); err != nil {
+		t.Fatalf("Error toggling task: %s", err)
+	}
+	tasks, _ := s.GetAllItems()
+	if len(tasks) != 3 {
+		t.Fatalf("expected the next occurrence to be added once, got %+v", tasks)
+	}
+	next := tasks[1]
+	if next.Done || next.Title != "Rotate on-call" || next.Repeat != "FREQ=WEEKLY" || next.Series != id || len(next.Tags) != 1 {
+		t.Errorf("expected an open copy of the task in its series, got %+v", next)
+	}
+	if expected := due.AddDate(0, 0, 7); !next.Due.Equal(expected) {
+		t.Errorf("expected the next occurrence due %s, got %s", expected, next.Due)
+	}
+	if history := History(tasks, next); len(history) != 1 || history[0].ID != id {
+		t.Errorf("expected the completed occurrence in the history, got %v", history)
+	}
+}
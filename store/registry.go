@@ -0,0 +1,32 @@
+package store
+
+import "sync"
+
+// Registry hands out one Store per user, opening it on first use.
+type Registry struct {
+	mu     sync.Mutex
+	open   func(user string) (Store, error)
+	stores map[string]Store
+}
+
+func NewRegistry(open func(user string) (Store, error)) *Registry {
+	return &Registry{
+		open:   open,
+		stores: map[string]Store{},
+	}
+}
+
+func (r *Registry) ForUser(user string) (Store, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.stores[user]; ok {
+		return s, nil
+	}
+	s, err := r.open(user)
+	if err != nil {
+		return nil, err
+	}
+	r.stores[user] = s
+	return s, nil
+}
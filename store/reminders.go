@@ -0,0 +1,127 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReminderLog records the reminders already sent, so that each fires once
+// even across restarts.
+type ReminderLog interface {
+	// Claim records key as sent, reporting false if it already was.
+	Claim(key string) (bool, error)
+	// Release forgets key, so that a reminder that couldn't be delivered
+	// is tried again.
+	Release(key string) error
+	// Prune forgets the keys claimed before before, once their reminders
+	// are too late to be sent again.
+	Prune(before time.Time) error
+}
+
+type InMemoryReminderLog struct {
+	mu       sync.Mutex
+	sent     map[string]time.Time
+	filePath string
+}
+
+func NewInMemoryReminderLog(config Config) (*InMemoryReminderLog, error) {
+	l := &InMemoryReminderLog{sent: map[string]time.Time{}}
+	if config.LoadFromFile {
+		l.filePath = "reminders.json"
+		b, err := os.ReadFile(l.filePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, &l.sent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l, nil
+}
+
+func (l *InMemoryReminderLog) Claim(key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.sent[key]; ok {
+		return false, nil
+	}
+	l.sent[key] = time.Now()
+	if err := l.save(); err != nil {
+		delete(l.sent, key)
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *InMemoryReminderLog) Release(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.sent, key)
+	return l.save()
+}
+
+func (l *InMemoryReminderLog) Prune(before time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := len(l.sent)
+	maps.DeleteFunc(l.sent, func(key string, sent time.Time) bool { return sent.Before(before) })
+	if len(l.sent) == n {
+		return nil
+	}
+	return l.save()
+}
+
+// save writes the log to the file; callers hold l.mu.
+func (l *InMemoryReminderLog) save() error {
+	if l.filePath == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(l.sent, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.filePath, b, 0o600)
+}
+
+type PostgresReminderLog struct {
+	Db *sql.DB
+}
+
+func NewPostgresReminderLog(db *sql.DB) (*PostgresReminderLog, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS reminders_sent (
+		key TEXT PRIMARY KEY,
+		sent TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresReminderLog{Db: db}, nil
+}
+
+func (l *PostgresReminderLog) Claim(key string) (bool, error) {
+	res, err := l.Db.Exec("INSERT INTO reminders_sent (key) VALUES ($1) ON CONFLICT (key) DO NOTHING", key)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (l *PostgresReminderLog) Release(key string) error {
+	_, err := l.Db.Exec("DELETE FROM reminders_sent WHERE key = $1", key)
+	return err
+}
+
+func (l *PostgresReminderLog) Prune(before time.Time) error {
+	_, err := l.Db.Exec("DELETE FROM reminders_sent WHERE sent < $1", before)
+	return err
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInMemoryReminderLog(t *testing.T) {
+	t.Chdir(t.TempDir())
+	c := Config{LoadFromFile: true}
+
+	l, err := NewInMemoryReminderLog(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if ok, err := l.Claim("alice/task/24h"); !ok || err != nil {
+		t.Fatalf("expected the first claim to succeed, got %v, %v", ok, err)
+	}
+	if ok, _ := l.Claim("alice/task/24h"); ok {
+		t.Error("expected a second claim to fail")
+	}
+	_, _ = l.Claim("alice/task/1h")
+	if err := l.Release("alice/task/1h"); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	// A restarted server remembers what was sent.
+	l, err = NewInMemoryReminderLog(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if ok, _ := l.Claim("alice/task/24h"); ok {
+		t.Error("expected the claim to be remembered")
+	}
+	if ok, _ := l.Claim("alice/task/1h"); !ok {
+		t.Error("expected the released claim to be forgotten")
+	}
+}
+
+func TestInMemoryReminderLogPrune(t *testing.T) {
+	t.Chdir(t.TempDir())
+	c := Config{LoadFromFile: true}
+
+	l, err := NewInMemoryReminderLog(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	_, _ = l.Claim("alice/task/24h")
+	if err := l.Prune(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if ok, _ := l.Claim("alice/task/24h"); ok {
+		t.Error("expected a recent claim kept")
+	}
+	if err := l.Prune(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	// The pruned keys are gone from the file too.
+	l, err = NewInMemoryReminderLog(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if len(l.sent) != 0 {
+		t.Errorf("expected the log pruned, got %v", l.sent)
+	}
+}
@@ -1,15 +1,64 @@
 package store
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 )
 
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskExists   = errors.New("a task with this ID already exists")
+)
+
 type Store interface {
 	GetAllItems() ([]Task, error)
 	AddItem(id uuid.UUID, title string, priority Priority) error
 	DeleteItem(id uuid.UUID) error
 	ToggleDone(id uuid.UUID) error
 	EditTask(id uuid.UUID, title string) error
+	SetPriority(id uuid.UUID, priority Priority) error
+	UpdateTask(task Task) error
+	MoveTask(id, before uuid.UUID) error
+	AddTag(id uuid.UUID, tag string) error
+	RemoveTag(id uuid.UUID, tag string) error
+	GetLists() ([]List, error)
+	CreateList(id uuid.UUID, name string) error
+	RenameList(id uuid.UUID, name string) error
+	ArchiveList(id uuid.UUID, archived bool) error
+	DeleteList(id uuid.UUID) error
+	SetList(taskID, listID uuid.UUID) error
+	SetParent(id, parent uuid.UUID) error
+	SetAutoComplete(id uuid.UUID, auto bool) error
+	DeleteTree(id uuid.UUID) error
+	AddBlocker(id, blocker uuid.UUID) error
+	RemoveBlocker(id, blocker uuid.UUID) error
+	SetRepeat(id uuid.UUID, rule string) error
+	// DeleteItem and DeleteTree move tasks to the trash, from where they
+	// can be restored until they are purged.
+	GetTrash() ([]Task, error)
+	RestoreTask(id uuid.UUID) error
+	PurgeTask(id uuid.UUID) error
+	EmptyTrash(before time.Time) error
+	// ArchiveTask archives a done task or unarchives it, and ArchiveDone
+	// archives the tasks completed before before.
+	ArchiveTask(id uuid.UUID, archived bool) error
+	ArchiveDone(before time.Time) error
+	// StartTimer starts timing the task in a new entry id, one at a time,
+	// and StopTimer ends it. Entries can also be added and edited by hand.
+	GetTimeEntries() ([]TimeEntry, error)
+	StartTimer(id, task uuid.UUID) error
+	StopTimer() error
+	AddTimeEntry(e TimeEntry) error
+	EditTimeEntry(e TimeEntry) error
+	DeleteTimeEntry(id uuid.UUID) error
+	// Undo reverts the last change that isn't undone yet, and Redo makes the
+	// last one undone again.
+	Undo() error
+	Redo() error
+	Batch(ops []TaskOperation) error
 }
 
 type Priority string
@@ -20,21 +69,163 @@ const (
 	High   Priority = "High"
 )
 
+func (p Priority) Valid() bool {
+	return p == Low || p == Medium || p == High
+}
+
 type Config struct {
 	LoadFromFile bool
+	FilePath     string
 	DBName       string
+	User         string
 }
 type Task struct {
-	ID       uuid.UUID `json:"ID"`
-	Title    string    `json:"Title"`
-	Priority Priority  `json:"Priority"`
-	Done     bool      `json:"Done"`
+	ID          uuid.UUID `json:"ID"`
+	Title       string    `json:"Title"`
+	Priority    Priority  `json:"Priority"`
+	Done        bool      `json:"Done"`
+	Description string    `json:"Description,omitempty"`
+	Due         time.Time `json:"Due,omitzero"`
+	Position    float64   `json:"Position"`
+	Tags        []string  `json:"Tags,omitempty"`
+	List        uuid.UUID `json:"List,omitzero"`
+	// Parent is the task this one is a subtask of. AutoComplete tasks are
+	// done once all their subtasks are.
+	Parent       uuid.UUID `json:"Parent,omitzero"`
+	AutoComplete bool      `json:"AutoComplete,omitempty"`
+	// BlockedBy lists the tasks that must be done before this one can be.
+	BlockedBy []uuid.UUID `json:"BlockedBy,omitempty"`
+	// Repeat is the RRULE of a recurring task. Its occurrences share the
+	// ID of the first one as their Series.
+	Repeat string    `json:"Repeat,omitempty"`
+	Series uuid.UUID `json:"Series,omitzero"`
+	// Deleted is when a task in the trash was deleted.
+	Deleted time.Time `json:"Deleted,omitzero"`
+	// Completed is when a done task was completed. Archived done tasks are
+	// left out of queries unless searched for.
+	Completed time.Time `json:"Completed,omitzero"`
+	Archived  time.Time `json:"Archived,omitzero"`
 }
 
+// TaskOperation is a change to the tasks or lists of a store. List operations
+// carry the list in ID and its name in Title.
 type TaskOperation struct {
-	Type     string
-	ID       uuid.UUID
-	Title    string
-	Priority Priority
-	Result   chan error
+	Type         string
+	ID           uuid.UUID
+	Title        string
+	Priority     Priority
+	Description  string    `json:",omitempty"`
+	Due          time.Time `json:",omitzero"`
+	Before       uuid.UUID `json:",omitzero"`
+	Tag          string    `json:",omitempty"`
+	List         uuid.UUID `json:",omitzero"`
+	Archived     bool      `json:",omitempty"`
+	Parent       uuid.UUID `json:",omitzero"`
+	AutoComplete bool      `json:",omitempty"`
+	Blocker      uuid.UUID `json:",omitzero"`
+	Repeat       string    `json:",omitempty"`
+	// DeletedBefore limits EmptyTrash to the tasks deleted before it.
+	DeletedBefore time.Time `json:",omitzero"`
+	// CompletedBefore has ArchiveDone archive the tasks completed before it.
+	CompletedBefore time.Time `json:",omitzero"`
+	// Time entry operations carry the entry in ID, its task in Task and
+	// when it started and ended in Start and End.
+	Task   uuid.UUID       `json:",omitzero"`
+	Start  time.Time       `json:",omitzero"`
+	End    time.Time       `json:",omitzero"`
+	Batch  []TaskOperation `json:",omitempty"`
+	Result chan error      `json:"-"`
+	// Recorded receives whether a successful operation was recorded for
+	// Undo, before Result.
+	Recorded chan bool `json:"-"`
+	// Unrecorded leaves the operation out of the undo history, for changes
+	// the user didn't make.
+	Unrecorded bool `json:"-"`
+}
+
+// UpdateOperation returns the operation setting the editable fields of a
+// task to those of t.
+func UpdateOperation(t Task) TaskOperation {
+	return TaskOperation{
+		Type:        "Update",
+		ID:          t.ID,
+		Title:       t.Title,
+		Priority:    t.Priority,
+		Description: t.Description,
+		Due:         t.Due,
+	}
+}
+
+// Apply performs op against s through the matching Store method.
+func Apply(s Store, op TaskOperation) error {
+	switch op.Type {
+	case "Add":
+		return s.AddItem(op.ID, op.Title, op.Priority)
+	case "Delete":
+		return s.DeleteItem(op.ID)
+	case "Edit":
+		return s.EditTask(op.ID, op.Title)
+	case "ToggleDone":
+		return s.ToggleDone(op.ID)
+	case "SetPriority":
+		return s.SetPriority(op.ID, op.Priority)
+	case "Update":
+		return s.UpdateTask(Task{ID: op.ID, Title: op.Title, Priority: op.Priority, Description: op.Description, Due: op.Due})
+	case "Move":
+		return s.MoveTask(op.ID, op.Before)
+	case "AddTag":
+		return s.AddTag(op.ID, op.Tag)
+	case "RemoveTag":
+		return s.RemoveTag(op.ID, op.Tag)
+	case "CreateList":
+		return s.CreateList(op.ID, op.Title)
+	case "RenameList":
+		return s.RenameList(op.ID, op.Title)
+	case "ArchiveList":
+		return s.ArchiveList(op.ID, op.Archived)
+	case "DeleteList":
+		return s.DeleteList(op.ID)
+	case "SetList":
+		return s.SetList(op.ID, op.List)
+	case "SetParent":
+		return s.SetParent(op.ID, op.Parent)
+	case "SetAutoComplete":
+		return s.SetAutoComplete(op.ID, op.AutoComplete)
+	case "DeleteTree":
+		return s.DeleteTree(op.ID)
+	case "AddBlocker":
+		return s.AddBlocker(op.ID, op.Blocker)
+	case "RemoveBlocker":
+		return s.RemoveBlocker(op.ID, op.Blocker)
+	case "SetRepeat":
+		return s.SetRepeat(op.ID, op.Repeat)
+	case "Restore":
+		return s.RestoreTask(op.ID)
+	case "Purge":
+		return s.PurgeTask(op.ID)
+	case "EmptyTrash":
+		return s.EmptyTrash(op.DeletedBefore)
+	case "Archive":
+		return s.ArchiveTask(op.ID, op.Archived)
+	case "ArchiveDone":
+		return s.ArchiveDone(op.CompletedBefore)
+	case "StartTimer":
+		return s.StartTimer(op.ID, op.Task)
+	case "StopTimer":
+		return s.StopTimer()
+	case "AddTimeEntry":
+		return s.AddTimeEntry(TimeEntry{ID: op.ID, Task: op.Task, Start: op.Start, End: op.End})
+	case "EditTimeEntry":
+		return s.EditTimeEntry(TimeEntry{ID: op.ID, Task: op.Task, Start: op.Start, End: op.End})
+	case "DeleteTimeEntry":
+		return s.DeleteTimeEntry(op.ID)
+	case "Undo":
+		return s.Undo()
+	case "Redo":
+		return s.Redo()
+	case "Batch":
+		return s.Batch(op.Batch)
+	default:
+		return fmt.Errorf("unknown operation %q", op.Type)
+	}
 }
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidParent = errors.New("a task can't be a subtask of itself or of its own subtasks")
+
+func indexOf(tasks []Task, id uuid.UUID) int {
+	return slices.IndexFunc(tasks, func(t Task) bool { return t.ID == id })
+}
+
+// Progress counts the subtasks of the task id and how many of them are done.
+func Progress(tasks []Task, id uuid.UUID) (done, total int) {
+	for _, t := range tasks {
+		if t.Parent == id && id != uuid.Nil {
+			total++
+			if t.Done {
+				done++
+			}
+		}
+	}
+	return done, total
+}
+
+// Descendants returns the IDs of the subtasks of the task id, of their
+// subtasks and so on.
+func Descendants(tasks []Task, id uuid.UUID) []uuid.UUID {
+	var found []uuid.UUID
+	for queue := []uuid.UUID{id}; len(queue) > 0; queue = queue[1:] {
+		for _, t := range tasks {
+			if t.Parent == queue[0] {
+				found = append(found, t.ID)
+				queue = append(queue, t.ID)
+			}
+		}
+	}
+	return found
+}
+
+// CheckParent reports whether the task id can become a subtask of parent,
+// uuid.Nil making it a top-level task.
+func CheckParent(tasks []Task, id, parent uuid.UUID) error {
+	switch {
+	case indexOf(tasks, id) < 0:
+		return ErrTaskNotFound
+	case parent == uuid.Nil:
+		return nil
+	case indexOf(tasks, parent) < 0:
+		return ErrTaskNotFound
+	case parent == id || slices.Contains(Descendants(tasks, id), parent):
+		return ErrInvalidParent
+	}
+	return nil
+}
+
+// RollUp completes the task id if it completes automatically and all its
+// subtasks are done, or reopens it if one of them is open again, and so on
+// up its ancestors. It returns the indexes of the tasks it changed.
+func RollUp(tasks []Task, id uuid.UUID) []int {
+	var changed []int
+	for {
+		i := indexOf(tasks, id)
+		if i < 0 || !tasks[i].AutoComplete {
+			return changed
+		}
+		done, total := Progress(tasks, id)
+		if total == 0 || tasks[i].Done == (done == total) {
+			return changed
+		}
+		tasks[i] = tasks[i].WithDone(done == total)
+		changed = append(changed, i)
+		id = tasks[i].Parent
+	}
+}
+
+// RemoveTask returns a copy of tasks without the task id. Its subtasks are
+// removed with it when tree is set, otherwise they move up to its parent.
+// Tasks blocked by a removed task no longer wait on it.
+func RemoveTask(tasks []Task, id uuid.UUID, tree bool) ([]Task, error) {
+	tasks, removed, err := detach(tasks, id, tree)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		for _, r := range removed {
+			if slices.Contains(tasks[i].BlockedBy, r) {
+				tasks[i] = tasks[i].WithoutBlocker(r)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// detach removes the task id from tasks as RemoveTask does, leaving the
+// blockers alone, and returns the IDs of the removed tasks.
+func detach(tasks []Task, id uuid.UUID, tree bool) ([]Task, []uuid.UUID, error) {
+	i := indexOf(tasks, id)
+	if i < 0 {
+		return nil, nil, ErrTaskNotFound
+	}
+	parent := tasks[i].Parent
+	removed := []uuid.UUID{id}
+	if tree {
+		removed = append(removed, Descendants(tasks, id)...)
+	}
+	tasks = slices.DeleteFunc(slices.Clone(tasks), func(t Task) bool { return slices.Contains(removed, t.ID) })
+	for i := range tasks {
+		if tasks[i].Parent == id {
+			tasks[i].Parent = parent
+		}
+	}
+	RollUp(tasks, parent)
+	return tasks, removed, nil
+}
+
+// Nest orders tasks depth first, every task followed by its subtasks, and
+// returns how deeply each one is nested. Tasks whose parent isn't among tasks
+// are at the top level, and subtasks keep their order.
+func Nest(tasks []Task) ([]Task, []int) {
+	shown := map[uuid.UUID]bool{}
+	for _, t := range tasks {
+		shown[t.ID] = true
+	}
+	children := map[uuid.UUID][]Task{}
+	for _, t := range tasks {
+		parent := t.Parent
+		if !shown[parent] {
+			parent = uuid.Nil
+		}
+		children[parent] = append(children[parent], t)
+	}
+	nested := make([]Task, 0, len(tasks))
+	depths := make([]int, 0, len(tasks))
+	var walk func(parent uuid.UUID, depth int)
+	walk = func(parent uuid.UUID, depth int) {
+		for _, t := range children[parent] {
+			nested = append(nested, t)
+			depths = append(depths, depth)
+			walk(t.ID, depth+1)
+		}
+	}
+	walk(uuid.Nil, 0)
+	return nested, depths
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNest(t *testing.T) {
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	tasks := []Task{
+		{ID: c, Title: "c", Parent: b},
+		{ID: a, Title: "a"},
+		{ID: b, Title: "b", Parent: a},
+		{ID: d, Title: "d", Parent: a},
+	}
+	nested, depths := Nest(tasks)
+	var titles []string
+	for _, t := range nested {
+		titles = append(titles, t.Title)
+	}
+	if expected := []string{"a", "b", "c", "d"}; !slices.Equal(titles, expected) {
+		t.Errorf("expected %q, got %q", expected, titles)
+	}
+	if expected := []int{0, 1, 2, 1}; !slices.Equal(depths, expected) {
+		t.Errorf("expected depths %v, got %v", expected, depths)
+	}
+
+	// A subtask whose parent is filtered out is shown at the top level.
+	nested, depths = Nest(tasks[:1])
+	if len(nested) != 1 || depths[0] != 0 {
+		t.Errorf("expected c at the top level, got %v %v", nested, depths)
+	}
+}
+
+// testSubtasks checks nesting tasks, their roll-up and deleting them against
+// an empty store.
+func testSubtasks(t *testing.T, s Store) {
+	t.Helper()
+	parent, first, second, grandchild := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{parent, first, second, grandchild} {
+		if err := s.AddItem(id, "Test Task", Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	for child, p := range map[uuid.UUID]uuid.UUID{first: parent, second: parent, grandchild: second} {
+		if err := s.SetParent(child, p); err != nil {
+			t.Fatalf("Error nesting task: %s", err)
+		}
+	}
+	if err := s.SetParent(parent, grandchild); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("expected %v, got %v", ErrInvalidParent, err)
+	}
+	if err := s.SetParent(first, uuid.New()); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+	}
+	task := func(id uuid.UUID) Task {
+		tasks, _ := s.GetAllItems()
+		for _, t := range tasks {
+			if t.ID == id {
+				return t
+			}
+		}
+		return Task{}
+	}
+
+	if err := s.SetAutoComplete(parent, true); err != nil {
+		t.Fatalf("Error setting auto-completion: %s", err)
+	}
+	for _, id := range []uuid.UUID{first, second} {
+		if err := s.ToggleDone(id); err != nil {
+			t.Fatalf("Error toggling task: %s", err)
+		}
+	}
+	if !task(parent).Done {
+		t.Error("expected the parent to be done with all its subtasks")
+	}
+	if err := s.ToggleDone(first); err != nil {
+		t.Fatalf("Error toggling task: %s", err)
+	}
+	if task(parent).Done {
+		t.Error("expected the parent to be open again")
+	}
+
+	if err := s.DeleteItem(second); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+	if task(grandchild).Parent != parent {
+		t.Errorf("expected the subtask of a deleted task to move up to %s, got %s", parent, task(grandchild).Parent)
+	}
+	if err := s.DeleteTree(parent); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+	if tasks, _ := s.GetAllItems(); len(tasks) != 0 {
+		t.Errorf("expected the subtasks to be deleted too, got %v", tasks)
+	}
+}
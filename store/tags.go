@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidTag = errors.New("invalid tag")
+
+const maxTagLength = 32
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_.-]*$`)
+
+// NormalizeTag returns tag as stored: trimmed, lower case and without a
+// leading "+" or "#". It reports false for tags that are empty, too long or
+// contain anything but letters, digits, "_", "." and "-".
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	tag = strings.TrimLeft(tag, "+#")
+	if len(tag) > maxTagLength || !tagPattern.MatchString(tag) {
+		return "", false
+	}
+	return tag, true
+}
+
+// withTag returns tags with tag added, keeping them sorted and unique.
+func withTag(tags []string, tag string) []string {
+	i, found := slices.BinarySearch(tags, tag)
+	if found {
+		return tags
+	}
+	return slices.Insert(slices.Clone(tags), i, tag)
+}
+
+// WithTag returns a copy of t with tag added.
+func (t Task) WithTag(tag string) Task {
+	t.Tags = withTag(t.Tags, tag)
+	return t
+}
+
+// WithoutTag returns a copy of t with tag removed.
+func (t Task) WithoutTag(tag string) Task {
+	if i, found := slices.BinarySearch(t.Tags, tag); found {
+		t.Tags = slices.Delete(slices.Clone(t.Tags), i, i+1)
+	}
+	if len(t.Tags) == 0 {
+		t.Tags = nil
+	}
+	return t
+}
+
+func (t Task) HasTag(tag string) bool {
+	_, found := slices.BinarySearch(t.Tags, tag)
+	return found
+}
+
+// AllTags returns the tags used by tasks, sorted.
+func AllTags(tasks []Task) []string {
+	var tags []string
+	for _, t := range tasks {
+		for _, tag := range t.Tags {
+			tags = withTag(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected string
+		valid    bool
+	}{
+		{"backend", "backend", true},
+		{" +Ops ", "ops", true},
+		{"#home", "home", true},
+		{"v1.2_beta-3", "v1.2_beta-3", true},
+		{"", "", false},
+		{"+", "", false},
+		{"two words", "", false},
+		{"-leading", "", false},
+		{"x123456789012345678901234567890123", "", false},
+	}
+	for _, tt := range tests {
+		tag, valid := NormalizeTag(tt.tag)
+		if tag != tt.expected || valid != tt.valid {
+			t.Errorf("NormalizeTag(%q): expected %q, %t, got %q, %t", tt.tag, tt.expected, tt.valid, tag, valid)
+		}
+	}
+}
+
+// testTags checks adding and removing tags against an empty store.
+func testTags(t *testing.T, s Store) {
+	t.Helper()
+	taskID := uuid.New()
+	if err := s.AddItem(taskID, "Test Task", Low); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	tags := func() []string {
+		tasks, _ := s.GetAllItems()
+		return tasks[0].Tags
+	}
+
+	for _, tag := range []string{"ops", "+Backend", "ops"} {
+		if err := s.AddTag(taskID, tag); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+	if expected := []string{"backend", "ops"}; !slices.Equal(tags(), expected) {
+		t.Errorf("expected %q, got %q", expected, tags())
+	}
+
+	if err := s.RemoveTag(taskID, "OPS"); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := s.RemoveTag(taskID, "home"); err != nil {
+		t.Fatalf("expected no error removing a missing tag, got %s", err)
+	}
+	if expected := []string{"backend"}; !slices.Equal(tags(), expected) {
+		t.Errorf("expected %q, got %q", expected, tags())
+	}
+
+	if err := s.AddTag(uuid.New(), "ops"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+	}
+	if err := s.AddTag(taskID, "two words"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEntryNotFound = errors.New("time entry not found")
+	ErrEntryExists   = errors.New("a time entry with this ID already exists")
+	ErrInvalidEntry  = errors.New("a time entry must end after it starts")
+	ErrTimerRunning  = errors.New("a timer is already running")
+	ErrNoTimer       = errors.New("no timer is running")
+)
+
+// TimeEntry is time spent on a task, from Start to End. The running timer is
+// the entry without an End; there is at most one.
+type TimeEntry struct {
+	ID    uuid.UUID `json:"ID"`
+	Task  uuid.UUID `json:"Task"`
+	Start time.Time `json:"Start"`
+	End   time.Time `json:"End,omitzero"`
+}
+
+func (e TimeEntry) Running() bool {
+	return e.End.IsZero()
+}
+
+// Duration returns how long e lasted, until now if it is running.
+func (e TimeEntry) Duration(now time.Time) time.Duration {
+	end := e.End
+	if e.Running() {
+		end = now
+	}
+	return max(end.Sub(e.Start), 0)
+}
+
+// FormatDuration writes d in hours and minutes, as 1h05m.
+func FormatDuration(d time.Duration) string {
+	m := int(d.Round(time.Minute) / time.Minute)
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
+
+// EntryOperation returns the operation of type typ, "AddTimeEntry" or
+// "EditTimeEntry", carrying e.
+func EntryOperation(typ string, e TimeEntry) TaskOperation {
+	return TaskOperation{Type: typ, ID: e.ID, Task: e.Task, Start: e.Start, End: e.End}
+}
+
+// CheckEntry reports whether e can join entries, replacing the entry with
+// its ID if there is one: it must end after it starts, and start a timer
+// only if no other one is running.
+func CheckEntry(entries []TimeEntry, e TimeEntry) error {
+	if e.Start.IsZero() || !e.Running() && !e.End.After(e.Start) {
+		return ErrInvalidEntry
+	}
+	if e.Running() && slices.ContainsFunc(entries, func(other TimeEntry) bool { return other.Running() && other.ID != e.ID }) {
+		return ErrTimerRunning
+	}
+	return nil
+}
+
+// RunningTimer returns the index of the running entry, -1 if there is none.
+func RunningTimer(entries []TimeEntry) int {
+	return slices.IndexFunc(entries, TimeEntry.Running)
+}
+
+// TimeSpent totals the time of entries by task.
+func TimeSpent(entries []TimeEntry, now time.Time) map[uuid.UUID]time.Duration {
+	spent := map[uuid.UUID]time.Duration{}
+	for _, e := range entries {
+		spent[e.Task] += e.Duration(now)
+	}
+	return spent
+}
+
+// sortEntries orders entries most recently started first.
+func sortEntries(entries []TimeEntry) {
+	slices.SortStableFunc(entries, func(a, b TimeEntry) int { return b.Start.Compare(a.Start) })
+}
+
+// ReportRow is a line of a time report: the time spent on a task, on the
+// tasks with a tag or on a day.
+type ReportRow struct {
+	// Key is the task's title, the tag, "" for tasks without tags, or the
+	// day as 2006-01-02.
+	Key   string
+	Task  uuid.UUID `json:",omitzero"`
+	Total time.Duration
+}
+
+// ReportKinds are what TimeReport can total time by.
+var ReportKinds = []string{"task", "tag", "day"}
+
+// TimeReport totals the time of entries spent from from to to, either of
+// them zero for no bound, by "task", "tag" or "day" in loc. Tasks lists the
+// tasks the entries may be about, trashed ones included. Days come in
+// order, tasks and tags the most time first.
+func TimeReport(tasks []Task, entries []TimeEntry, by string, from, to, now time.Time, loc *time.Location) []ReportRow {
+	var rows []ReportRow
+	add := func(key string, task uuid.UUID, d time.Duration) {
+		i := slices.IndexFunc(rows, func(r ReportRow) bool { return r.Key == key && r.Task == task })
+		if i < 0 {
+			rows = append(rows, ReportRow{Key: key, Task: task})
+			i = len(rows) - 1
+		}
+		rows[i].Total += d
+	}
+	for _, e := range entries {
+		start, end := e.Start, e.Start.Add(e.Duration(now))
+		if !from.IsZero() && start.Before(from) {
+			start = from
+		}
+		if !to.IsZero() && end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		var t Task
+		if i := indexOf(tasks, e.Task); i >= 0 {
+			t = tasks[i]
+		}
+		switch by {
+		case "task":
+			add(t.Title, e.Task, end.Sub(start))
+		case "tag":
+			if len(t.Tags) == 0 {
+				add("", uuid.Nil, end.Sub(start))
+			}
+			for _, tag := range t.Tags {
+				add(tag, uuid.Nil, end.Sub(start))
+			}
+		case "day":
+			// Entries running past midnight count towards both days.
+			for start.Before(end) {
+				y, m, d := start.In(loc).Date()
+				next := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+				if next.After(end) {
+					next = end
+				}
+				add(start.In(loc).Format(time.DateOnly), uuid.Nil, next.Sub(start))
+				start = next
+			}
+		}
+	}
+	if by == "day" {
+		slices.SortFunc(rows, func(a, b ReportRow) int { return cmp.Compare(a.Key, b.Key) })
+	} else {
+		slices.SortStableFunc(rows, func(a, b ReportRow) int { return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Key, b.Key)) })
+	}
+	return rows
+}
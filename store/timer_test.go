@@ -0,0 +1,135 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testTimer(t *testing.T, s Store) {
+	t.Helper()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+	entries := func() []TimeEntry {
+		t.Helper()
+		entries, err := s.GetTimeEntries()
+		if err != nil {
+			t.Fatalf("Error getting time entries: %s", err)
+		}
+		return entries
+	}
+
+	report, invoice := uuid.New(), uuid.New()
+	must(s.AddItem(report, "Write report", Medium))
+	must(s.AddItem(invoice, "Send invoice", Low))
+	if err := s.StopTimer(); !errors.Is(err, ErrNoTimer) {
+		t.Errorf("expected %v, got %v", ErrNoTimer, err)
+	}
+	if err := s.StartTimer(uuid.New(), uuid.New()); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v, got %v", ErrTaskNotFound, err)
+	}
+
+	running := uuid.New()
+	must(s.StartTimer(running, report))
+	if err := s.StartTimer(uuid.New(), invoice); !errors.Is(err, ErrTimerRunning) {
+		t.Errorf("expected %v starting a second timer, got %v", ErrTimerRunning, err)
+	}
+	if got := entries(); len(got) != 1 || !got[0].Running() || got[0].Task != report {
+		t.Fatalf("expected a timer running on the report, got %+v", got)
+	}
+	must(s.StopTimer())
+	if got := entries(); got[0].Running() {
+		t.Fatalf("expected the timer stopped, got %+v", got[0])
+	}
+
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	manual := TimeEntry{ID: uuid.New(), Task: invoice, Start: start, End: start.Add(time.Hour)}
+	must(s.AddTimeEntry(manual))
+	if err := s.AddTimeEntry(manual); !errors.Is(err, ErrEntryExists) {
+		t.Errorf("expected %v adding an entry twice, got %v", ErrEntryExists, err)
+	}
+	if err := s.AddTimeEntry(TimeEntry{ID: uuid.New(), Task: invoice, Start: start, End: start}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("expected %v for an empty entry, got %v", ErrInvalidEntry, err)
+	}
+	manual.End = start.Add(2 * time.Hour)
+	must(s.EditTimeEntry(manual))
+	if err := s.EditTimeEntry(TimeEntry{ID: uuid.New(), Task: invoice, Start: start, End: manual.End}); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("expected %v, got %v", ErrEntryNotFound, err)
+	}
+	got := entries()
+	if i := slices.IndexFunc(got, func(e TimeEntry) bool { return e.ID == manual.ID }); i < 0 || !got[i].End.Equal(manual.End) {
+		t.Fatalf("expected the entry edited, got %+v", got)
+	}
+	if spent := TimeSpent(got, time.Now())[invoice]; spent != 2*time.Hour {
+		t.Errorf("expected 2h spent on the invoice, got %s", spent)
+	}
+
+	// Time entries aren't changes that can be undone.
+	must(s.ToggleDone(invoice))
+	must(s.DeleteTimeEntry(running))
+	must(s.Undo())
+	if got := entries(); len(got) != 1 {
+		t.Errorf("expected the deletion kept, got %+v", got)
+	}
+	if err := s.DeleteTimeEntry(running); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("expected %v, got %v", ErrEntryNotFound, err)
+	}
+
+	must(s.DeleteItem(invoice))
+	must(s.PurgeTask(invoice))
+	if got := entries(); len(got) != 0 {
+		t.Errorf("expected the time of purged tasks dropped, got %+v", got)
+	}
+}
+
+func TestTimeReport(t *testing.T) {
+	loc := time.UTC
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	report, invoice := uuid.New(), uuid.New()
+	tasks := []Task{
+		{ID: report, Title: "Write report", Tags: []string{"work", "writing"}},
+		{ID: invoice, Title: "Send invoice", Tags: []string{"work"}},
+	}
+	entries := []TimeEntry{
+		{Task: report, Start: day.Add(-time.Hour), End: day.Add(time.Hour)},
+		{Task: invoice, Start: day.Add(9 * time.Hour), End: day.Add(12 * time.Hour)},
+		{Task: report, Start: day.Add(20 * time.Hour)},
+	}
+	now := day.Add(21 * time.Hour)
+
+	tests := []struct {
+		by       string
+		from, to time.Time
+		want     []ReportRow
+	}{
+		{"task", time.Time{}, time.Time{}, []ReportRow{
+			{Key: "Send invoice", Task: invoice, Total: 3 * time.Hour},
+			{Key: "Write report", Task: report, Total: 3 * time.Hour},
+		}},
+		{"tag", time.Time{}, time.Time{}, []ReportRow{
+			{Key: "work", Total: 6 * time.Hour},
+			{Key: "writing", Total: 3 * time.Hour},
+		}},
+		{"day", time.Time{}, time.Time{}, []ReportRow{
+			{Key: "2026-03-01", Total: time.Hour},
+			{Key: "2026-03-02", Total: 5 * time.Hour},
+		}},
+		{"task", day, day.Add(10 * time.Hour), []ReportRow{
+			{Key: "Send invoice", Task: invoice, Total: time.Hour},
+			{Key: "Write report", Task: report, Total: time.Hour},
+		}},
+	}
+	for _, tt := range tests {
+		got := TimeReport(tasks, entries, tt.by, tt.from, tt.to, now, loc)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("TimeReport by %s from %v to %v = %+v, want %+v", tt.by, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrashTask removes the task id from tasks as RemoveTask does and adds it to
+// trash, deleted at now, together with its subtasks when tree is set. Trashed
+// tasks keep their parent, tags and blockers for RestoreTask, and the tasks
+// they block keep waiting on them once restored.
+func TrashTask(tasks, trash []Task, id uuid.UUID, tree bool, now time.Time) ([]Task, []Task, error) {
+	left, removed, err := detach(tasks, id, tree)
+	if err != nil {
+		return nil, nil, err
+	}
+	trash = slices.Clone(trash)
+	for _, t := range tasks {
+		if slices.Contains(removed, t.ID) {
+			t.Deleted = now
+			trash = append(trash, t)
+		}
+	}
+	return left, trash, nil
+}
+
+// RestoreTask moves the task id back from trash to tasks with the subtasks
+// trashed under it. It becomes a top-level task if its parent is gone.
+func RestoreTask(tasks, trash []Task, id uuid.UUID) ([]Task, []Task, error) {
+	i := indexOf(trash, id)
+	if i < 0 {
+		return nil, nil, ErrTaskNotFound
+	}
+	restored := append([]uuid.UUID{id}, Descendants(trash, id)...)
+	parent := trash[i].Parent
+	if indexOf(tasks, parent) < 0 {
+		parent = uuid.Nil
+	}
+	tasks = slices.Clone(tasks)
+	for _, t := range trash {
+		if !slices.Contains(restored, t.ID) {
+			continue
+		}
+		t.Deleted = time.Time{}
+		if t.ID == id {
+			t.Parent = parent
+		}
+		j, _ := slices.BinarySearchFunc(tasks, t.Position, func(t Task, position float64) int {
+			switch {
+			case t.Position < position:
+				return -1
+			case t.Position > position:
+				return 1
+			}
+			return 0
+		})
+		tasks = slices.Insert(tasks, j, t)
+	}
+	trash = slices.DeleteFunc(slices.Clone(trash), func(t Task) bool { return slices.Contains(restored, t.ID) })
+	RollUp(tasks, parent)
+	return tasks, trash, nil
+}
+
+// PurgeTask removes the task id and the subtasks trashed under it from trash
+// for good.
+func PurgeTask(trash []Task, id uuid.UUID) ([]Task, error) {
+	if indexOf(trash, id) < 0 {
+		return nil, ErrTaskNotFound
+	}
+	purged := append([]uuid.UUID{id}, Descendants(trash, id)...)
+	return slices.DeleteFunc(slices.Clone(trash), func(t Task) bool { return slices.Contains(purged, t.ID) }), nil
+}
+
+// EmptyTrash removes the tasks deleted before before from trash for good,
+// all of them given a zero time.
+func EmptyTrash(trash []Task, before time.Time) []Task {
+	return slices.DeleteFunc(slices.Clone(trash), func(t Task) bool { return before.IsZero() || t.Deleted.Before(before) })
+}
+
+// unblocked returns a copy of tasks no longer waiting on the tasks missing
+// from live.
+func unblocked(tasks, live []Task) []Task {
+	tasks = slices.Clone(tasks)
+	for i, t := range tasks {
+		for _, blocker := range t.BlockedBy {
+			if indexOf(live, blocker) < 0 {
+				tasks[i] = tasks[i].WithoutBlocker(blocker)
+			}
+		}
+	}
+	return tasks
+}
+
+// sortTrash orders trashed tasks most recently deleted first.
+func sortTrash(trash []Task) {
+	slices.SortStableFunc(trash, func(a, b Task) int { return b.Deleted.Compare(a.Deleted) })
+}
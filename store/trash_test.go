@@ -0,0 +1,108 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testTrash(t *testing.T, s Store) {
+	t.Helper()
+	parent, child, blocker, other := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{parent, child, blocker, other} {
+		if err := s.AddItem(id, "Test Task", Low); err != nil {
+			t.Fatalf("Error adding task: %s", err)
+		}
+	}
+	if err := s.SetParent(child, parent); err != nil {
+		t.Fatalf("Error nesting task: %s", err)
+	}
+	if err := s.AddTag(child, "ops"); err != nil {
+		t.Fatalf("Error tagging task: %s", err)
+	}
+	if err := s.AddBlocker(other, blocker); err != nil {
+		t.Fatalf("Error adding blocker: %s", err)
+	}
+	ids := func(tasks []Task, err error) []uuid.UUID {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		var got []uuid.UUID
+		for _, task := range tasks {
+			got = append(got, task.ID)
+		}
+		return got
+	}
+
+	if err := s.DeleteTree(parent); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+	if err := s.DeleteItem(blocker); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+	if got := ids(s.GetAllItems()); !slices.Equal(got, []uuid.UUID{other}) {
+		t.Errorf("expected only the other task left, got %v", got)
+	}
+	trash, _ := s.GetTrash()
+	if got := ids(trash, nil); len(got) != 3 || got[0] != blocker || trash[0].Deleted.IsZero() {
+		t.Errorf("expected the deleted tasks in the trash, latest first, got %+v", trash)
+	}
+	if err := s.EditTask(child, "Renamed"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v editing a trashed task, got %v", ErrTaskNotFound, err)
+	}
+	if err := s.ToggleDone(other); err != nil {
+		t.Errorf("expected the task no longer blocked, got %v", err)
+	}
+
+	if err := s.RestoreTask(parent); err != nil {
+		t.Fatalf("Error restoring task: %s", err)
+	}
+	tasks, _ := s.GetAllItems()
+	if got := ids(tasks, nil); !slices.Equal(got, []uuid.UUID{parent, child, other}) {
+		t.Errorf("expected the task restored with its subtask, got %v", got)
+	}
+	if i := slices.IndexFunc(tasks, func(t Task) bool { return t.ID == child }); tasks[i].Parent != parent || !slices.Equal(tasks[i].Tags, []string{"ops"}) {
+		t.Errorf("expected the subtask restored as it was, got %+v", tasks[i])
+	}
+	if err := s.RestoreTask(parent); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v restoring a task twice, got %v", ErrTaskNotFound, err)
+	}
+	if i := slices.IndexFunc(tasks, func(t Task) bool { return t.ID == other }); len(tasks[i].BlockedBy) != 0 {
+		t.Errorf("expected the task not waiting on a trashed task, got %+v", tasks[i])
+	}
+	if err := s.RestoreTask(blocker); err != nil {
+		t.Fatalf("Error restoring task: %s", err)
+	}
+	tasks, _ = s.GetAllItems()
+	if i := slices.IndexFunc(tasks, func(t Task) bool { return t.ID == other }); !slices.Equal(tasks[i].BlockedBy, []uuid.UUID{blocker}) {
+		t.Errorf("expected the task blocked by the restored task again, got %+v", tasks[i])
+	}
+	if err := s.DeleteItem(blocker); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+
+	if err := s.DeleteItem(child); err != nil {
+		t.Fatalf("Error deleting task: %s", err)
+	}
+	if err := s.EmptyTrash(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Error emptying the trash: %s", err)
+	}
+	if got := ids(s.GetTrash()); len(got) != 2 {
+		t.Errorf("expected recently deleted tasks kept, got %v", got)
+	}
+	if err := s.PurgeTask(blocker); err != nil {
+		t.Fatalf("Error purging task: %s", err)
+	}
+	if err := s.PurgeTask(other); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected %v purging a task not in the trash, got %v", ErrTaskNotFound, err)
+	}
+	if err := s.EmptyTrash(time.Time{}); err != nil {
+		t.Fatalf("Error emptying the trash: %s", err)
+	}
+	if got := ids(s.GetTrash()); len(got) != 0 {
+		t.Errorf("expected an empty trash, got %v", got)
+	}
+}
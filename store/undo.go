@@ -0,0 +1,313 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNothingToUndo = errors.New("there is nothing to undo")
+	ErrNothingToRedo = errors.New("there is nothing to redo")
+	ErrUndoConflict  = errors.New("the tasks were changed since")
+)
+
+// Recorder is implemented by stores that tell whether a change was recorded
+// for Undo.
+type Recorder interface {
+	Record(ops ...TaskOperation) (bool, error)
+}
+
+// undoLimit is how many changes an UndoStack keeps.
+const undoLimit = 50
+
+// Change is a change made to a store: Ops make it again and Undo reverts it.
+// State holds what it touched as it was left, after the change or once it
+// was undone, to tell whether someone changed it since.
+type Change struct {
+	Ops   []TaskOperation
+	Undo  []TaskOperation `json:",omitempty"`
+	State Snapshot
+}
+
+// Snapshot holds some tasks and lists by ID, nil for those that don't exist.
+type Snapshot struct {
+	Tasks map[uuid.UUID]*Task `json:",omitempty"`
+	Lists map[uuid.UUID]*List `json:",omitempty"`
+}
+
+// UndoStack holds the changes made to a store that can be undone, the most
+// recent last, and those undone that can be redone.
+type UndoStack struct {
+	Done   []Change `json:",omitempty"`
+	Undone []Change `json:",omitempty"`
+}
+
+// record adds the change ops made to the tasks, trash and lists of before,
+// reporting whether it did. snapshot gives the tasks and lists it touched as
+// they are now. Purging the trash can't be undone, so batches doing it aren't
+// recorded.
+func (u *UndoStack) record(before *InMemoryStore, ops []TaskOperation, snapshot func(tasks, lists []uuid.UUID) (Snapshot, error)) (bool, error) {
+	var undo []TaskOperation
+	var tasks, lists []uuid.UUID
+	ops = slices.Clone(ops)
+	scratch := before.clone()
+	for i, op := range ops {
+		ops[i].Result = nil
+		next := scratch.clone()
+		if err := next.apply(op); err != nil {
+			return false, nil
+		}
+		inv, t, l, ok := inverse(scratch, next, op)
+		if !ok {
+			return false, nil
+		}
+		undo = append(inv, undo...)
+		tasks, lists = append(tasks, t...), append(lists, l...)
+		scratch = next
+	}
+	if len(undo) == 0 {
+		// Nothing changed, as when no task was due for archiving or the
+		// task was already blocked.
+		return false, nil
+	}
+	state, err := snapshot(tasks, lists)
+	if err != nil {
+		return false, err
+	}
+	u.Done = append(u.Done, Change{Ops: ops, Undo: undo, State: state})
+	if len(u.Done) > undoLimit {
+		u.Done = slices.Delete(u.Done, 0, len(u.Done)-undoLimit)
+	}
+	u.Undone = nil
+	return true, nil
+}
+
+// step undoes the last change, or redoes the last one undone, through apply
+// and returns the operations it applied. snapshot gives the tasks and lists
+// of the store as they are now. A change whose tasks were changed since is
+// dropped with ErrUndoConflict rather than overwriting them.
+func (u *UndoStack) step(undo bool, snapshot func(tasks, lists []uuid.UUID) (Snapshot, error), apply func([]TaskOperation) error) ([]TaskOperation, error) {
+	from, to, empty := &u.Done, &u.Undone, ErrNothingToUndo
+	if !undo {
+		from, to, empty = to, from, ErrNothingToRedo
+	}
+	if len(*from) == 0 {
+		return nil, empty
+	}
+	c := (*from)[len(*from)-1]
+	current, err := snapshot(c.State.ids())
+	if err != nil {
+		return nil, err
+	}
+	*from = (*from)[:len(*from)-1]
+	if !c.State.matches(current) {
+		return nil, ErrUndoConflict
+	}
+	ops := c.Ops
+	if undo {
+		ops = c.Undo
+	}
+	if err := apply(ops); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUndoConflict, err)
+	}
+	if c.State, err = snapshot(c.State.ids()); err != nil {
+		return nil, err
+	}
+	*to = append(*to, c)
+	return ops, nil
+}
+
+// inverse returns the operations undoing op, which turned before into after,
+// with the IDs of the tasks and lists it touched. It reports false for
+// operations that can't be undone.
+func inverse(before, after *InMemoryStore, op TaskOperation) (undo []TaskOperation, tasks, lists []uuid.UUID, ok bool) {
+	// New tasks, added or next occurrences, go away for good.
+	for _, t := range after.tasks {
+		if indexOf(before.tasks, t.ID) < 0 && indexOf(before.trash, t.ID) < 0 {
+			undo = append(undo, TaskOperation{Type: "Delete", ID: t.ID}, TaskOperation{Type: "Purge", ID: t.ID})
+			tasks = append(tasks, t.ID)
+		}
+	}
+
+	var t Task
+	if i := indexOf(before.tasks, op.ID); i >= 0 {
+		t = before.tasks[i]
+	}
+	switch op.Type {
+	case "Add":
+	case "Delete", "DeleteTree":
+		removed := []uuid.UUID{op.ID}
+		if op.Type == "DeleteTree" {
+			removed = append(removed, Descendants(before.tasks, op.ID)...)
+		}
+		tasks = append(tasks, removed...)
+		undo = append(undo, TaskOperation{Type: "Restore", ID: op.ID})
+		// Restoring brings back the subtasks trashed earlier too.
+		for _, trashed := range before.trash {
+			if slices.Contains(removed, trashed.Parent) {
+				undo = append(undo, TaskOperation{Type: "DeleteTree", ID: trashed.ID})
+			}
+		}
+		for _, other := range before.tasks {
+			if other.Parent == op.ID && op.Type == "Delete" {
+				undo = append(undo, TaskOperation{Type: "SetParent", ID: other.ID, Parent: op.ID})
+				tasks = append(tasks, other.ID)
+			}
+		}
+	case "Restore":
+		tasks = append([]uuid.UUID{op.ID}, Descendants(before.trash, op.ID)...)
+		undo = append(undo, TaskOperation{Type: "DeleteTree", ID: op.ID})
+	case "Edit":
+		undo = append(undo, TaskOperation{Type: "Edit", ID: op.ID, Title: t.Title})
+	case "ToggleDone":
+		undo = append(undo, TaskOperation{Type: "ToggleDone", ID: op.ID})
+		// Reopening a task unarchived it.
+		if !t.Archived.IsZero() {
+			undo = append(undo, TaskOperation{Type: "Archive", ID: op.ID, Archived: true})
+		}
+	case "SetPriority":
+		undo = append(undo, TaskOperation{Type: "SetPriority", ID: op.ID, Priority: t.Priority})
+	case "Update":
+		undo = append(undo, UpdateOperation(t))
+	case "Move":
+		sorted := slices.Clone(before.tasks)
+		sortByPosition(sorted)
+		var next uuid.UUID
+		if i := indexOf(sorted, op.ID); i+1 < len(sorted) {
+			next = sorted[i+1].ID
+		}
+		undo = append(undo, TaskOperation{Type: "Move", ID: op.ID, Before: next})
+	case "AddTag", "RemoveTag":
+		tag, _ := NormalizeTag(op.Tag)
+		if had := slices.Contains(t.Tags, tag); had && op.Type == "RemoveTag" {
+			undo = append(undo, TaskOperation{Type: "AddTag", ID: op.ID, Tag: tag})
+		} else if !had && op.Type == "AddTag" {
+			undo = append(undo, TaskOperation{Type: "RemoveTag", ID: op.ID, Tag: tag})
+		}
+	case "SetList":
+		undo = append(undo, TaskOperation{Type: "SetList", ID: op.ID, List: t.List})
+	case "SetParent":
+		undo = append(undo, TaskOperation{Type: "SetParent", ID: op.ID, Parent: t.Parent})
+	case "SetAutoComplete":
+		undo = append(undo, TaskOperation{Type: "SetAutoComplete", ID: op.ID, AutoComplete: t.AutoComplete})
+		// Completing automatically may have completed the task right away.
+		if i := indexOf(after.tasks, op.ID); i >= 0 && after.tasks[i].Done != t.Done {
+			undo = append(undo, TaskOperation{Type: "ToggleDone", ID: op.ID})
+		}
+	case "AddBlocker", "RemoveBlocker":
+		if had := slices.Contains(t.BlockedBy, op.Blocker); had && op.Type == "RemoveBlocker" {
+			undo = append(undo, TaskOperation{Type: "AddBlocker", ID: op.ID, Blocker: op.Blocker})
+		} else if !had && op.Type == "AddBlocker" {
+			undo = append(undo, TaskOperation{Type: "RemoveBlocker", ID: op.ID, Blocker: op.Blocker})
+		}
+	case "SetRepeat":
+		undo = append(undo, TaskOperation{Type: "SetRepeat", ID: op.ID, Repeat: t.Repeat})
+	case "Archive":
+		undo = append(undo, TaskOperation{Type: "Archive", ID: op.ID, Archived: !t.Archived.IsZero()})
+	case "ArchiveDone":
+		for _, other := range after.tasks {
+			if i := indexOf(before.tasks, other.ID); i >= 0 && before.tasks[i].Archived.IsZero() && !other.Archived.IsZero() {
+				undo = append(undo, TaskOperation{Type: "Archive", ID: other.ID})
+				tasks = append(tasks, other.ID)
+			}
+		}
+		return undo, tasks, nil, true
+	case "CreateList", "RenameList", "ArchiveList", "DeleteList":
+		lists = append(lists, op.ID)
+		var l List
+		if i := slices.IndexFunc(before.lists, func(l List) bool { return l.ID == op.ID }); i >= 0 {
+			l = before.lists[i]
+		}
+		switch op.Type {
+		case "CreateList":
+			undo = append(undo, TaskOperation{Type: "DeleteList", ID: op.ID})
+		case "RenameList":
+			undo = append(undo, TaskOperation{Type: "RenameList", ID: op.ID, Title: l.Name})
+		case "ArchiveList":
+			undo = append(undo, TaskOperation{Type: "ArchiveList", ID: op.ID, Archived: l.Archived})
+		default:
+			undo = append(undo, TaskOperation{Type: "CreateList", ID: op.ID, Title: l.Name})
+			if l.Archived {
+				undo = append(undo, TaskOperation{Type: "ArchiveList", ID: op.ID, Archived: true})
+			}
+			for _, other := range before.tasks {
+				if other.List == op.ID {
+					undo = append(undo, TaskOperation{Type: "SetList", ID: other.ID, List: op.ID})
+					tasks = append(tasks, other.ID)
+				}
+			}
+		}
+		return undo, tasks, lists, true
+	default:
+		// Time entries, among others, aren't changes that can be undone.
+		return nil, nil, nil, false
+	}
+	return undo, append(tasks, op.ID), lists, true
+}
+
+// clone returns a store holding a copy of the tasks, trash, lists and time
+// entries of s for operations to be tried on; callers hold s.mu.
+func (s *InMemoryStore) clone() *InMemoryStore {
+	return &InMemoryStore{tasks: slices.Clone(s.tasks), trash: slices.Clone(s.trash), lists: slices.Clone(s.lists), entries: slices.Clone(s.entries)}
+}
+
+// snapshot returns the tasks and lists of s with the given IDs; callers hold
+// s.mu.
+func (s *InMemoryStore) snapshot(tasks, lists []uuid.UUID) (Snapshot, error) {
+	snap := Snapshot{Tasks: map[uuid.UUID]*Task{}, Lists: map[uuid.UUID]*List{}}
+	for _, id := range tasks {
+		snap.Tasks[id] = nil
+		for _, t := range [][]Task{s.tasks, s.trash} {
+			if i := indexOf(t, id); i >= 0 {
+				task := t[i]
+				snap.Tasks[id] = &task
+			}
+		}
+	}
+	for _, id := range lists {
+		snap.Lists[id] = nil
+		if i := slices.IndexFunc(s.lists, func(l List) bool { return l.ID == id }); i >= 0 {
+			l := s.lists[i]
+			snap.Lists[id] = &l
+		}
+	}
+	return snap, nil
+}
+
+func (snap Snapshot) ids() (tasks, lists []uuid.UUID) {
+	for id := range snap.Tasks {
+		tasks = append(tasks, id)
+	}
+	for id := range snap.Lists {
+		lists = append(lists, id)
+	}
+	return tasks, lists
+}
+
+// matches reports whether current holds the tasks and lists of snap as they
+// were, leaving aside where tasks are in the list and when they were trashed.
+func (snap Snapshot) matches(current Snapshot) bool {
+	for id, t := range snap.Tasks {
+		c := current.Tasks[id]
+		if (t == nil) != (c == nil) || t != nil && !sameState(*t, *c) {
+			return false
+		}
+	}
+	for id, l := range snap.Lists {
+		c := current.Lists[id]
+		if (l == nil) != (c == nil) || l != nil && (l.Name != c.Name || l.Archived != c.Archived) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameState(a, b Task) bool {
+	return a.Title == b.Title && a.Priority == b.Priority && a.Done == b.Done && a.Description == b.Description &&
+		a.Due.Equal(b.Due) && slices.Equal(a.Tags, b.Tags) && a.List == b.List && a.Parent == b.Parent &&
+		a.AutoComplete == b.AutoComplete && slices.Equal(a.BlockedBy, b.BlockedBy) && a.Repeat == b.Repeat &&
+		a.Series == b.Series && a.Deleted.IsZero() == b.Deleted.IsZero() && a.Archived.IsZero() == b.Archived.IsZero()
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testUndo(t *testing.T, s Store) {
+	t.Helper()
+	find := func(id uuid.UUID) (Task, bool) {
+		tasks, err := s.GetAllItems()
+		if err != nil {
+			t.Fatalf("Error getting tasks: %s", err)
+		}
+		i := indexOf(tasks, id)
+		if i < 0 {
+			return Task{}, false
+		}
+		return tasks[i], true
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+
+	if err := s.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("expected ErrNothingToUndo, got %v", err)
+	}
+
+	report := uuid.New()
+	must(s.AddItem(report, "Write report", Medium))
+	must(s.EditTask(report, "Write the report"))
+	must(s.Undo())
+	if task, _ := find(report); task.Title != "Write report" {
+		t.Errorf("expected the title reverted, got %q", task.Title)
+	}
+	must(s.Redo())
+	if task, _ := find(report); task.Title != "Write the report" {
+		t.Errorf("expected the title changed again, got %q", task.Title)
+	}
+	must(s.Undo())
+	must(s.Undo())
+	if _, ok := find(report); ok {
+		t.Error("expected the added task gone")
+	}
+	if trash, _ := s.GetTrash(); len(trash) != 0 {
+		t.Errorf("expected an undone task to skip the trash, got %+v", trash)
+	}
+	must(s.Redo())
+	if _, ok := find(report); !ok {
+		t.Error("expected the task added again")
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		parent, child, waiting := uuid.New(), uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{parent, child, waiting} {
+			must(s.AddItem(id, "Task", Low))
+		}
+		must(s.SetParent(child, parent))
+		must(s.AddBlocker(waiting, parent))
+		must(s.DeleteItem(parent))
+		must(s.Undo())
+		if task, ok := find(parent); !ok || !task.Deleted.IsZero() {
+			t.Fatalf("expected the task restored with its ID, got %+v", task)
+		}
+		if task, _ := find(child); task.Parent != parent {
+			t.Errorf("expected the subtask back under its parent, got %s", task.Parent)
+		}
+		if task, _ := find(waiting); !slices.Equal(task.BlockedBy, []uuid.UUID{parent}) {
+			t.Errorf("expected the task waiting on it again, got %v", task.BlockedBy)
+		}
+	})
+
+	t.Run("recurrence", func(t *testing.T) {
+		plants := uuid.New()
+		must(s.AddItem(plants, "Water plants", Low))
+		must(s.UpdateTask(Task{ID: plants, Title: "Water plants", Priority: Low, Due: time.Now().AddDate(0, 0, 1)}))
+		must(s.SetRepeat(plants, "daily"))
+		must(s.ToggleDone(plants))
+		if _, ok := find(NextOccurrenceID(plants)); !ok {
+			t.Fatal("expected the next occurrence")
+		}
+		must(s.Undo())
+		if task, _ := find(plants); task.Done {
+			t.Error("expected the task open again")
+		}
+		if _, ok := find(NextOccurrenceID(plants)); ok {
+			t.Error("expected the next occurrence gone")
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		draft := uuid.New()
+		must(s.AddItem(draft, "Draft", Low))
+		must(s.EditTask(report, "Report"))
+		must(s.DeleteItem(draft))
+		// Purging can't be undone, so it changes the task behind the
+		// stack's back.
+		must(s.PurgeTask(draft))
+		if err := s.Undo(); !errors.Is(err, ErrUndoConflict) {
+			t.Fatalf("expected ErrUndoConflict, got %v", err)
+		}
+		must(s.Undo())
+		if task, _ := find(report); task.Title != "Write report" {
+			t.Errorf("expected the change before the conflicting one undone, got %q", task.Title)
+		}
+	})
+
+	must(s.EditTask(report, "Write a report"))
+	must(s.Undo())
+	must(s.EditTask(report, "Write reports"))
+	if err := s.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("expected a new change to clear what can be redone, got %v", err)
+	}
+}
+
+func TestInMemoryUndoFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	c := Config{LoadFromFile: true}
+	s, _ := NewInMemoryStore(c)
+	id := uuid.New()
+	if err := s.AddItem(id, "Write report", Medium); err != nil {
+		t.Fatalf("Error adding task: %s", err)
+	}
+	s.SaveTasksToFile()
+
+	s, _ = NewInMemoryStore(c)
+	if err := s.Undo(); err != nil {
+		t.Fatalf("expected the change kept in the file, got %s", err)
+	}
+	if tasks, _ := s.GetAllItems(); len(tasks) != 0 {
+		t.Errorf("expected the task gone, got %+v", tasks)
+	}
+}